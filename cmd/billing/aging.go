@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/jmacd/caspar.water/cmd/internal/billing/aging"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/constant"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/csv"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/logic"
+)
+
+// reportDate returns the --asof date, by default today.
+func (o *options) reportDate() (csv.Date, error) {
+	if o.asOfDate == "" {
+		now := time.Now()
+		return csv.DateFromTime(time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)), nil
+	}
+	return csv.ParseDate(o.asOfDate)
+}
+
+// reportPrefix returns the --output prefix, by default the report
+// name and date.
+func (o *options) reportPrefix(name string, date csv.Date) string {
+	if o.output != "" {
+		return o.output
+	}
+	return o.path(name + "-" + date.Date().Format(time.DateOnly))
+}
+
+// agingReport writes the accounts-receivable aging as CSV and PDF.
+func (o *options) agingReport(result *logic.Result) error {
+	asOf, err := o.reportDate()
+	if err != nil {
+		return err
+	}
+	report := aging.Compute(result.Accounts, asOf)
+	prefix := o.reportPrefix("aging", asOf)
+
+	f, err := os.Create(prefix + ".csv")
+	if err != nil {
+		return err
+	}
+	if err := report.WriteCSV(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	print := result.Layout.Report(
+		result.Business,
+		"Accounts Receivable Aging",
+		asOf.Date().Format(constant.FullDateLayout),
+		report.MainContent,
+	)
+	if err := print.OutputFileAndClose(prefix + ".pdf"); err != nil {
+		return err
+	}
+
+	fmt.Printf("Aging as of %s: total %s (%s)\n",
+		asOf.Date().Format(constant.FullDateLayout), report.Total.Total.Display(), prefix)
+	return nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/jmacd/caspar.water/cmd/internal/billing/logic"
+)
+
+// cycleAudit writes how each cent of one cycle's total was assigned,
+// the exact shares and the leftover cents, as CSV.
+func (o *options) cycleAudit(result *logic.Result) error {
+	cs, err := result.FindCycle(o.cycleName)
+	if err != nil {
+		return err
+	}
+	audit := cs.Audit()
+	prefix := o.output
+	if prefix == "" {
+		prefix = o.path("audit-" + cs.Name())
+	}
+
+	f, err := os.Create(prefix + ".csv")
+	if err != nil {
+		return err
+	}
+	if err := audit.WriteCSV(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Cycle %s exact %s rounded %s to %s, %d leftover cents (%s.csv)\n",
+		cs.Name(), audit.Exact, audit.Rounding, audit.Total.Display(), len(audit.Cents), prefix)
+	return nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// config lists the companies billed by one installation, e.g., the
+// water company, a septic service, and another water company.
+type config struct {
+	Companies []company `yaml:"companies"`
+}
+
+// company is a business with its own files.  Dir is its directory,
+// relative to the configuration file, where the files named by the
+// flags, unless absolute, are found and the outputs are written, so
+// each company has its own business file, users, cycles, statement
+// templates, and layout with its logo and colors.
+//
+// Flags sets flag values for the company, e.g., "payers: payers.csv".
+// A flag given on the command line overrides them.
+type company struct {
+	Name  string            `yaml:"name"`
+	Dir   string            `yaml:"dir"`
+	Flags map[string]string `yaml:"flags"`
+}
+
+func readConfig(name string) (*config, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var cfg config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("config %s: %w", name, err)
+	}
+	if len(cfg.Companies) == 0 {
+		return nil, fmt.Errorf("config %s: no companies", name)
+	}
+	flags := new(options).flagSet(name)
+	seen := map[string]bool{}
+	for i, c := range cfg.Companies {
+		if c.Name == "" || c.Dir == "" {
+			return nil, fmt.Errorf("config %s: company %d needs a name and dir", name, i+1)
+		}
+		if seen[c.Name] {
+			return nil, fmt.Errorf("config %s: duplicate company %s", name, c.Name)
+		}
+		seen[c.Name] = true
+		for f := range c.Flags {
+			if flags.Lookup(f) == nil {
+				return nil, fmt.Errorf("config %s: company %s: unknown flag %s", name, c.Name, f)
+			}
+		}
+		if !filepath.IsAbs(c.Dir) {
+			cfg.Companies[i].Dir = filepath.Join(filepath.Dir(name), c.Dir)
+		}
+	}
+	return &cfg, nil
+}
+
+// forEach runs fn with the options of each company, or only the
+// named company.  The options are parsed from the command line's
+// args, then the company's flags are set unless given in args, and
+// the files are placed in the company's directory.
+func (cfg *config) forEach(args []string, only string, fn func(*options) error) error {
+	found := false
+	for _, c := range cfg.Companies {
+		if only != "" && c.Name != only {
+			continue
+		}
+		found = true
+		o, err := c.options(args)
+		if err != nil {
+			return fmt.Errorf("company %s: %w", c.Name, err)
+		}
+		fmt.Println("Company", c.Name)
+		if err := fn(o); err != nil {
+			return fmt.Errorf("company %s: %w", c.Name, err)
+		}
+	}
+	if !found {
+		return fmt.Errorf("unknown company: %s", only)
+	}
+	return nil
+}
+
+// options returns the company's options, with its flags and in its
+// directory.  Flags given in args apply to every company.
+func (c company) options(args []string) (*options, error) {
+	o := &options{}
+	fs := o.flagSet(c.Name)
+	fs.SetOutput(io.Discard)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	given := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+	for f, v := range c.Flags {
+		if given[f] {
+			continue
+		}
+		if err := fs.Set(f, v); err != nil {
+			return nil, fmt.Errorf("flag %s: %w", f, err)
+		}
+	}
+	o.inDir(c.Dir)
+	return o, nil
+}
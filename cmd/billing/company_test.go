@@ -0,0 +1,200 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jmacd/caspar.water/cmd/internal/billing/logic"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, name, data string) {
+	require.NoError(t, os.MkdirAll(filepath.Dir(name), 0777))
+	require.NoError(t, os.WriteFile(name, []byte(data), 0644))
+}
+
+func TestReadConfig(t *testing.T) {
+	dir := t.TempDir()
+	abs := filepath.Join(dir, "elsewhere")
+	name := filepath.Join(dir, "conf", "companies.yaml")
+	writeFile(t, name, `
+companies:
+  - name: Caspar Water
+    dir: caspar
+  - name: Noyo
+    dir: `+abs+`
+    flags:
+      payers: payers.csv
+`)
+	cfg, err := readConfig(name)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(cfg.Companies))
+	require.Equal(t, filepath.Join(dir, "conf", "caspar"), cfg.Companies[0].Dir)
+	require.Equal(t, abs, cfg.Companies[1].Dir)
+	require.Equal(t, map[string]string{"payers": "payers.csv"}, cfg.Companies[1].Flags)
+
+	for _, bad := range []string{
+		"companies: []\n",
+		"companies:\n  - name: Noyo\n",
+		"companies:\n  - dir: noyo\n",
+		"companies:\n  - name: Noyo\n    dir: a\n  - name: Noyo\n    dir: b\n",
+		"companies:\n  - name: Noyo\n    dir: noyo\n    flags:\n      nonesuch: x\n",
+		"companies: [\n",
+	} {
+		writeFile(t, name, bad)
+		_, err := readConfig(name)
+		require.Error(t, err, "for %s", bad)
+	}
+
+	_, err = readConfig(filepath.Join(dir, "missing.yaml"))
+	require.Error(t, err)
+}
+
+func TestForEach(t *testing.T) {
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+
+	cfg := &config{
+		Companies: []company{
+			{
+				Name: "Caspar Water",
+				Dir:  "caspar",
+				Flags: map[string]string{
+					"payers": "payers.csv",
+					"years":  "5",
+					"cycle":  "2025-Mar",
+				},
+			},
+			{
+				Name: "Noyo",
+				Dir:  "noyo",
+				Flags: map[string]string{
+					"layout": "/shared/layout.yaml",
+				},
+			},
+		},
+	}
+	args := []string{"--cycle", "2025-Sep", "--margins", "0.1", "--margins", "0.2"}
+
+	var opts []*options
+	require.NoError(t, cfg.forEach(args, "", func(o *options) error {
+		opts = append(opts, o)
+		return nil
+	}))
+	require.Equal(t, 2, len(opts))
+
+	// Each company has its own files and flags; a flag on the
+	// command line applies to every company.
+	caspar, noyo := opts[0], opts[1]
+	require.Equal(t, filepath.Join("caspar", "users.csv"), caspar.usersFile)
+	require.Equal(t, filepath.Join("caspar", "statements"), caspar.statementsDir)
+	require.Equal(t, filepath.Join("caspar", "payers.csv"), caspar.payersFile)
+	require.Equal(t, 5, caspar.simYears)
+	require.Equal(t, "2025-Sep", caspar.cycleName)
+	require.Equal(t, "", caspar.layoutFile)
+	require.Equal(t, filepath.Join("caspar", "aging-2025-09-30"), caspar.path("aging-2025-09-30"))
+
+	require.Equal(t, filepath.Join("noyo", "users.csv"), noyo.usersFile)
+	require.Equal(t, "", noyo.payersFile)
+	require.Equal(t, 10, noyo.simYears)
+	require.Equal(t, "2025-Sep", noyo.cycleName)
+	require.Equal(t, "/shared/layout.yaml", noyo.layoutFile)
+	require.Equal(t, scheduleFlags{"0.1", "0.2"}, noyo.marginSchedules)
+	require.Equal(t, scheduleFlags{"0.1", "0.2"}, caspar.marginSchedules)
+
+	// Only the named company.
+	var names []string
+	require.NoError(t, cfg.forEach(args, "Noyo", func(o *options) error {
+		names = append(names, o.dir)
+		return nil
+	}))
+	require.Equal(t, []string{"noyo"}, names)
+
+	require.EqualError(t, cfg.forEach(args, "Albion", func(*options) error {
+		return nil
+	}), "unknown company: Albion")
+
+	require.EqualError(t, cfg.forEach(args, "", func(*options) error {
+		return errors.New("failed")
+	}), "company Caspar Water: failed")
+
+	cfg.Companies[1].Flags["years"] = "many"
+	require.ErrorContains(t, cfg.forEach(nil, "Noyo", func(*options) error {
+		return nil
+	}), "company Noyo: flag years")
+
+	// The working directory is unchanged.
+	after, err := os.Getwd()
+	require.NoError(t, err)
+	require.Equal(t, wd, after)
+}
+
+func TestForEachBill(t *testing.T) {
+	dir := t.TempDir()
+	users := `Account Name,User Name,Service Address,Billing Address,First Period Start
+House1,Smith,"1 Road; Caspar, CA 91234","1 Road; Caspar, CA 91234",%s
+House2,Miller,"2 Road; Caspar, CA 91234","2 Road; Caspar, CA 91234",%s
+`
+	company := func(name, business, start, bill, closing string) {
+		writeFile(t, filepath.Join(dir, name, "business.csv"), `Name,Address,Contact`+business)
+		writeFile(t, filepath.Join(dir, name, "users.csv"), fmt.Sprintf(users, start, start))
+		writeFile(t, filepath.Join(dir, name, "cycles.csv"), `Period Start,Operations,Utilities,Insurance,Taxes,Bill Date,Margin
+`+start+`,$100.00,$100.00,$0.00,$0.00,`+bill+`,0.0
+`)
+		writeFile(t, filepath.Join(dir, name, "payments.csv"), `Date,Account Name,Amount
+`+bill+`,House1,$100.00
+`)
+		writeFile(t, filepath.Join(dir, name, "statements", closing+".txt"), "hello world\n")
+		writeFile(t, filepath.Join(dir, name, "layout.yaml"), `
+blocks:
+  - text: "{{.Business.Name}}"
+  - content: true
+`)
+	}
+	company("quarterly", `,Billing Period,Anchor Month
+"Water Company","1 Drive; Caspar, CA 91234",p: 555-555-5555,Quarterly,2
+`, "2/1/2024", "5/1/2024", "2024-Apr")
+	company("semiannual", `
+"Septic Company","1 Drive; Caspar, CA 91234",p: 555-555-5555
+`, "4/1/2024", "10/1/2024", "2024-Sep")
+
+	name := filepath.Join(dir, "companies.yaml")
+	writeFile(t, name, `
+companies:
+  - name: Quarterly
+    dir: quarterly
+    flags:
+      layout: layout.yaml
+  - name: Semiannual
+    dir: semiannual
+    flags:
+      layout: layout.yaml
+`)
+	cfg, err := readConfig(name)
+	require.NoError(t, err)
+
+	// Each company's periods follow its own schedule.
+	cycles := map[string]string{}
+	require.NoError(t, cfg.forEach(nil, "", func(o *options) error {
+		return o.bill(func(o *options, result *logic.Result) error {
+			cycles[result.Business.Name] = result.Cycles[0].Name()
+			return o.statements(result)
+		})
+	}))
+	require.Equal(t, map[string]string{
+		"Water Company":  "2024-Apr",
+		"Septic Company": "2024-Sep",
+	}, cycles)
+
+	// The statements are written in each company's directory.
+	for _, name := range []string{
+		filepath.Join(dir, "quarterly", "statements", "2024-Apr", "House1.pdf"),
+		filepath.Join(dir, "semiannual", "statements", "2024-Sep", "House2.pdf"),
+	} {
+		_, err := os.Stat(name)
+		require.NoError(t, err, "for %s", name)
+	}
+}
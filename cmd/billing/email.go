@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/smtp"
+	"os"
+	"time"
+
+	"github.com/jmacd/caspar.water/cmd/internal/billing/email"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/logic"
+	"github.com/spf13/afero"
+)
+
+// emailStatements writes the statements, then an .eml file for each
+// statement of one cycle, optionally sending them over SMTP.  The
+// SMTP password is read from $SMTP_PASSWORD.
+func (o *options) emailStatements(result *logic.Result) error {
+	if err := logic.Output(result); err != nil {
+		return err
+	}
+	cycle, err := result.FindCycle(o.cycleName)
+	if err != nil {
+		return err
+	}
+	tmpls, err := logic.ReadEmailTemplates(o.statementsDir, afero.NewOsFs())
+	if err != nil {
+		return err
+	}
+
+	var sender *email.Sender
+	if o.smtpAddr != "" {
+		sender = &email.Sender{Addr: o.smtpAddr}
+		if o.smtpUser != "" {
+			host, _, err := net.SplitHostPort(o.smtpAddr)
+			if err != nil {
+				return err
+			}
+			sender.Auth = smtp.PlainAuth("", o.smtpUser, os.Getenv("SMTP_PASSWORD"), host)
+		}
+	}
+
+	log, sendErr := logic.Deliver(result, cycle, tmpls, sender, time.Now())
+	if err := email.AppendLog(o.emailLog, log); err != nil {
+		return err
+	}
+	for _, d := range log {
+		fmt.Printf("%s %s: %s %s\n", d.Statement, d.AccountName, d.Status, d.To)
+	}
+	return sendErr
+}
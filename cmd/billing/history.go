@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/jmacd/caspar.water/cmd/internal/billing/csv"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/history"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/logic"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/notice"
+	"github.com/spf13/afero"
+)
+
+// historyStatement writes one customer's account history as PDF,
+// with the notices issued to the customer.
+func (o *options) historyStatement(result *logic.Result) error {
+	acct := result.Accounts.Lookup(o.accountName)
+	if acct == nil {
+		return fmt.Errorf("account not found: %q", o.accountName)
+	}
+	to, err := o.reportDate()
+	if err != nil {
+		return err
+	}
+	// By default the history starts with the account's first entry.
+	from := to
+	if entries := acct.Entries(); len(entries) != 0 && entries[0].Date.Before(to) {
+		from = entries[0].Date
+	}
+	if o.fromDate != "" {
+		if from, err = csv.ParseDate(o.fromDate); err != nil {
+			return err
+		}
+	}
+
+	stmt, err := history.New(acct, from, to, to)
+	if err != nil {
+		return err
+	}
+	notices, err := notice.ReadLog(o.noticeLog, afero.NewOsFs())
+	if err != nil {
+		return err
+	}
+	for _, rec := range notices.Account(o.accountName) {
+		stmt.Note(rec.IssueDate, "Notice issued: "+rec.Stage+", "+rec.Overdue.Display()+" overdue")
+	}
+	print, err := result.Layout.PDF(result.Business, acct.User(), stmt, stmt.MainContent)
+	if err != nil {
+		return err
+	}
+	prefix := o.reportPrefix("history-"+o.accountName, to)
+	if err := print.OutputFileAndClose(prefix + ".pdf"); err != nil {
+		return err
+	}
+	fmt.Printf("History of %s: %d entries, balance %s (%s)\n",
+		o.accountName, len(stmt.Lines), stmt.Closing.Display(), prefix)
+	return nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/jmacd/caspar.water/cmd/internal/billing/bank"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/csv"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/logic"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/payer"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/payment"
+	"github.com/spf13/afero"
+)
+
+// importPayments reads a bank export, writes the deposits it cannot
+// match to a review file, then appends the matched deposits to the
+// payments file.
+func (o *options) importPayments(result *logic.Result) error {
+	fs := afero.NewOsFs()
+	if o.bankFile == "" {
+		return fmt.Errorf("--bank is required")
+	}
+	f, err := fs.Open(o.bankFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var txns []bank.Transaction
+	switch strings.ToLower(filepath.Ext(o.bankFile)) {
+	case ".ofx", ".qfx":
+		txns, err = bank.ReadOFX(f)
+	default:
+		if o.mappingFile == "" {
+			return fmt.Errorf("--mapping is required for CSV bank files")
+		}
+		var mapping []bank.Mapping
+		if mapping, err = csv.ReadFile[bank.Mapping](o.mappingFile, fs); err != nil {
+			return err
+		}
+		if len(mapping) != 1 {
+			return fmt.Errorf("mapping file should have one row: %d", len(mapping))
+		}
+		txns, err = bank.ReadCSV(f, mapping[0])
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", o.bankFile, err)
+	}
+
+	existing, err := csv.ReadFile[payment.Payment](o.paymentsFile, fs)
+	if err != nil {
+		return err
+	}
+	m := bank.Matcher{
+		Accounts: result.Accounts,
+		Existing: existing,
+	}
+	if o.payersFile != "" {
+		if m.Payers, err = csv.ReadFile[payer.Payer](o.payersFile, fs); err != nil {
+			return err
+		}
+	}
+	if o.rulesFile != "" {
+		if m.Rules, err = csv.ReadFile[bank.Rule](o.rulesFile, fs); err != nil {
+			return err
+		}
+	}
+
+	imp := m.Match(txns)
+
+	date, err := o.reportDate()
+	if err != nil {
+		return err
+	}
+	review := o.reportPrefix("import-review", date) + ".csv"
+	rf, err := fs.Create(review)
+	if err != nil {
+		return err
+	}
+	if err := imp.WriteReview(rf); err != nil {
+		rf.Close()
+		return err
+	}
+	if err := rf.Close(); err != nil {
+		return err
+	}
+
+	if err := bank.AppendPayments(o.paymentsFile, fs, imp.Payments); err != nil {
+		return err
+	}
+
+	fmt.Printf("Imported %d payments, %d duplicates, %d withdrawals ignored, %d to review (%s)\n",
+		len(imp.Payments), imp.Duplicates, imp.Ignored, len(imp.Review), review)
+	return nil
+}
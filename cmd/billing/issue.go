@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/jmacd/caspar.water/cmd/internal/billing/logic"
+)
+
+// issueStatements writes the statements, then records those of one
+// cycle in the registry of issued statements, closing the cycle.
+func (o *options) issueStatements(result *logic.Result) error {
+	cs, err := result.FindCycle(o.cycleName)
+	if err != nil {
+		return err
+	}
+	if err := logic.Output(result); err != nil {
+		return err
+	}
+	if err := result.Issue(cs); err != nil {
+		return err
+	}
+	if err := result.Registry.Write(o.registryFile); err != nil {
+		return err
+	}
+	fmt.Printf("Issued cycle %s (%s)\n", cs.Name(), o.registryFile)
+	return nil
+}
+
+// verifyStatements reports the differences between the issued
+// statements of closed cycles and the statements computed now.
+func (o *options) verifyStatements(result *logic.Result) error {
+	chs, err := result.Changes()
+	if err != nil {
+		return err
+	}
+	if len(chs) != 0 {
+		return logic.ChangesError(chs)
+	}
+	fmt.Println("Issued statements are unchanged")
+	return nil
+}
@@ -4,37 +4,244 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/jmacd/caspar.water/cmd/internal/billing/logic"
 	"github.com/spf13/afero"
 )
 
-var (
-	usersFile     = flag.String("users", "users.csv", "csv")
-	businessFile  = flag.String("business", "business.csv", "csv")
-	cyclesFile    = flag.String("cycles", "cycles.csv", "csv")
-	paymentsFile  = flag.String("payments", "payments.csv", "csv")
-	statementsDir = flag.String("statements", "statements", "input directory")
-)
+// options are the flag values of one run.  With several companies,
+// each company has its own, see config.forEach.
+type options struct {
+	// dir is the company's directory, empty for the working
+	// directory, see inDir.
+	dir string
+
+	usersFile     string
+	businessFile  string
+	cyclesFile    string
+	paymentsFile  string
+	statementsDir string
+	metersFile    string
+	ratesFile     string
+	journalFile   string
+	adjustFile    string
+	invoicesFile  string
+	payersFile    string
+	lateFeeFile   string
+	expensesFile  string
+	capitalFile   string
+	weightsFile   string
+	meterSizes    string
+	registryFile  string
+	layoutFile    string
+	htmlOutput    bool
+
+	// Several companies
+	configFile  string
+	companyName string
+
+	// Report modes
+	asOfDate    string
+	output      string
+	accountName string
+	fromDate    string
+
+	// Email, summary, audit, and issue modes
+	cycleName string
+	smtpAddr  string
+	smtpUser  string
+	emailLog  string
+
+	// Import mode
+	bankFile    string
+	mappingFile string
+	rulesFile   string
+
+	// Notices mode
+	noticesFile string
+	noticeLog   string
+
+	// Reserve and simulate modes
+	reserveFile     string
+	simYears        int
+	growthRates     string
+	marginSchedules scheduleFlags
+}
+
+func (o *options) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.usersFile, "users", "users.csv", "csv")
+	fs.StringVar(&o.businessFile, "business", "business.csv", "csv")
+	fs.StringVar(&o.cyclesFile, "cycles", "cycles.csv", "csv")
+	fs.StringVar(&o.paymentsFile, "payments", "payments.csv", "csv")
+	fs.StringVar(&o.statementsDir, "statements", "statements", "input directory")
+	fs.StringVar(&o.metersFile, "meters", "", "csv (optional, for metered cycles)")
+	fs.StringVar(&o.ratesFile, "rates", "", "csv (optional, for metered cycles)")
+	fs.StringVar(&o.journalFile, "journal", "", "csv (optional)")
+	fs.StringVar(&o.adjustFile, "adjustments", "", "csv refunds, credits, and adjustments (optional)")
+	fs.StringVar(&o.invoicesFile, "invoices", "", "csv one-off invoices, written by cmd/invoice (optional)")
+	fs.StringVar(&o.payersFile, "payers", "", "csv (optional)")
+	fs.StringVar(&o.lateFeeFile, "latefee", "", "csv (optional)")
+	fs.StringVar(&o.expensesFile, "expenses", "", "csv expense items (optional, for itemized cycles)")
+	fs.StringVar(&o.capitalFile, "capital", "", "csv capital purchases (optional)")
+	fs.StringVar(&o.weightsFile, "weights", "", "csv account weights and meter sizes (optional)")
+	fs.StringVar(&o.meterSizes, "meter-sizes", "", "csv meter size equivalents (optional, for meter sizes)")
+	fs.StringVar(&o.registryFile, "registry", "issued.csv", "csv of issued statements")
+	fs.StringVar(&o.layoutFile, "layout", "", "yaml statement layout (optional)")
+	fs.BoolVar(&o.htmlOutput, "html", false, "also write statements as HTML")
+
+	fs.StringVar(&o.configFile, "config", "", "yaml list of companies, each with its own directory (optional)")
+	fs.StringVar(&o.companyName, "company", "", "company to run from the config (default all)")
+
+	fs.StringVar(&o.asOfDate, "asof", "", "report date M/D/YYYY (default today)")
+	fs.StringVar(&o.output, "output", "", "report output file prefix")
+	fs.StringVar(&o.accountName, "account", "", "account name (history)")
+	fs.StringVar(&o.fromDate, "from", "", "first date M/D/YYYY (history, default the first entry)")
+
+	fs.StringVar(&o.cycleName, "cycle", "", "cycle to email, summarize, audit, or issue, e.g. 2025-Sep (default last)")
+	fs.StringVar(&o.smtpAddr, "smtp", "", "SMTP server host:port (optional, to send)")
+	fs.StringVar(&o.smtpUser, "smtpuser", "", "SMTP user name (optional, password from $SMTP_PASSWORD)")
+	fs.StringVar(&o.emailLog, "emaillog", "email-log.csv", "delivery log csv")
+
+	fs.StringVar(&o.bankFile, "bank", "", "bank export, .ofx/.qfx or csv (import)")
+	fs.StringVar(&o.mappingFile, "mapping", "", "csv column mapping for a csv bank export (import)")
+	fs.StringVar(&o.rulesFile, "rules", "", "csv matching rules (import, optional)")
+
+	fs.StringVar(&o.noticesFile, "notices", "", "csv delinquency notice stages (notices)")
+	fs.StringVar(&o.noticeLog, "noticelog", "notice-log.csv", "csv of issued notices (notices, history)")
+
+	fs.StringVar(&o.reserveFile, "reserve", "", "csv reserve deposits and withdrawals (optional)")
+	fs.IntVar(&o.simYears, "years", 10, "years to simulate")
+	fs.StringVar(&o.growthRates, "growth", "0", "comma-separated yearly expense growth rates (simulate)")
+	fs.Var(&o.marginSchedules, "margins", "comma-separated yearly margins, repeated per schedule (simulate, default the last cycle's)")
+}
+
+// flagSet returns a new flag set of the options.
+func (o *options) flagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	o.register(fs)
+	return fs
+}
+
+// inDir places the options in a company's directory: the files they
+// name, and the outputs, are relative to it.
+func (o *options) inDir(dir string) {
+	o.dir = dir
+	for _, name := range []*string{
+		&o.usersFile, &o.businessFile, &o.cyclesFile, &o.paymentsFile,
+		&o.statementsDir, &o.metersFile, &o.ratesFile, &o.journalFile,
+		&o.adjustFile, &o.invoicesFile, &o.payersFile, &o.lateFeeFile,
+		&o.expensesFile, &o.capitalFile, &o.weightsFile, &o.meterSizes,
+		&o.registryFile, &o.layoutFile, &o.output, &o.emailLog,
+		&o.bankFile, &o.mappingFile, &o.rulesFile, &o.noticesFile,
+		&o.noticeLog, &o.reserveFile,
+	} {
+		*name = o.path(*name)
+	}
+}
+
+// path returns a file name relative to the company's directory.
+func (o *options) path(name string) string {
+	if name == "" || filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(o.dir, name)
+}
+
+// modes are run by name, as in "billing aging [flags]"; the default
+// mode writes the statements.
+var modes = map[string]func(*options, *logic.Result) error{
+	"statements": (*options).statements,
+	"aging":      (*options).agingReport,
+	"history":    (*options).historyStatement,
+	"projection": (*options).projectionReport,
+	"email":      (*options).emailStatements,
+	"import":     (*options).importPayments,
+	"summary":    (*options).cycleSummary,
+	"audit":      (*options).cycleAudit,
+	"reserve":    (*options).reserveReport,
+	"simulate":   (*options).simulateReserve,
+	"issue":      (*options).issueStatements,
+	"verify":     (*options).verifyStatements,
+	"notices":    (*options).issueNotices,
+}
 
 func main() {
-	flag.Parse()
+	mode := "statements"
+	args := os.Args[1:]
+	if len(args) != 0 && !strings.HasPrefix(args[0], "-") {
+		mode, args = args[0], args[1:]
+	}
+	run, ok := modes[mode]
+	if !ok {
+		fmt.Println("unknown mode:", mode)
+		os.Exit(1)
+	}
+	var opts options
+	opts.register(flag.CommandLine)
+	_ = flag.CommandLine.Parse(args)
 
-	result, err := logic.Logic(logic.Inputs{
-		UsersFile:     *usersFile,
-		BusinessFile:  *businessFile,
-		CyclesFile:    *cyclesFile,
-		PaymentsFile:  *paymentsFile,
-		StatementsDir: *statementsDir,
-	}, afero.NewOsFs())
+	if opts.configFile == "" {
+		if err := opts.bill(run); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
+	cfg, err := readConfig(opts.configFile)
 	if err != nil {
-		fmt.Printf("command failed: %v", err)
+		fmt.Println(err)
 		os.Exit(1)
 	}
-
-	if err := logic.Output(result); err != nil {
-		fmt.Println("output failed:", err)
+	if err := cfg.forEach(args, opts.companyName, func(o *options) error {
+		return o.bill(run)
+	}); err != nil {
+		fmt.Println(err)
 		os.Exit(1)
 	}
+}
+
+// statements writes the statements.
+func (o *options) statements(result *logic.Result) error {
+	return logic.Output(result)
+}
 
+// bill computes the result from the files named by the options and
+// runs the mode.
+func (o *options) bill(run func(*options, *logic.Result) error) error {
+	result, err := logic.Logic(logic.Inputs{
+		UsersFile:       o.usersFile,
+		BusinessFile:    o.businessFile,
+		CyclesFile:      o.cyclesFile,
+		PaymentsFile:    o.paymentsFile,
+		StatementsDir:   o.statementsDir,
+		MetersFile:      o.metersFile,
+		RatesFile:       o.ratesFile,
+		JournalFile:     o.journalFile,
+		AdjustmentsFile: o.adjustFile,
+		InvoicesFile:    o.invoicesFile,
+		PayersFile:      o.payersFile,
+		LateFeeFile:     o.lateFeeFile,
+		ExpensesFile:    o.expensesFile,
+		CapitalFile:     o.capitalFile,
+		WeightsFile:     o.weightsFile,
+		MeterSizesFile:  o.meterSizes,
+		RegistryFile:    o.registryFile,
+		LayoutFile:      o.layoutFile,
+		HTML:            o.htmlOutput,
+	}, afero.NewOsFs())
+	if err != nil {
+		return fmt.Errorf("command failed: %w", err)
+	}
+	// The layout's logo is in the company's directory.
+	result.Layout.Logo = o.path(result.Layout.Logo)
+	for _, name := range result.PendingRevisions {
+		fmt.Printf("Revision of cycle %v waits for the next statement\n", name)
+	}
+
+	if err := run(o, result); err != nil {
+		return fmt.Errorf("output failed: %w", err)
+	}
+	return nil
 }
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jmacd/caspar.water/cmd/internal/billing/logic"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/notice"
+	"github.com/spf13/afero"
+)
+
+// issueNotices writes the delinquency notices due as of --asof, one
+// PDF per account in the notices directory, and records them in the
+// notice log.  The log is saved as each notice is written, so that
+// a failed run keeps the notices it issued.
+func (o *options) issueNotices(result *logic.Result) error {
+	if o.noticesFile == "" {
+		return fmt.Errorf("notices mode requires --notices")
+	}
+	asOf, err := o.reportDate()
+	if err != nil {
+		return err
+	}
+	fs := afero.NewOsFs()
+	stages, err := notice.ReadStages(o.noticesFile, o.dir, fs)
+	if err != nil {
+		return err
+	}
+	log, err := notice.ReadLog(o.noticeLog, fs)
+	if err != nil {
+		return err
+	}
+	due := notice.Due(result.Accounts, stages, log, asOf)
+	if len(due) == 0 {
+		fmt.Println("No notices are due")
+		return nil
+	}
+
+	dir := o.reportPrefix("notices", asOf)
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return err
+	}
+	for _, n := range due {
+		acct := result.Accounts.Lookup(n.AccountName)
+		print, err := result.Layout.PDF(result.Business, acct.User(), n, n.MainContent)
+		if err != nil {
+			return err
+		}
+		name := filepath.Join(dir, n.AccountName+"-"+strings.ReplaceAll(n.Stage, " ", "")+".pdf")
+		if err := print.OutputFileAndClose(name); err != nil {
+			return err
+		}
+		if err := log.Add(n.Record()); err != nil {
+			return err
+		}
+		if err := log.Write(o.noticeLog); err != nil {
+			return err
+		}
+		fmt.Printf("%s %s: %s overdue (%s)\n", n.AccountName, n.Stage, n.Overdue, name)
+	}
+	return nil
+}
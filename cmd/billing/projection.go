@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/jmacd/caspar.water/cmd/internal/billing/constant"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/logic"
+)
+
+// projectionReport writes the projected expenses and their basis as
+// CSV and PDF.
+func (o *options) projectionReport(result *logic.Result) error {
+	date, err := o.reportDate()
+	if err != nil {
+		return err
+	}
+	report := result.Projections
+	prefix := o.reportPrefix("projection", date)
+
+	f, err := os.Create(prefix + ".csv")
+	if err != nil {
+		return err
+	}
+	if err := report.WriteCSV(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	print := result.Layout.Report(
+		result.Business,
+		"Expense Projection",
+		date.Date().Format(constant.FullDateLayout),
+		report.MainContent,
+	)
+	if err := print.OutputFileAndClose(prefix + ".pdf"); err != nil {
+		return err
+	}
+
+	for _, c := range report {
+		fmt.Printf("Cycle %s projected by %s\n", c.Name, c.Method.Display())
+		for _, e := range c.Estimates {
+			fmt.Printf("  projected %v %v: %v\n", e.Expense, e.Amount.Display(), e.Basis)
+		}
+	}
+	fmt.Printf("Projected %d cycles (%s)\n", len(report), prefix)
+	return nil
+}
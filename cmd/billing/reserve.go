@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/jmacd/caspar.water/cmd/internal/billing/constant"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/csv"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/logic"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/reserve"
+	"github.com/spf13/afero"
+)
+
+// scheduleFlags collects repeated --margins flags.
+type scheduleFlags []string
+
+func (s *scheduleFlags) String() string {
+	return strings.Join(*s, " ")
+}
+
+func (s *scheduleFlags) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+// reserveLedger reads the reserve file and builds the reserve ledger
+// through the report date.
+func (o *options) reserveLedger(result *logic.Result) (reserve.Ledger, []reserve.Transaction, error) {
+	asOf, err := o.reportDate()
+	if err != nil {
+		return reserve.Ledger{}, nil, err
+	}
+	var txns []reserve.Transaction
+	if o.reserveFile != "" {
+		if txns, err = csv.ReadFile[reserve.Transaction](o.reserveFile, afero.NewOsFs()); err != nil {
+			return reserve.Ledger{}, nil, err
+		}
+	}
+	l, err := result.Reserve(txns, asOf)
+	return l, txns, err
+}
+
+// reserveReport writes the reserve fund ledger as CSV and PDF.
+func (o *options) reserveReport(result *logic.Result) error {
+	l, _, err := o.reserveLedger(result)
+	if err != nil {
+		return err
+	}
+	prefix := o.reportPrefix("reserve", l.AsOf)
+
+	f, err := os.Create(prefix + ".csv")
+	if err != nil {
+		return err
+	}
+	if err := l.WriteCSV(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	print := result.Layout.Report(
+		result.Business,
+		"Reserve Fund",
+		l.AsOf.Date().Format(constant.FullDateLayout),
+		l.MainContent,
+	)
+	if err := print.OutputFileAndClose(prefix + ".pdf"); err != nil {
+		return err
+	}
+
+	fmt.Printf("Reserve balance %s (%s)\n", l.Balance.Display(), prefix)
+	return nil
+}
+
+// simulateReserve projects the reserve balance and charges for each
+// margin schedule and expense growth rate, as CSV and PDF.
+func (o *options) simulateReserve(result *logic.Result) error {
+	l, txns, err := o.reserveLedger(result)
+	if err != nil {
+		return err
+	}
+	basis, err := result.SimulationBasis(l, txns)
+	if err != nil {
+		return err
+	}
+
+	schedules := o.marginSchedules
+	if len(schedules) == 0 {
+		last := result.Cycles[len(result.Cycles)-1].Expenses
+		schedules = scheduleFlags{strconv.FormatFloat(last.Margin, 'f', -1, 64)}
+	}
+	var scenarios []reserve.Scenario
+	for _, sched := range schedules {
+		margins, err := reserve.ParseMargins(sched)
+		if err != nil {
+			return err
+		}
+		for _, g := range strings.Split(o.growthRates, ",") {
+			growth, err := strconv.ParseFloat(strings.TrimSpace(g), 64)
+			if err != nil {
+				return fmt.Errorf("invalid growth rate %q: %w", g, err)
+			}
+			scenarios = append(scenarios, reserve.Scenario{
+				Margins: margins,
+				Growth:  growth,
+			})
+		}
+	}
+
+	sim, err := reserve.Simulate(basis, o.simYears, scenarios)
+	if err != nil {
+		return err
+	}
+	prefix := o.reportPrefix("simulate", l.AsOf)
+
+	f, err := os.Create(prefix + ".csv")
+	if err != nil {
+		return err
+	}
+	if err := sim.WriteCSV(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	print := result.Layout.Report(
+		result.Business,
+		"Reserve Simulation",
+		l.AsOf.Date().Format(constant.FullDateLayout),
+		sim.MainContent,
+	)
+	if err := print.OutputFileAndClose(prefix + ".pdf"); err != nil {
+		return err
+	}
+
+	fmt.Printf("Simulated %d scenarios for %d years from a %s reserve (%s)\n",
+		len(scenarios), o.simYears, basis.Balance.Display(), prefix)
+	return nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/jmacd/caspar.water/cmd/internal/billing/constant"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/logic"
+)
+
+// cycleSummary writes the company summary of one cycle, its expenses,
+// margin, billing, rounding, and collections, as CSV and PDF.
+func (o *options) cycleSummary(result *logic.Result) error {
+	asOf, err := o.reportDate()
+	if err != nil {
+		return err
+	}
+	cs, err := result.FindCycle(o.cycleName)
+	if err != nil {
+		return err
+	}
+	summary := cs.Summary(result.Accounts, asOf)
+	prefix := o.output
+	if prefix == "" {
+		prefix = o.path("summary-" + cs.Name())
+	}
+
+	f, err := os.Create(prefix + ".csv")
+	if err != nil {
+		return err
+	}
+	if err := summary.WriteCSV(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	print := result.Layout.Report(
+		result.Business,
+		"Cycle Summary "+cs.Name(),
+		asOf.Date().Format(constant.FullDateLayout),
+		summary.MainContent,
+	)
+	if err := print.OutputFileAndClose(prefix + ".pdf"); err != nil {
+		return err
+	}
+
+	fmt.Printf("Cycle %s billed %s of %s, collected %s (%s)\n",
+		cs.Name(), summary.Billed.Display(), summary.Total.Display(), summary.Collected.Display(), prefix)
+	return nil
+}
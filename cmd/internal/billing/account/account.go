@@ -1,31 +1,39 @@
 package account
 
 import (
+	"fmt"
+
 	"github.com/jmacd/caspar.water/cmd/internal/billing/csv"
 	"github.com/jmacd/caspar.water/cmd/internal/billing/currency"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/ledger"
 	"github.com/jmacd/caspar.water/cmd/internal/billing/payment"
 	"github.com/jmacd/caspar.water/cmd/internal/billing/user"
 )
 
+// Account is a customer's view of the ledger.
 type Account struct {
-	payments []payment.Payment
-	charges  []payment.Payment
-	user     user.User
+	ledger *ledger.Ledger
+	user   user.User
 }
 
 type Accounts struct {
+	ledger   *ledger.Ledger
 	balances map[string]*Account
+	order    []string
 }
 
 func NewAccounts() *Accounts {
 	return &Accounts{
+		ledger:   ledger.New(),
 		balances: map[string]*Account{},
 	}
 }
 
 func (a *Accounts) Register(u user.User) {
+	a.order = append(a.order, u.AccountName)
 	a.balances[u.AccountName] = &Account{
-		user: u,
+		ledger: a.ledger,
+		user:   u,
 	}
 }
 
@@ -33,37 +41,86 @@ func (a *Accounts) Lookup(name string) *Account {
 	return a.balances[name]
 }
 
-func (a *Account) EnterPayment(pay payment.Payment) {
-	a.payments = append(a.payments, pay)
+// Names lists the account names in registration order.
+func (a *Accounts) Names() []string {
+	return a.order
 }
 
-func (a *Account) EnterAmountDue(date csv.Date, due currency.Amount) {
-	a.charges = append(a.charges, payment.Payment{
-		Date:        date,
-		AccountName: a.user.AccountName,
-		Amount:      due,
-	})
+// Ledger returns the journal shared by all accounts.
+func (a *Accounts) Ledger() *ledger.Ledger {
+	return a.ledger
 }
 
-func (a *Account) Balance(on csv.Date) currency.Amount {
-	var total currency.Amount
-	for _, charge := range a.charges {
-		if !charge.Date.Date().After(on.Date()) {
-			total = currency.Sum(total, charge.Amount)
-		}
+// Post enters a journal record.  A Transfer must name a registered
+// destination account.
+func (a *Accounts) Post(rec ledger.Record) error {
+	if a.Lookup(rec.AccountName) == nil {
+		return fmt.Errorf("journal account not found: %s", rec.AccountName)
 	}
-	for _, pay := range a.payments {
-		if !pay.Date.Date().After(on.Date()) {
-			total = currency.Difference(total, pay.Amount)
-		}
+	if rec.Kind == ledger.Transfer && a.Lookup(rec.ToAccount) == nil {
+		return fmt.Errorf("journal transfer account not found: %s", rec.ToAccount)
+	}
+	e, err := rec.Entry()
+	if err != nil {
+		return err
 	}
-	return total
+	return a.ledger.Post(e)
+}
+
+func (a *Account) User() user.User {
+	return a.user
+}
+
+// Net returns the entry's effect on the account balance.
+func (a *Account) Net(e ledger.Entry) currency.Amount {
+	return e.Amount(a.receivable())
 }
 
-func (a *Account) LastPayment() payment.Payment {
-	if a.payments == nil {
-		return payment.Payment{}
+func (a *Account) receivable() string {
+	return ledger.Receivable(a.user.AccountName)
+}
+
+// Enter posts a journal entry of the given kind to the account.
+func (a *Account) Enter(date csv.Date, kind ledger.Kind, amount currency.Amount, memo string) error {
+	e, err := ledger.NewEntry(date, kind, a.user.AccountName, amount, "", memo)
+	if err != nil {
+		return err
 	}
+	return a.ledger.Post(e)
+}
 
-	return a.payments[len(a.payments)-1]
+func (a *Account) EnterPayment(pay payment.Payment) error {
+	return a.Enter(pay.Date, ledger.Payment, pay.Amount, pay.Comments)
+}
+
+func (a *Account) EnterAmountDue(date csv.Date, due currency.Amount, memo string) error {
+	return a.Enter(date, ledger.Charge, due, memo)
+}
+
+// Balance is the amount owed through a date.
+func (a *Account) Balance(on csv.Date) currency.Amount {
+	return a.ledger.Balance(a.receivable(), on)
+}
+
+// Entries lists every journal entry for the account in date order.
+func (a *Account) Entries() []ledger.Entry {
+	return a.ledger.Entries(a.receivable())
+}
+
+// LastPayment returns the last payment received through a date, so
+// that later payments do not change a prior statement.
+func (a *Account) LastPayment(on csv.Date) payment.Payment {
+	var last payment.Payment
+	for _, e := range a.Entries() {
+		if e.Kind != ledger.Payment || on.Before(e.Date) {
+			continue
+		}
+		last = payment.Payment{
+			Date:        e.Date,
+			AccountName: a.user.AccountName,
+			Amount:      currency.Difference(currency.Units(0), e.Amount(a.receivable())),
+			Comments:    e.Memo,
+		}
+	}
+	return last
 }
@@ -0,0 +1,177 @@
+package adhoc
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/jmacd/caspar.water/cmd/internal/billing/constant"
+	billingcsv "github.com/jmacd/caspar.water/cmd/internal/billing/csv"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/currency"
+	"github.com/spf13/afero"
+)
+
+// Invoice is a one-off invoice, e.g., a connection fee or a repair,
+// recorded in the invoices file shared by the invoice and billing
+// programs.  The billing program charges its amount to the account
+// on its date and lists it on the account's next statement.
+type Invoice struct {
+	// Number is assigned in sequence when the invoice is first
+	// recorded and is stable after.
+	Number int
+
+	Date        billingcsv.Date
+	AccountName string
+	JobName     string
+
+	// Amount is the sum of the invoice's items.
+	Amount currency.Amount
+}
+
+func (inv Invoice) Validate() error {
+	if inv.Number <= 0 {
+		return fmt.Errorf("invoice number should be positive: %d", inv.Number)
+	}
+	if err := inv.Date.Validate(); err != nil {
+		return err
+	}
+	if inv.AccountName == "" {
+		return fmt.Errorf("empty invoice account name")
+	}
+	if inv.JobName == "" {
+		return fmt.Errorf("invoice %d has no job name", inv.Number)
+	}
+	if inv.Amount.Units() <= 0 {
+		return fmt.Errorf("invoice %d amount should be positive: %s", inv.Number, inv.Amount.Display())
+	}
+	return nil
+}
+
+// Memo describes the invoice's charge in the journal and on the
+// statement.
+func (inv Invoice) Memo() string {
+	return fmt.Sprintf("Invoice %d %s", inv.Number, inv.JobName)
+}
+
+// same indicates the invoice is for the same job.
+func (inv Invoice) same(other Invoice) bool {
+	return inv.AccountName == other.AccountName &&
+		inv.JobName == other.JobName &&
+		inv.Date.Date().Equal(other.Date.Date())
+}
+
+// Log is the invoices file.
+type Log struct {
+	invoices map[int]Invoice
+}
+
+func New() *Log {
+	return &Log{
+		invoices: map[int]Invoice{},
+	}
+}
+
+// Read reads an invoices file; a missing file is an empty log.
+func Read(name string, fs afero.Fs) (*Log, error) {
+	l := New()
+	if _, err := fs.Stat(name); errors.Is(err, os.ErrNotExist) {
+		return l, nil
+	}
+	invs, err := billingcsv.ReadFile[Invoice](name, fs)
+	if err != nil {
+		return nil, err
+	}
+	for _, inv := range invs {
+		if _, ok := l.invoices[inv.Number]; ok {
+			return nil, fmt.Errorf("%s: duplicate invoice number %d", name, inv.Number)
+		}
+		l.invoices[inv.Number] = inv
+	}
+	return l, nil
+}
+
+// Record adds an invoice and returns it with its number.  An invoice
+// for the same account, job, and date keeps its number, so invoices
+// can be printed again; its amount cannot change, corrections are
+// entered as adjustments.  A new invoice may request a number,
+// otherwise it is the next in sequence.
+func (l *Log) Record(inv Invoice) (Invoice, error) {
+	for _, prev := range l.invoices {
+		if !prev.same(inv) {
+			continue
+		}
+		if inv.Number != 0 && inv.Number != prev.Number {
+			return Invoice{}, fmt.Errorf("invoice %s was recorded as number %d", prev.JobName, prev.Number)
+		}
+		if prev.Amount != inv.Amount {
+			return Invoice{}, fmt.Errorf("invoice %d was recorded for %s, not %s", prev.Number, prev.Amount.Display(), inv.Amount.Display())
+		}
+		return prev, nil
+	}
+	if inv.Number == 0 {
+		inv.Number = l.next()
+	} else if prev, ok := l.invoices[inv.Number]; ok {
+		return Invoice{}, fmt.Errorf("invoice number %d is %s for %s", inv.Number, prev.JobName, prev.AccountName)
+	}
+	if err := inv.Validate(); err != nil {
+		return Invoice{}, err
+	}
+	l.invoices[inv.Number] = inv
+	return inv, nil
+}
+
+func (l *Log) next() int {
+	n := 0
+	for num := range l.invoices {
+		n = max(n, num)
+	}
+	return n + 1
+}
+
+// Invoices returns the invoices by number.
+func (l *Log) Invoices() []Invoice {
+	var invs []Invoice
+	for _, inv := range l.invoices {
+		invs = append(invs, inv)
+	}
+	sort.Slice(invs, func(i, j int) bool {
+		return invs[i].Number < invs[j].Number
+	})
+	return invs
+}
+
+var header = []string{
+	"Number",
+	"Date",
+	"Account Name",
+	"Job Name",
+	"Amount",
+}
+
+// Write replaces the invoices file.
+func (l *Log) Write(name string) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	cw := csv.NewWriter(f)
+	_ = cw.Write(header)
+	for _, inv := range l.Invoices() {
+		_ = cw.Write([]string{
+			strconv.Itoa(inv.Number),
+			inv.Date.Date().Format(constant.CsvLayout),
+			inv.AccountName,
+			inv.JobName,
+			inv.Amount.Display(),
+		})
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,87 @@
+package adhoc
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jmacd/caspar.water/cmd/internal/billing/csv"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/currency"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func invoice(t *testing.T, number int, name, job string, amount int64) Invoice {
+	date, err := csv.ParseDate("7/1/2025")
+	require.NoError(t, err)
+	return Invoice{
+		Number:      number,
+		Date:        date,
+		AccountName: name,
+		JobName:     job,
+		Amount:      currency.Units(amount),
+	}
+}
+
+func TestLog(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "invoices.csv")
+
+	log, err := Read(name, afero.NewOsFs())
+	require.NoError(t, err)
+	require.Empty(t, log.Invoices())
+
+	// Numbered in sequence.
+	inv, err := log.Record(invoice(t, 0, "House2", "Connection fee", 50000))
+	require.NoError(t, err)
+	require.Equal(t, 1, inv.Number)
+	inv, err = log.Record(invoice(t, 0, "House3", "Emergency repair", 1950))
+	require.NoError(t, err)
+	require.Equal(t, 2, inv.Number)
+
+	// A chosen number, and the sequence continues after it.
+	inv, err = log.Record(invoice(t, 10, "House4", "Meter test", 2500))
+	require.NoError(t, err)
+	require.Equal(t, 10, inv.Number)
+	inv, err = log.Record(invoice(t, 0, "House4", "Meter move", 2500))
+	require.NoError(t, err)
+	require.Equal(t, 11, inv.Number)
+	_, err = log.Record(invoice(t, 2, "House4", "Valve", 2500))
+	require.EqualError(t, err, "invoice number 2 is Emergency repair for House3")
+
+	require.NoError(t, log.Write(name))
+
+	data, err := os.ReadFile(name)
+	require.NoError(t, err)
+	require.Equal(t, `Number,Date,Account Name,Job Name,Amount
+1,7/1/2025,House2,Connection fee,$500.00
+2,7/1/2025,House3,Emergency repair,$19.50
+10,7/1/2025,House4,Meter test,$25.00
+11,7/1/2025,House4,Meter move,$25.00
+`, string(data))
+
+	// Numbers are stable when the same invoice is recorded again.
+	log, err = Read(name, afero.NewOsFs())
+	require.NoError(t, err)
+	inv, err = log.Record(invoice(t, 0, "House3", "Emergency repair", 1950))
+	require.NoError(t, err)
+	require.Equal(t, 2, inv.Number)
+	require.Equal(t, 4, len(log.Invoices()))
+
+	_, err = log.Record(invoice(t, 0, "House3", "Emergency repair", 2000))
+	require.EqualError(t, err, "invoice 2 was recorded for $19.50, not $20.00")
+	_, err = log.Record(invoice(t, 3, "House3", "Emergency repair", 1950))
+	require.EqualError(t, err, "invoice Emergency repair was recorded as number 2")
+	_, err = log.Record(invoice(t, 0, "House3", "Nothing", 0))
+	require.Error(t, err)
+}
+
+func TestLogInvalid(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "invoices.csv", []byte(`Number,Date,Account Name,Job Name,Amount
+1,7/1/2025,House2,Connection fee,$500.00
+1,7/2/2025,House3,Repair,$5.00
+`), 0644))
+	_, err := Read("invoices.csv", fs)
+	require.EqualError(t, err, "invoices.csv: duplicate invoice number 1")
+}
@@ -0,0 +1,115 @@
+package adjustment
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jmacd/caspar.water/cmd/internal/billing/constant"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/csv"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/currency"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/ledger"
+)
+
+// Adjustment is a refund, service credit, or one-off adjustment to a
+// customer account, shown with its reason on the account's next
+// statement.
+type Adjustment struct {
+	Date        csv.Date
+	AccountName string
+
+	// Kind is one of:
+	// - Refund: money returned to the customer, which increases
+	//   the balance.
+	// - Credit: a service credit, e.g., after an outage or a
+	//   boil-water notice, which decreases the balance.
+	// - Adjustment: a one-off correction, negative to decrease
+	//   the balance.
+	Kind ledger.Kind
+
+	// Amount is positive, except for an Adjustment.
+	Amount currency.Amount
+
+	// Reason explains the adjustment on the statement.
+	Reason string
+
+	// Event identifies the occasion, e.g., "Outage 7/14/2025".
+	// An account is adjusted at most once per event.
+	Event string `csv:",optional"`
+}
+
+func (a Adjustment) Validate() error {
+	if err := a.Date.Validate(); err != nil {
+		return err
+	}
+	if a.AccountName == "" {
+		return fmt.Errorf("empty adjustment account name")
+	}
+	switch a.Kind {
+	case ledger.Refund, ledger.Credit, ledger.Adjustment:
+	default:
+		return fmt.Errorf("adjustment kind should be Refund, Credit, or Adjustment: %q", a.Kind)
+	}
+	if a.Amount.IsZero() {
+		return fmt.Errorf("zero adjustment is invalid")
+	}
+	if a.Reason == "" {
+		return fmt.Errorf("adjustment requires a reason")
+	}
+	_, err := a.Entry()
+	return err
+}
+
+// Entry converts the adjustment to a journal entry, with the reason
+// as its memo.
+func (a Adjustment) Entry() (ledger.Entry, error) {
+	return ledger.NewEntry(a.Date, a.Kind, a.AccountName, a.Amount, "", a.Reason)
+}
+
+// Net is the adjustment's effect on the account balance.
+func (a Adjustment) Net() currency.Amount {
+	e, err := a.Entry()
+	if err != nil {
+		return currency.Units(0)
+	}
+	return e.Amount(ledger.Receivable(a.AccountName))
+}
+
+// Check rejects a second adjustment of an account for the same
+// event, and a row repeating another's date, account, kind, and
+// amount, with or without an event.
+func Check(adjs []Adjustment) error {
+	type eventKey struct {
+		account, event string
+	}
+	type rowKey struct {
+		date    string
+		account string
+		kind    ledger.Kind
+		amount  currency.Amount
+	}
+	events := map[eventKey]Adjustment{}
+	rows := map[rowKey]bool{}
+	var errs []error
+	for _, a := range adjs {
+		date := a.Date.Date().Format(constant.CsvLayout)
+		rk := rowKey{date, a.AccountName, a.Kind, a.Amount}
+		if rows[rk] {
+			errs = append(errs, fmt.Errorf("account %s has a duplicate %s of %s on %s",
+				a.AccountName, a.Kind, a.Amount.Display(), date))
+			continue
+		}
+		rows[rk] = true
+
+		if a.Event == "" {
+			continue
+		}
+		ek := eventKey{a.AccountName, a.Event}
+		if prev, ok := events[ek]; ok {
+			errs = append(errs, fmt.Errorf("account %s already adjusted for event %q on %s",
+				a.AccountName, a.Event, prev.Date.Date().Format(constant.CsvLayout)))
+			continue
+		}
+		events[ek] = a
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,90 @@
+package adjustment
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/jmacd/caspar.water/cmd/internal/billing"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/csv"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/currency"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/ledger"
+	"github.com/stretchr/testify/require"
+)
+
+const header = "Date,Account Name,Kind,Amount,Reason,Event"
+
+func TestAdjustmentRead(t *testing.T) {
+	data := header + `
+7/20/2025,Name1,Credit,$25.00,Boil-water notice,Boil water 7/2025
+8/1/2025,Name2,refund,$50.00,Overpayment,
+8/1/2025,Name2,Adjustment,-$5.00,Meter read correction,
+`
+	adjs, err := csv.Read[Adjustment]("<input>", bytes.NewBufferString(data))
+	require.NoError(t, err)
+	require.Equal(t, []Adjustment{
+		{
+			Date:        internal.Must(csv.ParseDate("7/20/2025")),
+			AccountName: "Name1",
+			Kind:        ledger.Credit,
+			Amount:      currency.Units(2500),
+			Reason:      "Boil-water notice",
+			Event:       "Boil water 7/2025",
+		},
+		{
+			Date:        internal.Must(csv.ParseDate("8/1/2025")),
+			AccountName: "Name2",
+			Kind:        ledger.Refund,
+			Amount:      currency.Units(5000),
+			Reason:      "Overpayment",
+		},
+		{
+			Date:        internal.Must(csv.ParseDate("8/1/2025")),
+			AccountName: "Name2",
+			Kind:        ledger.Adjustment,
+			Amount:      currency.Units(-500),
+			Reason:      "Meter read correction",
+		},
+	}, adjs)
+
+	// Credits decrease the balance, refunds increase it.
+	require.Equal(t, currency.Units(-2500), adjs[0].Net())
+	require.Equal(t, currency.Units(5000), adjs[1].Net())
+	require.Equal(t, currency.Units(-500), adjs[2].Net())
+	require.NoError(t, Check(adjs))
+}
+
+func TestAdjustmentInvalid(t *testing.T) {
+	for _, test := range []string{
+		`7/20/2025,,Credit,$25.00,Outage,`,
+		`7/20/2025,Name,Credit,$25.00,,`,
+		`7/20/2025,Name,Credit,-$25.00,Outage,`,
+		`7/20/2025,Name,Refund,$0.00,Overpayment,`,
+		`7/20/2025,Name,Payment,$25.00,Outage,`,
+		`7/20/2025,Name,LateFee,$25.00,Outage,`,
+	} {
+		data := header + "\n" + test
+		_, err := csv.Read[Adjustment]("<input>", bytes.NewBufferString(data))
+		require.Error(t, err, "for %s", test)
+	}
+}
+
+func TestAdjustmentCheck(t *testing.T) {
+	adjs, err := csv.Read[Adjustment]("<input>", bytes.NewBufferString(header+`
+7/20/2025,Name1,Credit,$25.00,Boil-water notice,Boil water 7/2025
+7/20/2025,Name2,Credit,$25.00,Boil-water notice,Boil water 7/2025
+7/21/2025,Name1,Credit,$25.00,Boil-water notice,
+7/21/2025,Name1,Credit,$10.00,Boil-water notice,
+7/22/2025,Name1,Refund,$25.00,Boil-water notice,Boil water 7/2025
+`))
+	require.NoError(t, err)
+	require.EqualError(t, Check(adjs), `account Name1 already adjusted for event "Boil water 7/2025" on 7/20/2025`)
+
+	// A repeated row is a duplicate without an event.
+	adjs, err = csv.Read[Adjustment]("<input>", bytes.NewBufferString(header+`
+7/21/2025,Name1,Credit,$25.00,Boil-water notice,
+7/21/2025,Name2,Credit,$25.00,Boil-water notice,
+7/21/2025,Name1,Credit,$25.00,Outage,
+`))
+	require.NoError(t, err)
+	require.EqualError(t, Check(adjs), `account Name1 has a duplicate Credit of $25.00 on 7/21/2025`)
+}
@@ -0,0 +1,186 @@
+package aging
+
+import (
+	"encoding/csv"
+	"io"
+
+	"github.com/jmacd/caspar.water/cmd/internal/billing/account"
+	billingcsv "github.com/jmacd/caspar.water/cmd/internal/billing/csv"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/currency"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/invoice"
+	"github.com/jmacd/maroto/pkg/pdf"
+)
+
+// Bucket ranges, by the number of days since a charge was posted.
+var Labels = []string{
+	"0-30",
+	"31-60",
+	"61-90",
+	"90+",
+}
+
+const hoursPerDay = 24
+
+// Buckets holds the unpaid balance by age.  A credit balance is
+// reported in the first bucket.
+type Buckets [4]currency.Amount
+
+func bucket(days int) int {
+	switch {
+	case days <= 30:
+		return 0
+	case days <= 60:
+		return 1
+	case days <= 90:
+		return 2
+	default:
+		return 3
+	}
+}
+
+// Row is the aging of one account.
+type Row struct {
+	AccountName string
+	UserName    string
+	Buckets     Buckets
+	Total       currency.Amount
+}
+
+// Report is the accounts-receivable aging on a date.
+type Report struct {
+	AsOf  billingcsv.Date
+	Rows  []Row
+	Total Row
+}
+
+// Compute ages each account's balance by allocating credits
+// (payments, credits, write-offs) to the oldest charges first.
+func Compute(accts *account.Accounts, asOf billingcsv.Date) Report {
+	r := Report{
+		AsOf: asOf,
+		Total: Row{
+			AccountName: "Total",
+		},
+	}
+	for _, name := range accts.Names() {
+		row := Age(accts.Lookup(name), asOf)
+
+		for i := range row.Buckets {
+			r.Total.Buckets[i] = currency.Sum(r.Total.Buckets[i], row.Buckets[i])
+		}
+		r.Total.Total = currency.Sum(r.Total.Total, row.Total)
+		r.Rows = append(r.Rows, row)
+	}
+	return r
+}
+
+// Charge is the unpaid part of a charge.
+type Charge struct {
+	Date   billingcsv.Date
+	Amount currency.Amount
+}
+
+// Unpaid lists the unpaid part of the account's charges through a
+// date, oldest first, allocating credits (payments, credits,
+// write-offs) to the oldest charges first.  The credit is what
+// remains after paying every charge.
+func Unpaid(acct *account.Account, asOf billingcsv.Date) (charges []Charge, credit currency.Amount) {
+	var debits []Charge
+	for _, e := range acct.Entries() {
+		if e.Date.Date().After(asOf.Date()) {
+			continue
+		}
+		net := acct.Net(e)
+		if net.Units() > 0 {
+			debits = append(debits, Charge{
+				Date:   e.Date,
+				Amount: net,
+			})
+		} else {
+			credit = currency.Difference(credit, net)
+		}
+	}
+
+	// Entries are in date order, oldest first.
+	for _, d := range debits {
+		applied := d.Amount
+		if credit.Units() < applied.Units() {
+			applied = credit
+		}
+		credit = currency.Difference(credit, applied)
+		if remain := currency.Difference(d.Amount, applied); !remain.IsZero() {
+			charges = append(charges, Charge{
+				Date:   d.Date,
+				Amount: remain,
+			})
+		}
+	}
+	return charges, credit
+}
+
+// Days is the age of the charge on a date.
+func (c Charge) Days(asOf billingcsv.Date) int {
+	return int(asOf.Date().Sub(c.Date.Date()).Hours() / hoursPerDay)
+}
+
+// Age computes the aging of one account.
+func Age(acct *account.Account, asOf billingcsv.Date) Row {
+	row := Row{
+		AccountName: acct.User().AccountName,
+		UserName:    acct.User().UserName,
+	}
+	charges, credit := Unpaid(acct, asOf)
+	for _, c := range charges {
+		b := bucket(c.Days(asOf))
+		row.Buckets[b] = currency.Sum(row.Buckets[b], c.Amount)
+		row.Total = currency.Sum(row.Total, c.Amount)
+	}
+	if !credit.IsZero() {
+		row.Buckets[0] = currency.Difference(row.Buckets[0], credit)
+		row.Total = currency.Difference(row.Total, credit)
+	}
+	return row
+}
+
+func (row Row) strings() []string {
+	r := []string{row.AccountName, row.UserName}
+	for _, b := range row.Buckets {
+		r = append(r, b.Display())
+	}
+	return append(r, row.Total.Display())
+}
+
+func header() []string {
+	return append(append([]string{"Account Name", "User Name"}, Labels...), "Total")
+}
+
+// WriteCSV writes one row per account followed by the total.
+func (r Report) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(header()); err != nil {
+		return err
+	}
+	for _, row := range r.Rows {
+		if err := cw.Write(row.strings()); err != nil {
+			return err
+		}
+	}
+	if err := cw.Write(r.Total.strings()); err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// MainContent renders the report as a table.
+func (r Report) MainContent(m pdf.Maroto) {
+	var rows [][]string
+	for _, row := range r.Rows {
+		rows = append(rows, row.strings())
+	}
+	rows = append(rows, []string{}, r.Total.strings())
+
+	m.Row(2, func() {
+		m.TableList(header(), rows, invoice.TableStyle)
+	})
+}
@@ -0,0 +1,69 @@
+package aging
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/jmacd/caspar.water/cmd/internal/billing"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/account"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/csv"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/currency"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/ledger"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/user"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAging(t *testing.T) {
+	accts := account.NewAccounts()
+	accts.Register(user.User{AccountName: "A", UserName: "Alice"})
+	accts.Register(user.User{AccountName: "B", UserName: "Bob"})
+	accts.Register(user.User{AccountName: "C", UserName: "Carol"})
+
+	date := func(s string) csv.Date {
+		return internal.Must(csv.ParseDate(s))
+	}
+	enter := func(name, on string, kind ledger.Kind, units int64) {
+		require.NoError(t, accts.Lookup(name).Enter(date(on), kind, currency.Units(units), ""))
+	}
+
+	// Alice's payments cover the oldest charge and half the next.
+	enter("A", "1/1/2023", ledger.Charge, 10000)
+	enter("A", "2/15/2023", ledger.Charge, 10000)
+	enter("A", "3/20/2023", ledger.Charge, 10000)
+	enter("A", "4/15/2023", ledger.Charge, 10000)
+	enter("A", "4/20/2023", ledger.Payment, 15000)
+
+	// Bob overpaid.
+	enter("B", "3/1/2023", ledger.Charge, 10000)
+	enter("B", "3/2/2023", ledger.Payment, 12500)
+
+	// Carol's future charge is excluded.
+	enter("C", "1/1/2023", ledger.Charge, 5000)
+	enter("C", "5/15/2023", ledger.Charge, 5000)
+
+	report := Compute(accts, date("5/1/2023"))
+	require.Equal(t, 3, len(report.Rows))
+
+	require.Equal(t, Buckets{
+		currency.Units(10000),
+		currency.Units(10000),
+		currency.Units(5000),
+		currency.Units(0),
+	}, report.Rows[0].Buckets)
+	require.Equal(t, currency.Units(25000), report.Rows[0].Total)
+
+	require.Equal(t, currency.Units(-2500), report.Rows[1].Buckets[0])
+	require.Equal(t, currency.Units(-2500), report.Rows[1].Total)
+
+	require.Equal(t, currency.Units(5000), report.Rows[2].Buckets[3])
+	require.Equal(t, currency.Units(27500), report.Total.Total)
+
+	var buf bytes.Buffer
+	require.NoError(t, report.WriteCSV(&buf))
+	require.Equal(t, `Account Name,User Name,0-30,31-60,61-90,90+,Total
+A,Alice,$100.00,$100.00,$50.00,$0.00,$250.00
+B,Bob,-$25.00,$0.00,$0.00,$0.00,-$25.00
+C,Carol,$0.00,$0.00,$0.00,$50.00,$50.00
+Total,,$75.00,$100.00,$50.00,$50.00,$275.00
+`, buf.String())
+}
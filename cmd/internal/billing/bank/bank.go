@@ -0,0 +1,240 @@
+package bank
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/csv"
+	"fmt"
+	"html"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmacd/caspar.water/cmd/internal/billing/constant"
+	billingcsv "github.com/jmacd/caspar.water/cmd/internal/billing/csv"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/currency"
+)
+
+// Transaction is one line of a bank statement.  Deposits are
+// positive.
+type Transaction struct {
+	Date   billingcsv.Date
+	Amount currency.Amount
+	Name   string
+	Memo   string
+
+	// Reference is unique to the transaction, the bank's ID
+	// when available, prefixed by the source format so that it
+	// is never numeric.
+	Reference string
+}
+
+// ReadOFX reads the transactions of an OFX or QFX statement, in
+// either the SGML (version 1) or XML (version 2) form.
+func ReadOFX(r io.Reader) ([]Transaction, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var txns []Transaction
+	var fields map[string]string
+
+	s := string(data)
+	for {
+		lt := strings.IndexByte(s, '<')
+		if lt < 0 {
+			break
+		}
+		gt := strings.IndexByte(s[lt:], '>')
+		if gt < 0 {
+			return nil, fmt.Errorf("ofx: unterminated tag")
+		}
+		tag := strings.ToUpper(s[lt+1 : lt+gt])
+		s = s[lt+gt+1:]
+
+		value := s
+		if next := strings.IndexByte(s, '<'); next >= 0 {
+			value = s[:next]
+		}
+		value = html.UnescapeString(strings.TrimSpace(value))
+
+		switch {
+		case tag == "STMTTRN":
+			fields = map[string]string{}
+		case tag == "/STMTTRN":
+			if fields == nil {
+				return nil, fmt.Errorf("ofx: unexpected </STMTTRN>")
+			}
+			txn, err := ofxTransaction(fields)
+			if err != nil {
+				return nil, err
+			}
+			txns = append(txns, txn)
+			fields = nil
+		case fields != nil && !strings.HasPrefix(tag, "/"):
+			fields[tag] = value
+		}
+	}
+	if fields != nil {
+		return nil, fmt.Errorf("ofx: unterminated <STMTTRN>")
+	}
+	return txns, nil
+}
+
+func ofxTransaction(fields map[string]string) (Transaction, error) {
+	posted := fields["DTPOSTED"]
+	if len(posted) < 8 {
+		return Transaction{}, fmt.Errorf("ofx: invalid DTPOSTED: %q", posted)
+	}
+	t, err := time.Parse("20060102", posted[:8])
+	if err != nil {
+		return Transaction{}, fmt.Errorf("ofx: invalid DTPOSTED: %w", err)
+	}
+	amount, err := ParseAmount(fields["TRNAMT"])
+	if err != nil {
+		return Transaction{}, fmt.Errorf("ofx: %w", err)
+	}
+	if fields["FITID"] == "" {
+		return Transaction{}, fmt.Errorf("ofx: transaction without FITID")
+	}
+	return Transaction{
+		Date:      billingcsv.DateFromTime(t),
+		Amount:    amount,
+		Name:      fields["NAME"],
+		Memo:      fields["MEMO"],
+		Reference: "ofx:" + fields["FITID"],
+	}, nil
+}
+
+// ParseAmount reads a bank amount such as "1,234.50", "$400",
+// "-12.5", or "(12.50)".  Amounts are decimal dollars with at most
+// two fraction digits.
+func ParseAmount(s string) (currency.Amount, error) {
+	str := strings.NewReplacer("$", "", ",", "", " ", "").Replace(s)
+	negative := strings.HasPrefix(str, "(") && strings.HasSuffix(str, ")")
+	if negative {
+		str = str[1 : len(str)-1]
+	} else if rest, ok := strings.CutPrefix(str, "-"); ok {
+		negative = true
+		str = rest
+	} else {
+		str = strings.TrimPrefix(str, "+")
+	}
+	dollars, cents, _ := strings.Cut(str, ".")
+	if (dollars == "" && cents == "") || len(cents) > 2 || !digits(dollars) || !digits(cents) {
+		return currency.Amount{}, fmt.Errorf("invalid amount: %q", s)
+	}
+	cents += strings.Repeat("0", 2-len(cents))
+	units, err := strconv.ParseInt(dollars+cents, 10, 64)
+	if err != nil {
+		return currency.Amount{}, fmt.Errorf("invalid amount: %q", s)
+	}
+	if negative {
+		units = -units
+	}
+	return currency.Units(units), nil
+}
+
+// digits tests for decimal digits only.
+func digits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Mapping names the columns of a generic CSV bank export.
+type Mapping struct {
+	// Date, Amount, and Name are required column names.
+	Date   string
+	Amount string
+	Name   string
+
+	// Memo and Reference are optional column names.  Without a
+	// Reference column, one is derived from the transaction.
+	Memo      string `csv:",optional"`
+	Reference string `csv:",optional"`
+
+	// DateLayout is a Go time layout, e.g., "2006-01-02", by
+	// default M/D/YYYY.
+	DateLayout string `csv:",optional"`
+}
+
+func (m Mapping) Validate() error {
+	if m.Date == "" || m.Amount == "" || m.Name == "" {
+		return fmt.Errorf("mapping requires Date, Amount, and Name columns")
+	}
+	return nil
+}
+
+// ReadCSV reads the transactions of a CSV bank export.
+func ReadCSV(r io.Reader, m Mapping) ([]Transaction, error) {
+	read, err := csv.NewReader(bufio.NewReader(r)).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("read bank csv: %w", err)
+	}
+	if len(read) < 1 {
+		return nil, fmt.Errorf("bank csv is empty")
+	}
+	index := map[string]int{}
+	for i, name := range read[0] {
+		index[strings.TrimSpace(name)] = i
+	}
+	// Optional columns that are not mapped have index -1.
+	var cols [5]int
+	for i, name := range []string{m.Date, m.Amount, m.Name, m.Memo, m.Reference} {
+		cols[i] = -1
+		if name == "" {
+			continue
+		}
+		idx, ok := index[name]
+		if !ok {
+			return nil, fmt.Errorf("bank csv has no %q column", name)
+		}
+		cols[i] = idx
+	}
+	layout := m.DateLayout
+	if layout == "" {
+		layout = constant.CsvLayout
+	}
+	cell := func(row []string, i int) string {
+		if i < 0 || i >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[i])
+	}
+
+	var txns []Transaction
+	seen := map[string]int{}
+	for line, row := range read[1:] {
+		t, err := time.Parse(layout, cell(row, cols[0]))
+		if err != nil {
+			return nil, fmt.Errorf("bank csv line %d: %w", line+2, err)
+		}
+		amount, err := ParseAmount(cell(row, cols[1]))
+		if err != nil {
+			return nil, fmt.Errorf("bank csv line %d: %w", line+2, err)
+		}
+		txn := Transaction{
+			Date:   billingcsv.DateFromTime(t),
+			Amount: amount,
+			Name:   cell(row, cols[2]),
+			Memo:   cell(row, cols[3]),
+		}
+		if ref := cell(row, cols[4]); ref != "" {
+			txn.Reference = "csv:" + ref
+		} else {
+			// Identical rows in one export are numbered, so
+			// that a re-import derives the same references.
+			key := fmt.Sprint(t.Format(time.DateOnly), "|", amount.Units(), "|", txn.Name, "|", txn.Memo)
+			seen[key]++
+			sum := sha256.Sum256([]byte(fmt.Sprint(key, "|", seen[key])))
+			txn.Reference = fmt.Sprintf("csv:%x", sum[:8])
+		}
+		txns = append(txns, txn)
+	}
+	return txns, nil
+}
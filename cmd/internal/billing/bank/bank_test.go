@@ -0,0 +1,126 @@
+package bank
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/jmacd/caspar.water/cmd/internal/billing/currency"
+	"github.com/stretchr/testify/require"
+)
+
+const sgml = `OFXHEADER:100
+DATA:OFXSGML
+VERSION:102
+
+<OFX>
+<BANKMSGSRSV1><STMTTRNRS><STMTRS>
+<BANKTRANLIST>
+<STMTTRN>
+<TRNTYPE>CREDIT
+<DTPOSTED>20250601120000[-8:PST]
+<TRNAMT>400.00
+<FITID>20250601001
+<NAME>JOHN MILLER
+<MEMO>House2 &amp; garden
+</STMTTRN>
+<STMTTRN>
+<TRNTYPE>DEBIT
+<DTPOSTED>20250602
+<TRNAMT>-12.50
+<FITID>20250602001
+<NAME>BANK FEE
+</STMTTRN>
+</BANKTRANLIST>
+</STMTRS></STMTTRNRS></BANKMSGSRSV1>
+</OFX>
+`
+
+const xml = `<?xml version="1.0" encoding="UTF-8"?>
+<?OFX OFXHEADER="200" VERSION="220"?>
+<OFX><BANKMSGSRSV1><STMTTRNRS><STMTRS><BANKTRANLIST>
+<STMTTRN><TRNTYPE>CREDIT</TRNTYPE><DTPOSTED>20250601</DTPOSTED><TRNAMT>400.00</TRNAMT><FITID>20250601001</FITID><NAME>JOHN MILLER</NAME><MEMO>House2 &amp; garden</MEMO></STMTTRN>
+<STMTTRN><TRNTYPE>DEBIT</TRNTYPE><DTPOSTED>20250602</DTPOSTED><TRNAMT>-12.50</TRNAMT><FITID>20250602001</FITID><NAME>BANK FEE</NAME></STMTTRN>
+</BANKTRANLIST></STMTRS></STMTTRNRS></BANKMSGSRSV1></OFX>
+`
+
+func TestReadOFX(t *testing.T) {
+	for _, data := range []string{sgml, xml} {
+		txns, err := ReadOFX(bytes.NewBufferString(data))
+		require.NoError(t, err)
+		require.Equal(t, 2, len(txns))
+
+		require.Equal(t, "2025-06-01", txns[0].Date.Date().Format("2006-01-02"))
+		require.Equal(t, currency.Units(40000), txns[0].Amount)
+		require.Equal(t, "JOHN MILLER", txns[0].Name)
+		require.Equal(t, "House2 & garden", txns[0].Memo)
+		require.Equal(t, "ofx:20250601001", txns[0].Reference)
+
+		require.Equal(t, currency.Units(-1250), txns[1].Amount)
+		require.Equal(t, "", txns[1].Memo)
+	}
+
+	for _, bad := range []string{
+		"<STMTTRN><DTPOSTED>2025<TRNAMT>1.00<FITID>1</STMTTRN>",
+		"<STMTTRN><DTPOSTED>20250601<TRNAMT>x<FITID>1</STMTTRN>",
+		"<STMTTRN><DTPOSTED>20250601<TRNAMT>1.00</STMTTRN>",
+		"<STMTTRN><DTPOSTED>20250601<TRNAMT>1.00<FITID>1",
+		"</STMTTRN>",
+	} {
+		_, err := ReadOFX(bytes.NewBufferString(bad))
+		require.Error(t, err, "for %s", bad)
+	}
+}
+
+func TestParseAmount(t *testing.T) {
+	for in, units := range map[string]int64{
+		"400":       40000,
+		"$1,234.50": 123450,
+		"-12.5":     -1250,
+		"(12.50)":   -1250,
+		".5":        50,
+		"7.":        700,
+	} {
+		a, err := ParseAmount(in)
+		require.NoError(t, err, "for %s", in)
+		require.Equal(t, currency.Units(units), a, "for %s", in)
+	}
+	for _, bad := range []string{"twelve", "", "-", "1e3", "NaN", "Inf", "12.345", "0.015", "1.2.3", "--5", "99999999999999999999"} {
+		_, err := ParseAmount(bad)
+		require.Error(t, err, "for %q", bad)
+	}
+}
+
+func TestReadCSV(t *testing.T) {
+	data := `Posted,Description,Details,Credit
+2025-06-01,JOHN MILLER,House2,400.00
+2025-06-01,CHECK DEPOSIT,,"1,200.00"
+2025-06-01,CHECK DEPOSIT,,"1,200.00"
+`
+	m := Mapping{
+		Date:       "Posted",
+		Amount:     "Credit",
+		Name:       "Description",
+		Memo:       "Details",
+		DateLayout: "2006-01-02",
+	}
+	txns, err := ReadCSV(bytes.NewBufferString(data), m)
+	require.NoError(t, err)
+	require.Equal(t, 3, len(txns))
+	require.Equal(t, "House2", txns[0].Memo)
+	require.Equal(t, currency.Units(120000), txns[1].Amount)
+
+	// Identical rows have distinct, repeatable references.
+	require.NotEqual(t, txns[1].Reference, txns[2].Reference)
+	again, err := ReadCSV(bytes.NewBufferString(data), m)
+	require.NoError(t, err)
+	require.Equal(t, txns, again)
+
+	m.Memo = "Missing"
+	_, err = ReadCSV(bytes.NewBufferString(data), m)
+	require.Error(t, err)
+
+	m.Memo = ""
+	m.DateLayout = ""
+	_, err = ReadCSV(bytes.NewBufferString(data), m)
+	require.Error(t, err)
+}
@@ -0,0 +1,335 @@
+package bank
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/jmacd/caspar.water/cmd/internal/billing/account"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/constant"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/currency"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/payer"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/payment"
+	"github.com/spf13/afero"
+)
+
+// Rule assigns transactions to an account when the transaction's
+// name and memo contain the given text, ignoring case.
+type Rule struct {
+	// AccountName is an account or payer name.
+	AccountName string
+
+	// Name and Memo are optional, at least one is required.
+	Name string `csv:",optional"`
+	Memo string `csv:",optional"`
+}
+
+func (r Rule) Validate() error {
+	if r.AccountName == "" {
+		return fmt.Errorf("empty rule account name")
+	}
+	if r.Name == "" && r.Memo == "" {
+		return fmt.Errorf("rule for %s requires a name or memo", r.AccountName)
+	}
+	return nil
+}
+
+func (r Rule) matches(txn Transaction) bool {
+	contains := func(s, sub string) bool {
+		return sub == "" || strings.Contains(normalize(s), normalize(sub))
+	}
+	return contains(txn.Name, r.Name) && contains(txn.Memo, r.Memo)
+}
+
+// Matcher assigns bank transactions to accounts.
+type Matcher struct {
+	Accounts *account.Accounts
+	Payers   []payer.Payer
+	Rules    []Rule
+
+	// Existing payments are checked for duplicates.
+	Existing []payment.Payment
+}
+
+// Review is a transaction that was not imported.
+type Review struct {
+	Transaction
+	Reason string
+}
+
+// Import is the result of matching.
+type Import struct {
+	Payments []payment.Payment
+	Review   []Review
+
+	// Duplicates were imported before, Ignored are withdrawals.
+	Duplicates int
+	Ignored    int
+}
+
+// payee is an account or payer that may be paid.
+type payee struct {
+	name    string
+	people  []string
+	balance func(txn Transaction) currency.Amount
+}
+
+func (m Matcher) payees() []payee {
+	var r []payee
+	for _, name := range m.Accounts.Names() {
+		acct := m.Accounts.Lookup(name)
+		r = append(r, payee{
+			name:   name,
+			people: []string{acct.User().UserName},
+			balance: func(txn Transaction) currency.Amount {
+				return acct.Balance(txn.Date)
+			},
+		})
+	}
+	for _, p := range m.Payers {
+		r = append(r, payee{
+			name:   p.PayerName,
+			people: []string{p.UserName, p.PayerName},
+			balance: func(txn Transaction) currency.Amount {
+				var total currency.Amount
+				for _, name := range p.Accounts {
+					if acct := m.Accounts.Lookup(name); acct != nil {
+						total = currency.Sum(total, acct.Balance(txn.Date))
+					}
+				}
+				return total
+			},
+		})
+	}
+	return r
+}
+
+// Match assigns each deposit to one account or payer, trying in
+// order: the rules, an account or payer name in the memo, the
+// payer's name, and an amount equal to exactly one balance due on
+// the transaction date.  Transactions whose reference was imported
+// before are skipped as duplicates.
+func (m Matcher) Match(txns []Transaction) Import {
+	var imp Import
+	payees := m.payees()
+
+	refs := map[string]bool{}
+	for _, p := range m.Existing {
+		if p.Reference != "" {
+			refs[p.Reference] = true
+		}
+	}
+
+	for _, txn := range txns {
+		if refs[txn.Reference] {
+			imp.Duplicates++
+			continue
+		}
+		refs[txn.Reference] = true
+
+		if txn.Amount.Units() <= 0 {
+			imp.Ignored++
+			continue
+		}
+
+		name, reason := m.match(txn, payees)
+		if name == "" {
+			imp.Review = append(imp.Review, Review{txn, reason})
+			continue
+		}
+		if m.handEntered(txn, name) {
+			imp.Review = append(imp.Review, Review{txn, "possible duplicate of a payment to " + name})
+			continue
+		}
+
+		comments := "Bank deposit " + txn.Name
+		if txn.Memo != "" {
+			comments += ": " + txn.Memo
+		}
+		imp.Payments = append(imp.Payments, payment.Payment{
+			Date:        txn.Date,
+			AccountName: name,
+			Amount:      txn.Amount,
+			Comments:    comments,
+			Reference:   txn.Reference,
+		})
+	}
+	return imp
+}
+
+// match returns the payee name, or the reason for review.
+func (m Matcher) match(txn Transaction, payees []payee) (string, string) {
+	type test struct {
+		what  string
+		match func(p payee) bool
+	}
+	for _, t := range []test{
+		{"rules", func(p payee) bool {
+			for _, r := range m.Rules {
+				if r.AccountName == p.name && r.matches(txn) {
+					return true
+				}
+			}
+			return false
+		}},
+		{"memo", func(p payee) bool {
+			for _, word := range words(txn.Memo) {
+				if word == normalize(p.name) {
+					return true
+				}
+			}
+			return false
+		}},
+		{"payer name", func(p payee) bool {
+			for _, person := range p.people {
+				if normalize(person) == normalize(txn.Name) {
+					return true
+				}
+			}
+			return false
+		}},
+		{"amount", func(p payee) bool {
+			return p.balance(txn) == txn.Amount
+		}},
+	} {
+		var found []string
+		for _, p := range payees {
+			if t.match(p) {
+				found = append(found, p.name)
+			}
+		}
+		switch len(found) {
+		case 0:
+			continue
+		case 1:
+			return found[0], ""
+		default:
+			sort.Strings(found)
+			return "", "ambiguous " + t.what + ": " + strings.Join(found, ", ")
+		}
+	}
+	return "", "no match"
+}
+
+// handEntered indicates an existing payment without a reference on
+// the same date, account, and amount.
+func (m Matcher) handEntered(txn Transaction, name string) bool {
+	for _, p := range m.Existing {
+		if p.Reference == "" && p.AccountName == name && p.Amount == txn.Amount &&
+			p.Date.Date().Equal(txn.Date.Date()) {
+			return true
+		}
+	}
+	return false
+}
+
+func normalize(s string) string {
+	return strings.Join(strings.Fields(strings.ToLower(s)), " ")
+}
+
+func words(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' && r != '-'
+	})
+}
+
+// WriteReview writes the transactions that need review as CSV.
+func (imp Import) WriteReview(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"Date", "Amount", "Name", "Memo", "Reference", "Reason"}); err != nil {
+		return err
+	}
+	for _, r := range imp.Review {
+		if err := cw.Write([]string{
+			r.Date.Date().Format(constant.CsvLayout),
+			r.Amount.Display(),
+			r.Name,
+			r.Memo,
+			r.Reference,
+			r.Reason,
+		}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// AppendPayments appends payments to a payments file, following the
+// file's header.  The file must have a Reference column.
+func AppendPayments(name string, fs afero.Fs, pays []payment.Payment) error {
+	f, err := fs.OpenFile(name, os.O_RDWR|os.O_APPEND, 0)
+	if err != nil {
+		return err
+	}
+	if err := appendPayments(f, name, pays); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func appendPayments(f afero.File, name string, pays []payment.Payment) error {
+	// The header is read from the start, whatever the file
+	// system's offset for appending.
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	read := csv.NewReader(f)
+	read.FieldsPerRecord = -1
+	header, err := read.Read()
+	if err != nil {
+		return fmt.Errorf("read %s header: %w", name, err)
+	}
+	// The file may not end with a newline.
+	if _, err := f.Seek(-1, io.SeekEnd); err != nil {
+		return err
+	}
+	last := make([]byte, 1)
+	if _, err := f.Read(last); err != nil {
+		return err
+	}
+
+	var hasRef bool
+	for _, col := range header {
+		hasRef = hasRef || strings.ReplaceAll(col, " ", "") == "Reference"
+	}
+	if !hasRef {
+		return fmt.Errorf("%s requires a Reference column to import payments", name)
+	}
+
+	cw := csv.NewWriter(f)
+	if last[0] != '\n' {
+		if _, err := f.WriteString("\n"); err != nil {
+			return err
+		}
+	}
+	for _, p := range pays {
+		var row []string
+		for _, col := range header {
+			var v string
+			switch strings.ReplaceAll(col, " ", "") {
+			case "Date":
+				v = p.Date.Date().Format(constant.CsvLayout)
+			case "AccountName":
+				v = p.AccountName
+			case "Amount":
+				v = p.Amount.Display()
+			case "Comments":
+				v = p.Comments
+			case "Reference":
+				v = p.Reference
+			}
+			row = append(row, v)
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
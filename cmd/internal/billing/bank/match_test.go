@@ -0,0 +1,127 @@
+package bank
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/jmacd/caspar.water/cmd/internal/billing"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/account"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/csv"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/currency"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/payer"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/payment"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/user"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func testAccounts(t *testing.T) *account.Accounts {
+	accts := account.NewAccounts()
+	due := internal.Must(csv.ParseDate("5/1/2025"))
+	for _, u := range []struct {
+		account, name string
+		owes          int64
+	}{
+		{"School", "Caspar School", 40000},
+		{"House2", "John Miller", 20000},
+		{"House3", "Ann Sawyer", 20000},
+		{"House4", "Ann Sawyer", 12345},
+	} {
+		accts.Register(user.User{AccountName: u.account, UserName: u.name})
+		require.NoError(t, accts.Lookup(u.account).EnterAmountDue(due, currency.Units(u.owes), "Statement"))
+	}
+	return accts
+}
+
+func txn(ref, name, memo string, units int64) Transaction {
+	return Transaction{
+		Date:      internal.Must(csv.ParseDate("6/1/2025")),
+		Amount:    currency.Units(units),
+		Name:      name,
+		Memo:      memo,
+		Reference: ref,
+	}
+}
+
+func TestMatch(t *testing.T) {
+	m := Matcher{
+		Accounts: testAccounts(t),
+		Payers: []payer.Payer{{
+			PayerName: "Landlord",
+			UserName:  "Rental Company",
+			Accounts:  payer.Accounts{"House3", "House4"},
+		}},
+		Rules: []Rule{
+			{AccountName: "School", Name: "county of"},
+		},
+		Existing: []payment.Payment{
+			{Date: internal.Must(csv.ParseDate("5/1/2025")), AccountName: "House2", Amount: currency.Units(100), Reference: "ofx:old"},
+			{Date: internal.Must(csv.ParseDate("6/1/2025")), AccountName: "House2", Amount: currency.Units(777)},
+		},
+	}
+	imp := m.Match([]Transaction{
+		txn("ofx:1", "COUNTY OF MENDOCINO", "", 40000),
+		txn("ofx:2", "J MILLER", "water house2", 20000),
+		txn("ofx:3", "john  miller", "", 15000),
+		txn("ofx:4", "RENTAL COMPANY", "", 32345),
+		txn("ofx:5", "CHECK", "", 32345),
+		txn("ofx:6", "Ann Sawyer", "", 100),
+		txn("ofx:7", "CHECK", "", 99),
+		txn("ofx:8", "FEE", "", -500),
+		txn("ofx:old", "J MILLER", "", 100),
+		txn("ofx:2", "J MILLER", "water house2", 20000),
+		txn("ofx:9", "J MILLER", "House2", 777),
+		txn("ofx:10", "CHECK", "", 20000),
+	})
+
+	var names []string
+	for _, p := range imp.Payments {
+		names = append(names, p.AccountName)
+	}
+	require.Equal(t, []string{"School", "House2", "House2", "Landlord", "Landlord"}, names)
+	require.Equal(t, "Bank deposit J MILLER: water house2", imp.Payments[1].Comments)
+	require.Equal(t, "ofx:2", imp.Payments[1].Reference)
+
+	require.Equal(t, 2, imp.Duplicates)
+	require.Equal(t, 1, imp.Ignored)
+
+	var reasons []string
+	for _, r := range imp.Review {
+		reasons = append(reasons, r.Reference+" "+r.Reason)
+	}
+	require.Equal(t, []string{
+		"ofx:6 ambiguous payer name: House3, House4",
+		"ofx:7 no match",
+		"ofx:9 possible duplicate of a payment to House2",
+		"ofx:10 ambiguous amount: House2, House3",
+	}, reasons)
+
+	var buf bytes.Buffer
+	require.NoError(t, imp.WriteReview(&buf))
+	require.Contains(t, buf.String(), "6/1/2025,$0.99,CHECK,,ofx:7,no match\n")
+}
+
+func TestAppendPayments(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	name := "payments.csv"
+	require.NoError(t, afero.WriteFile(fs, name, []byte(`Date,Account Name,Amount,Comments,Reference
+5/1/2025,House2,$1.00,,`), 0644))
+
+	pays := []payment.Payment{{
+		Date:        internal.Must(csv.ParseDate("6/1/2025")),
+		AccountName: "House2",
+		Amount:      currency.Units(123456),
+		Comments:    "Bank deposit",
+		Reference:   "ofx:1",
+	}}
+	require.NoError(t, AppendPayments(name, fs, pays))
+
+	read, err := csv.ReadFile[payment.Payment](name, fs)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(read))
+	require.Equal(t, pays[0], read[1])
+
+	// The Reference column is required.
+	require.NoError(t, afero.WriteFile(fs, name, []byte("Date,Account Name,Amount\n5/1/2025,House2,$1.00\n"), 0644))
+	require.Error(t, AppendPayments(name, fs, pays))
+}
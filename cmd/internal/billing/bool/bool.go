@@ -5,6 +5,7 @@ import (
 	"strings"
 )
 
+// Bool is TRUE or FALSE, case-insensitive.  An empty cell is false.
 type Bool bool
 
 func (b *Bool) UnmarshalJSON(d []byte) error {
@@ -12,6 +13,10 @@ func (b *Bool) UnmarshalJSON(d []byte) error {
 	if err := json.Unmarshal(d, &s); err != nil {
 		return err
 	}
+	if s == "" {
+		*b = false
+		return nil
+	}
 
 	var u bool
 	d = []byte(strings.ToLower(s))
@@ -19,6 +19,7 @@ func TestBoolGood(t *testing.T) {
 		"TRUE":  true,
 		"FALSE": false,
 		"FaLsE": false,
+		"":      false,
 	} {
 		var b Bool
 
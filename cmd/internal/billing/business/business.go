@@ -2,8 +2,13 @@ package business
 
 import (
 	"fmt"
+	"net/mail"
+	"time"
 
 	"github.com/jmacd/caspar.water/cmd/internal/billing/address"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/bool"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/currency"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/period"
 )
 
 // Business describes the billing entity and other static
@@ -17,6 +22,44 @@ type Business struct {
 
 	// Contact is how and with whom to discuss the payment.
 	Contact string
+
+	// Email is an optional sender address for emailed
+	// statements.
+	Email string `csv:",optional"`
+
+	// BillingPeriod is Monthly, Quarterly, Semi-annual (the
+	// default), or Annual.
+	BillingPeriod period.Length `csv:",optional"`
+
+	// AnchorMonth is a month (1-12) in which billing periods
+	// start, April by default.
+	AnchorMonth int `csv:",optional"`
+
+	// Service is optional, it names the primary service, e.g.,
+	// Water, on statements of accounts with additional
+	// services, see user.User.Services.
+	Service string `csv:",optional"`
+
+	// Rounding is how the cost with margin of a cycle is
+	// rounded to the cent: Down (the default), HalfUp,
+	// HalfEven, or Up.
+	Rounding currency.Rounding `csv:",default=Down"`
+
+	// ExpenseAppendix prints the itemized expenses of the cycle
+	// on each statement.
+	ExpenseAppendix bool.Bool `csv:",optional"`
+}
+
+// Schedule returns the configured billing period schedule.
+func (m Business) Schedule() period.Schedule {
+	s := period.Default
+	if m.BillingPeriod != 0 {
+		s.Length = m.BillingPeriod
+	}
+	if m.AnchorMonth != 0 {
+		s.Anchor = time.Month(m.AnchorMonth)
+	}
+	return s
 }
 
 func (m Business) Validate() error {
@@ -29,5 +72,13 @@ func (m Business) Validate() error {
 	if m.Contact == "" {
 		return fmt.Errorf("business contact empty")
 	}
+	if err := m.Schedule().Validate(); err != nil {
+		return err
+	}
+	if m.Email != "" {
+		if _, err := mail.ParseAddress(m.Email); err != nil {
+			return fmt.Errorf("business email: %w", err)
+		}
+	}
 	return nil
 }
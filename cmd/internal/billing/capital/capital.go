@@ -0,0 +1,123 @@
+package capital
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/jmacd/caspar.water/cmd/internal/billing/csv"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/currency"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/period"
+)
+
+// Funding is how a capital purchase is paid for.
+type Funding string
+
+const (
+	// ReserveFunding pays for the purchase from the reserve
+	// fund.  Its amortization is reported but not billed.
+	ReserveFunding Funding = "Reserve"
+
+	// ExpenseFunding bills the purchase as a straight-line
+	// expense over its useful life.
+	ExpenseFunding Funding = "Expense"
+)
+
+func (f *Funding) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	for _, fund := range []Funding{ReserveFunding, ExpenseFunding} {
+		if strings.EqualFold(strings.TrimSpace(s), string(fund)) {
+			*f = fund
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid funding: %q", s)
+}
+
+// Purchase is a capital purchase, e.g., a pump or a tank repair.
+type Purchase struct {
+	Name string
+	Date csv.Date
+	Cost currency.Amount
+
+	// LifeYears is the useful life.  The cost is amortized
+	// over the cycles of the life, starting with the cycle
+	// containing the purchase date.
+	LifeYears int
+
+	Funding Funding
+}
+
+func (p Purchase) Validate() error {
+	if p.Name == "" {
+		return fmt.Errorf("capital purchase requires a name")
+	}
+	if p.Cost.Units() <= 0 {
+		return fmt.Errorf("capital purchase %s requires a cost", p.Name)
+	}
+	if p.LifeYears <= 0 {
+		return fmt.Errorf("capital purchase %s requires a useful life", p.Name)
+	}
+	if p.Funding == "" {
+		return fmt.Errorf("capital purchase %s requires a funding source", p.Name)
+	}
+	return nil
+}
+
+// Share is a purchase's amortization in one cycle.
+type Share struct {
+	Purchase
+
+	// Cycle is the number of the cycle in the purchase's life,
+	// starting at 1, of Cycles.
+	Cycle  int
+	Cycles int
+
+	Amount currency.Amount
+
+	// Remaining is the cost not amortized after this cycle.
+	Remaining currency.Amount
+}
+
+// Billed indicates that the share is billed as an expense.
+func (s Share) Billed() bool {
+	return s.Funding == ExpenseFunding
+}
+
+// Amortize returns the shares of the purchases active in a period.
+// The cost is divided evenly over the cycles of the useful life, in
+// the period's schedule, the first cycles taking any extra cent.
+func Amortize(purchases []Purchase, p period.Period) []Share {
+	sched := p.Schedule()
+	var shares []Share
+	for _, pur := range purchases {
+		n := p.Since(sched.Containing(pur.Date))
+		cycles := pur.LifeYears * sched.PerYear()
+		if n < 0 || n >= cycles {
+			continue
+		}
+		split := pur.Cost.Split(cycles)
+		shares = append(shares, Share{
+			Purchase:  pur,
+			Cycle:     n + 1,
+			Cycles:    cycles,
+			Amount:    split[n],
+			Remaining: currency.Sum(split[n+1:]...),
+		})
+	}
+	return shares
+}
+
+// Expense is the sum of the billed shares.
+func Expense(shares []Share) currency.Amount {
+	var total currency.Amount
+	for _, s := range shares {
+		if s.Billed() {
+			total = currency.Sum(total, s.Amount)
+		}
+	}
+	return total
+}
@@ -0,0 +1,69 @@
+package capital
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/jmacd/caspar.water/cmd/internal/billing/csv"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/period"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAmortize(t *testing.T) {
+	purchases, err := csv.Read[Purchase]("<input>", bytes.NewBufferString(`Name,Date,Cost,Life Years,Funding
+Pump,11/15/2024,"$1,000.01",2,expense
+Tank repair,5/1/2025,$600.00,1,Reserve
+`))
+	require.NoError(t, err)
+
+	at := func(start string) []Share {
+		p, err := period.ParseStart(start)
+		require.NoError(t, err)
+		return Amortize(purchases, p)
+	}
+
+	require.Equal(t, 0, len(at("4/1/2024")))
+
+	shares := at("10/1/2024")
+	require.Equal(t, 1, len(shares))
+	require.Equal(t, "Pump", shares[0].Name)
+	require.Equal(t, 1, shares[0].Cycle)
+	require.Equal(t, 4, shares[0].Cycles)
+	require.Equal(t, "$250.01", shares[0].Amount.Display())
+	require.Equal(t, "$750.00", shares[0].Remaining.Display())
+	require.Equal(t, "$250.01", Expense(shares).Display())
+
+	shares = at("4/1/2025")
+	require.Equal(t, 2, len(shares))
+	require.Equal(t, "$250.00", shares[0].Amount.Display())
+	require.Equal(t, "$300.00", shares[1].Amount.Display())
+	require.False(t, shares[1].Billed())
+	require.Equal(t, "$250.00", Expense(shares).Display())
+
+	shares = at("4/1/2026")
+	require.Equal(t, 1, len(shares))
+	require.Equal(t, 4, shares[0].Cycle)
+	require.True(t, shares[0].Remaining.IsZero())
+
+	require.Equal(t, 0, len(at("10/1/2026")))
+
+	// The life is divided in the period's schedule.
+	quarterly := period.Schedule{Length: period.Quarterly, Anchor: time.February}
+	p, err := quarterly.ParseStart("2/1/2025")
+	require.NoError(t, err)
+	shares = Amortize(purchases, p)
+	require.Equal(t, 1, len(shares))
+	require.Equal(t, 2, shares[0].Cycle)
+	require.Equal(t, 8, shares[0].Cycles)
+	require.Equal(t, "$125.00", shares[0].Amount.Display())
+
+	for _, bad := range []string{
+		`Pump,11/15/2024,$1.00,0,Expense`,
+		`Pump,11/15/2024,$1.00,2,Loan`,
+		`,11/15/2024,$1.00,2,Expense`,
+	} {
+		_, err := csv.Read[Purchase]("<input>", bytes.NewBufferString("Name,Date,Cost,Life Years,Funding\n"+bad+"\n"))
+		require.Error(t, err, "for %s", bad)
+	}
+}
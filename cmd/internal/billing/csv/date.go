@@ -60,3 +60,34 @@ func DateFromTime(t time.Time) Date {
 func (d Date) Before(x Date) bool {
 	return d.date.Before(x.date)
 }
+
+// OptionalDate is a date that may be left empty.
+type OptionalDate struct {
+	date Date
+	set  bool
+}
+
+func (d *OptionalDate) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil && s == "" {
+		*d = OptionalDate{}
+		return nil
+	}
+	if err := d.date.UnmarshalJSON(data); err != nil {
+		return err
+	}
+	d.set = true
+	return d.date.Validate()
+}
+
+// Get returns the date, false when empty.
+func (d OptionalDate) Get() (Date, bool) {
+	return d.date, d.set
+}
+
+func OptionalDateFrom(d Date) OptionalDate {
+	return OptionalDate{
+		date: d,
+		set:  true,
+	}
+}
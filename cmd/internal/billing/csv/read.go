@@ -1,10 +1,13 @@
 package csv
 
 import (
+	"encoding"
 	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"reflect"
 	"strconv"
 	"strings"
 
@@ -15,56 +18,258 @@ type Validator interface {
 	Validate() error
 }
 
-// ReadAll converts a CSV file into a list of T structs (all defined
-// above), where the first CSV row matches field names.  This is done
-// via an intermediate JSON representation.
+// ReadFile reads a CSV file into a list of T, see Read.
 func ReadFile[T Validator](name string, fs afero.Fs) ([]T, error) {
 	f, err := fs.Open(name)
 	if err != nil {
 		return nil, fmt.Errorf("open %s: %w", name, err)
 	}
+	defer f.Close()
 	return Read[T](name, f)
 }
 
+// Read decodes CSV rows into a list of T, where the first row names
+// the columns.  Each column is a field of T, named by the field name
+// or its csv tag, ignoring spaces and case in the header.  The tag
+// options are:
+//
+//   - "optional": the column may be omitted, and an empty cell is
+//     the zero value.
+//   - "default=value": the value of an omitted column or empty cell,
+//     implies optional.
+//
+// A field tagged "-" is not read.  Other columns are required, and a
+// column without a field is an error.  A cell is decoded by the
+// field's type: as text by an encoding.TextUnmarshaler, as a JSON
+// string by a json.Unmarshaler, otherwise as a string, integer,
+// float, or boolean.  Each row is validated.  Errors are reported as
+// file:line:column, all of the file's errors at once.
 func Read[T Validator](name string, file io.Reader) ([]T, error) {
-	read, err := csv.NewReader(file).ReadAll()
+	cols, err := columns(reflect.TypeFor[T]())
 	if err != nil {
-		return nil, fmt.Errorf("read csv %s: %w", name, err)
+		return nil, err
 	}
-	if len(read) < 2 {
+
+	r := csv.NewReader(file)
+	r.FieldsPerRecord = -1
+
+	legend, err := r.Read()
+	if err == io.EOF {
 		return nil, fmt.Errorf("not enough rows: %s", name)
 	}
-	legend := read[0]
-	for i := range legend {
-		legend[i] = strings.ReplaceAll(legend[i], " ", "")
+	if err != nil {
+		return nil, fmt.Errorf("read csv %s: %w", name, err)
 	}
-	rows := read[1:]
-	var ret []T
-	for _, row := range rows {
-		xing := map[string]interface{}{}
-		for i, v := range row {
-			key := legend[i]
-			if vx, err := strconv.ParseUint(v, 10, 64); err == nil {
-				xing[key] = vx
-			} else if vx, err := strconv.ParseFloat(v, 64); err == nil {
-				xing[key] = vx
-			} else {
-				xing[key] = v
+
+	var errs []error
+	report := func(line, col int, format string, args ...any) {
+		pos := fmt.Sprintf("%s:%d", name, line)
+		if col != 0 {
+			pos += fmt.Sprintf(":%d", col)
+		}
+		errs = append(errs, fmt.Errorf("%s: %s", pos, fmt.Sprintf(format, args...)))
+	}
+
+	// byIndex is the column of each header cell.
+	byIndex := make([]*column, len(legend))
+	for i, h := range legend {
+		line, col := r.FieldPos(i)
+		key := strings.ReplaceAll(h, " ", "")
+		var found *column
+		for j := range cols {
+			if strings.EqualFold(cols[j].name, key) {
+				found = &cols[j]
 			}
 		}
-		data, err := json.Marshal(xing)
+		switch {
+		case found == nil:
+			report(line, col, "unknown column %q", h)
+		case found.header != "":
+			report(line, col, "duplicate column %q", h)
+		default:
+			found.header = h
+			byIndex[i] = found
+		}
+	}
+	headerLine, _ := r.FieldPos(0)
+	for _, c := range cols {
+		if c.header == "" && !c.optional {
+			report(headerLine, 0, "missing column %q", c.name)
+		}
+	}
+
+	var ret []T
+	for {
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		}
 		if err != nil {
-			return nil, fmt.Errorf("to json %w", err)
+			return nil, errors.Join(append(errs, fmt.Errorf("read csv %s: %w", name, err))...)
+		}
+		line, _ := r.FieldPos(0)
+		if len(row) != len(legend) {
+			report(line, 0, "%d fields, expected %d", len(row), len(legend))
+			continue
 		}
+
 		var out T
-		if err := json.Unmarshal(data, &out); err != nil {
-			return nil, fmt.Errorf("from json %q %w", string(data), err)
+		v := reflect.ValueOf(&out).Elem()
+		rowErrs := len(errs)
+
+		// Omitted columns take their default.
+		for _, c := range cols {
+			if c.header == "" && c.hasDefault {
+				if err := decode(v.FieldByIndex(c.index), c.def); err != nil {
+					report(line, 0, "%s default: %v", c.name, err)
+				}
+			}
+		}
+		for i, cell := range row {
+			c := byIndex[i]
+			if c == nil {
+				continue
+			}
+			if strings.TrimSpace(cell) == "" && c.optional {
+				if !c.hasDefault {
+					continue
+				}
+				cell = c.def
+			}
+			if err := decode(v.FieldByIndex(c.index), cell); err != nil {
+				_, col := r.FieldPos(i)
+				report(line, col, "%s: %v", c.header, err)
+			}
+		}
+		if len(errs) != rowErrs {
+			continue
 		}
 		if err := out.Validate(); err != nil {
-			return nil, fmt.Errorf("row %s: %w", row, err)
+			report(line, 0, "%v", err)
+			continue
 		}
 		ret = append(ret, out)
-
+	}
+	if len(errs) != 0 {
+		return nil, errors.Join(errs...)
+	}
+	if len(ret) == 0 {
+		return nil, fmt.Errorf("not enough rows: %s", name)
 	}
 	return ret, nil
 }
+
+// column is a field of the row type.
+type column struct {
+	name       string
+	index      []int
+	optional   bool
+	hasDefault bool
+	def        string
+
+	// header is the column's header cell, empty when omitted.
+	header string
+}
+
+var (
+	textType = reflect.TypeFor[encoding.TextUnmarshaler]()
+	jsonType = reflect.TypeFor[json.Unmarshaler]()
+)
+
+// columns returns the columns of a row type.
+func columns(t reflect.Type) ([]column, error) {
+	var cols []column
+	for _, f := range reflect.VisibleFields(t) {
+		if !f.IsExported() || f.Anonymous {
+			continue
+		}
+		tag := f.Tag.Get("csv")
+		if tag == "-" {
+			continue
+		}
+		c := column{
+			name:  f.Name,
+			index: f.Index,
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if name != "" {
+			c.name = strings.ReplaceAll(name, " ", "")
+		}
+		for _, opt := range strings.Split(opts, ",") {
+			switch {
+			case opt == "":
+			case opt == "optional":
+				c.optional = true
+			case strings.HasPrefix(opt, "default="):
+				c.optional = true
+				c.hasDefault = true
+				c.def = strings.TrimPrefix(opt, "default=")
+			default:
+				return nil, fmt.Errorf("%s.%s: unknown csv tag option %q", t.Name(), f.Name, opt)
+			}
+		}
+		if !decodable(f.Type) {
+			return nil, fmt.Errorf("%s.%s: cannot decode %s", t.Name(), f.Name, f.Type)
+		}
+		cols = append(cols, c)
+	}
+	return cols, nil
+}
+
+func decodable(t reflect.Type) bool {
+	if reflect.PointerTo(t).Implements(textType) || reflect.PointerTo(t).Implements(jsonType) {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// decode sets a field from its cell, see Read.
+func decode(v reflect.Value, cell string) error {
+	switch u := v.Addr().Interface().(type) {
+	case encoding.TextUnmarshaler:
+		return u.UnmarshalText([]byte(cell))
+	case json.Unmarshaler:
+		data, err := json.Marshal(cell)
+		if err != nil {
+			return err
+		}
+		return u.UnmarshalJSON(data)
+	}
+	text := strings.TrimSpace(cell)
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(cell)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(text)
+		if err != nil {
+			return fmt.Errorf("not a boolean: %q", cell)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(text, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("not an integer: %q", cell)
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(text, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("not a non-negative integer: %q", cell)
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(text, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("not a number: %q", cell)
+		}
+		v.SetFloat(f)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package csv
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testRow struct {
+	AccountName string
+	Address     string
+	Date        Date
+	Count       int
+	Rate        float64      `csv:",optional"`
+	Method      string       `csv:",default=Normal"`
+	Renamed     string       `csv:"Other Name,optional"`
+	Skipped     []string     `csv:"-"`
+	Start       OptionalDate `csv:",optional"`
+}
+
+func (r testRow) Validate() error {
+	if r.Count < 0 {
+		return fmt.Errorf("count cannot be negative")
+	}
+	return nil
+}
+
+func TestRead(t *testing.T) {
+	rows, err := Read[testRow]("<input>", bytes.NewBufferString(`Account Name,Address,Date,Count,Rate,Other Name
+1201,12,4/1/2025,3,0.5,x
+0042,"1 Road",4/2/2025,0,,
+`))
+	require.NoError(t, err)
+	require.Equal(t, 2, len(rows))
+
+	// Numeric text is kept as written.
+	require.Equal(t, "1201", rows[0].AccountName)
+	require.Equal(t, "12", rows[0].Address)
+	require.Equal(t, "0042", rows[1].AccountName)
+
+	require.Equal(t, 3, rows[0].Count)
+	require.Equal(t, 0.5, rows[0].Rate)
+	require.Equal(t, 0.0, rows[1].Rate)
+	require.Equal(t, "x", rows[0].Renamed)
+
+	// The omitted Method column takes its default.
+	require.Equal(t, "Normal", rows[0].Method)
+	_, ok := rows[0].Start.Get()
+	require.False(t, ok)
+
+	// An empty cell takes the default.
+	rows, err = Read[testRow]("<input>", bytes.NewBufferString(`Account Name,Address,Date,Count,Method
+A,B,4/1/2025,1,
+A,B,4/1/2025,1,Metered
+`))
+	require.NoError(t, err)
+	require.Equal(t, "Normal", rows[0].Method)
+	require.Equal(t, "Metered", rows[1].Method)
+}
+
+func TestReadErrors(t *testing.T) {
+	// Every error is reported, with its position.
+	_, err := Read[testRow]("in.csv", bytes.NewBufferString(`Account Name,Date,Count,Color
+A,4/1/2025,x,blue
+B,13/1/2025,1,red
+C,4/1/2025,-1,green
+D,4/1/2025
+`))
+	require.EqualError(t, err, `in.csv:1:25: unknown column "Color"
+in.csv:1: missing column "Address"
+in.csv:2:12: Count: not an integer: "x"
+in.csv:3:3: Date: parsing time "13/1/2025": month out of range
+in.csv:4: count cannot be negative
+in.csv:5: 2 fields, expected 4`)
+
+	_, err = Read[testRow]("in.csv", bytes.NewBufferString(`Account Name,Address,Date,Count,Count
+`))
+	require.ErrorContains(t, err, `in.csv:1:33: duplicate column "Count"`)
+
+	_, err = Read[testRow]("in.csv", bytes.NewBufferString(`Account Name,Address,Date,Count
+`))
+	require.EqualError(t, err, "not enough rows: in.csv")
+
+	_, err = Read[badRow]("in.csv", bytes.NewBufferString("List\na\n"))
+	require.EqualError(t, err, "badRow.List: cannot decode []string")
+}
+
+type badRow struct {
+	List []string
+}
+
+func (badRow) Validate() error { return nil }
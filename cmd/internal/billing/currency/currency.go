@@ -12,7 +12,7 @@ import (
 	"gopkg.in/yaml.v3"
 )
 
-var dollarsAndCentsRe = regexp.MustCompile(`\$(\d+(?:,\d\d\d)*)\.(\d\d)`)
+var dollarsAndCentsRe = regexp.MustCompile(`(-)?\$(\d+(?:,\d\d\d)*)\.(\d\d)`)
 
 type Amount struct {
 	units int64
@@ -76,6 +76,11 @@ func (a *Amount) UnmarshalYAML(node *yaml.Node) error {
 	return a.UnmarshalJSON([]byte(fmt.Sprintf("%q", node.Value)))
 }
 
+// MarshalJSON writes the displayed amount, which UnmarshalJSON reads.
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return json.Marshal(a.Display())
+}
+
 func (a *Amount) UnmarshalJSON(data []byte) error {
 	var s string
 	if err := json.Unmarshal(data, &s); err != nil {
@@ -87,14 +92,17 @@ func (a *Amount) UnmarshalJSON(data []byte) error {
 		return fmt.Errorf("not a currency amount: %v", s)
 	}
 
-	dollars, err := strconv.Atoi(strings.ReplaceAll(parts[1], ",", ""))
+	dollars, err := strconv.Atoi(strings.ReplaceAll(parts[2], ",", ""))
 	if err != nil {
 		return err
 	}
-	cents, err := strconv.Atoi(parts[2])
+	cents, err := strconv.Atoi(parts[3])
 	if err != nil {
 		return err
 	}
 	a.units = int64(dollars*100 + cents)
+	if parts[1] != "" {
+		a.units = -a.units
+	}
 	return nil
 }
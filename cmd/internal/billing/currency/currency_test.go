@@ -3,6 +3,7 @@ package currency
 import (
 	"encoding/json"
 	"fmt"
+	"math/big"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -14,10 +15,12 @@ func quoteBytes(s string) []byte {
 
 func TestCurrencyGood(t *testing.T) {
 	for ok, val := range map[string]int64{
-		"$1.00":     100,
-		"$1,000.00": 100000,
-		"$1,001.01": 100101,
-		"$3333.01":  333301,
+		"$1.00":      100,
+		"$1,000.00":  100000,
+		"$1,001.01":  100101,
+		"$3333.01":   333301,
+		"-$1.00":     -100,
+		"-$1,001.01": -100101,
 	} {
 		var a Amount
 
@@ -42,3 +45,76 @@ func TestCurrencyBad(t *testing.T) {
 		require.Error(t, err)
 	}
 }
+
+func TestCurrencyRoundTrip(t *testing.T) {
+	for _, units := range []int64{0, 1, 100101, -100101} {
+		data, err := json.Marshal(Units(units))
+		require.NoError(t, err)
+
+		var a Amount
+		require.NoError(t, json.Unmarshal(data, &a))
+		require.Equal(t, units, a.Units())
+	}
+}
+
+func TestRounding(t *testing.T) {
+	for _, tc := range []struct {
+		num, den int64
+		mode     Rounding
+		want     int64
+	}{
+		{5, 2, RoundDown, 2},
+		{5, 2, RoundHalfUp, 3},
+		{5, 2, RoundHalfEven, 2},
+		{7, 2, RoundHalfEven, 4},
+		{5, 2, RoundUp, 3},
+		{21, 10, RoundHalfUp, 2},
+		{21, 10, RoundUp, 3},
+		{-5, 2, RoundDown, -2},
+		{-5, 2, RoundHalfUp, -3},
+		{-5, 2, RoundUp, -3},
+		{4, 2, RoundUp, 2},
+	} {
+		got := tc.mode.Round(big.NewRat(tc.num, tc.den))
+		require.Equal(t, tc.want, got.Units(), "%d/%d %s", tc.num, tc.den, tc.mode)
+	}
+
+	var r Rounding
+	require.NoError(t, json.Unmarshal(quoteBytes("halfeven"), &r))
+	require.Equal(t, RoundHalfEven, r)
+	require.Error(t, json.Unmarshal(quoteBytes("nearest"), &r))
+}
+
+func TestExactMargin(t *testing.T) {
+	// 0.1 is exact, not its binary approximation.
+	require.Equal(t, big.NewRat(1, 10), Decimal(0.1))
+
+	// $1,234.50 with 10% margin is exactly $1,357.95.
+	total := Units(123450).Mul(new(big.Rat).Add(big.NewRat(1, 1), Decimal(0.1)), RoundDown)
+	require.Equal(t, int64(135795), total.Units())
+
+	require.Equal(t, int64(33), Units(100).Mul(big.NewRat(1, 3), RoundHalfUp).Units())
+	require.Equal(t, int64(34), Units(100).Mul(big.NewRat(1, 3), RoundUp).Units())
+}
+
+func TestAllocate(t *testing.T) {
+	// $1.00 in three equal parts leaves one cent, assigned in
+	// the given order.
+	a := Allocate(Units(100), []int64{1, 1, 1}, []int{2, 0, 1})
+	require.Equal(t, []Amount{Units(33), Units(33), Units(34)}, a.Parts)
+	require.Equal(t, []int{2}, a.Leftover)
+	require.Equal(t, 1, a.Received(2))
+	require.Equal(t, 0, a.Received(0))
+
+	// By largest remainder: 1000/7 shares of 1, 2, 4 are
+	// 142.86, 285.71, 571.43.
+	a = Allocate(Units(1000), []int64{1, 2, 4}, nil)
+	require.Equal(t, []Amount{Units(143), Units(286), Units(571)}, a.Parts)
+	require.Equal(t, []int{0, 1}, a.Leftover)
+
+	// Zero weights receive nothing, and the parts sum to the
+	// total.
+	a = Allocate(Units(101), []int64{0, 1, 1}, []int{0, 1, 2})
+	require.Equal(t, []Amount{Units(0), Units(51), Units(50)}, a.Parts)
+	require.Equal(t, Units(101), Sum(a.Parts...))
+}
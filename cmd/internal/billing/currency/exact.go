@@ -0,0 +1,173 @@
+package currency
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Rounding is how an exact amount is rounded to the cent.
+type Rounding string
+
+const (
+	// RoundDown truncates toward zero, the default.
+	RoundDown Rounding = "Down"
+
+	// RoundHalfUp rounds to the nearest cent, ties away from
+	// zero.
+	RoundHalfUp Rounding = "HalfUp"
+
+	// RoundHalfEven rounds to the nearest cent, ties to the
+	// even cent.
+	RoundHalfEven Rounding = "HalfEven"
+
+	// RoundUp rounds away from zero.
+	RoundUp Rounding = "Up"
+)
+
+func (r *Rounding) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	for _, mode := range []Rounding{RoundDown, RoundHalfUp, RoundHalfEven, RoundUp} {
+		if strings.EqualFold(s, string(mode)) {
+			*r = mode
+			return nil
+		}
+	}
+	if s == "" {
+		*r = RoundDown
+		return nil
+	}
+	return fmt.Errorf("invalid rounding: %q", s)
+}
+
+// Round rounds an exact number of cents.
+func (r Rounding) Round(cents *big.Rat) Amount {
+	num, den := cents.Num(), cents.Denom()
+	quo, rem := new(big.Int).QuoRem(num, den, new(big.Int))
+	if rem.Sign() == 0 {
+		return Units(quo.Int64())
+	}
+	away := big.NewInt(int64(num.Sign()))
+
+	// cmp compares twice the remainder with the denominator.
+	cmp := new(big.Int).Abs(new(big.Int).Lsh(rem, 1)).Cmp(den)
+	switch r {
+	case RoundUp:
+		quo.Add(quo, away)
+	case RoundHalfUp:
+		if cmp >= 0 {
+			quo.Add(quo, away)
+		}
+	case RoundHalfEven:
+		if cmp > 0 || (cmp == 0 && quo.Bit(0) == 1) {
+			quo.Add(quo, away)
+		}
+	}
+	return Units(quo.Int64())
+}
+
+// Rat returns the amount in cents.
+func (a Amount) Rat() *big.Rat {
+	return new(big.Rat).SetInt64(a.units)
+}
+
+// Decimal returns a number as entered, e.g., a margin of 0.1 is
+// exactly 1/10, not its nearest binary fraction.
+func Decimal(f float64) *big.Rat {
+	r, _ := new(big.Rat).SetString(strconv.FormatFloat(f, 'g', -1, 64))
+	return r
+}
+
+// Mul multiplies the amount by an exact ratio, rounded.
+func (a Amount) Mul(ratio *big.Rat, mode Rounding) Amount {
+	return mode.Round(new(big.Rat).Mul(a.Rat(), ratio))
+}
+
+// Allocation is an amount divided among parties in proportion to
+// their weights.  Each party receives its exact share rounded down
+// to the cent, and the leftover cents, fewer than the number of
+// parties, are assigned one at a time.
+type Allocation struct {
+	Total   Amount
+	Weights []int64
+
+	// Exact is each party's share in cents, Parts the amount
+	// assigned.
+	Exact []*big.Rat
+	Parts []Amount
+
+	// Leftover lists the party assigned each leftover cent, in
+	// order.
+	Leftover []int
+}
+
+// Allocate divides a non-negative amount by weight.  The leftover
+// cents go to the parties with non-zero weight in the given order,
+// or in order of the largest fractional remainder, then first party,
+// when order is nil.
+func Allocate(total Amount, weights []int64, order []int) Allocation {
+	a := Allocation{
+		Total:   total,
+		Weights: weights,
+		Exact:   make([]*big.Rat, len(weights)),
+		Parts:   make([]Amount, len(weights)),
+	}
+	var sum int64
+	for _, w := range weights {
+		sum += w
+	}
+	if sum == 0 {
+		for i := range weights {
+			a.Exact[i] = new(big.Rat)
+		}
+		return a
+	}
+	left := total.units
+	for i, w := range weights {
+		a.Exact[i] = new(big.Rat).Mul(total.Rat(), big.NewRat(w, sum))
+		a.Parts[i] = RoundDown.Round(a.Exact[i])
+		left -= a.Parts[i].units
+	}
+	if order == nil {
+		for i, w := range weights {
+			if w != 0 {
+				order = append(order, i)
+			}
+		}
+		rem := func(i int) *big.Rat {
+			return new(big.Rat).Sub(a.Exact[i], a.Parts[i].Rat())
+		}
+		sort.SliceStable(order, func(x, y int) bool {
+			return rem(order[x]).Cmp(rem(order[y])) > 0
+		})
+	}
+	for _, i := range order {
+		if left == 0 {
+			break
+		}
+		if weights[i] == 0 {
+			continue
+		}
+		a.Parts[i].units++
+		a.Leftover = append(a.Leftover, i)
+		left--
+	}
+	return a
+}
+
+// Received returns the leftover cents assigned to a party.
+func (a Allocation) Received(party int) int {
+	n := 0
+	for _, i := range a.Leftover {
+		if i == party {
+			n++
+		}
+	}
+	return n
+}
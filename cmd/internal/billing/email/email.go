@@ -0,0 +1,227 @@
+package email
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"strings"
+	"time"
+)
+
+// Message is a statement email with a PDF attachment.
+type Message struct {
+	// From and To are RFC 5322 addresses, e.g.,
+	// "Name <name@example.com>".
+	From string
+	To   string
+
+	Date    time.Time
+	Subject string
+
+	// Text is the plain-text body.  HTML is optional, when set
+	// the two are sent as alternatives.
+	Text string
+	HTML string
+
+	// AttachmentName is the file name of the PDF.
+	AttachmentName string
+	Attachment     []byte
+}
+
+const base64LineLength = 76
+
+// Write formats the message per RFC 5322, with a multipart/mixed
+// body holding the text and the attachment.
+func (m Message) Write(w io.Writer) error {
+	from, err := mail.ParseAddress(m.From)
+	if err != nil {
+		return fmt.Errorf("from address: %w", err)
+	}
+	to, err := mail.ParseAddress(m.To)
+	if err != nil {
+		return fmt.Errorf("to address: %w", err)
+	}
+
+	var buf bytes.Buffer
+	mixed := multipart.NewWriter(&buf)
+
+	header := []string{
+		"From: " + from.String(),
+		"To: " + to.String(),
+		"Subject: " + mime.QEncoding.Encode("utf-8", m.Subject),
+		"Date: " + m.Date.Format(time.RFC1123Z),
+		"Message-ID: " + m.messageID(from.Address),
+		"MIME-Version: 1.0",
+		"Content-Type: multipart/mixed; boundary=" + mixed.Boundary(),
+	}
+	if _, err := io.WriteString(&buf, strings.Join(header, "\r\n")+"\r\n\r\n"); err != nil {
+		return err
+	}
+
+	if err := m.writeBody(mixed); err != nil {
+		return err
+	}
+
+	part, err := mixed.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {mime.FormatMediaType("application/pdf", map[string]string{"name": m.AttachmentName})},
+		"Content-Transfer-Encoding": {"base64"},
+		"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": m.AttachmentName})},
+	})
+	if err != nil {
+		return err
+	}
+	enc := base64.StdEncoding.EncodeToString(m.Attachment)
+	for len(enc) > 0 {
+		n := min(len(enc), base64LineLength)
+		if _, err := io.WriteString(part, enc[:n]+"\r\n"); err != nil {
+			return err
+		}
+		enc = enc[n:]
+	}
+	if err := mixed.Close(); err != nil {
+		return err
+	}
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// writeBody writes the text, or the text and HTML as alternatives.
+func (m Message) writeBody(mixed *multipart.Writer) error {
+	if m.HTML == "" {
+		return writeText(mixed, "text/plain", m.Text)
+	}
+	var alt bytes.Buffer
+	aw := multipart.NewWriter(&alt)
+	if err := writeText(aw, "text/plain", m.Text); err != nil {
+		return err
+	}
+	if err := writeText(aw, "text/html", m.HTML); err != nil {
+		return err
+	}
+	if err := aw.Close(); err != nil {
+		return err
+	}
+	part, err := mixed.CreatePart(textproto.MIMEHeader{
+		"Content-Type": {"multipart/alternative; boundary=" + aw.Boundary()},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = part.Write(alt.Bytes())
+	return err
+}
+
+func writeText(mw *multipart.Writer, mediaType, body string) error {
+	part, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {mediaType + "; charset=utf-8"},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return err
+	}
+	qp := quotedprintable.NewWriter(part)
+	if _, err := io.WriteString(qp, body); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+// messageID is derived from the recipient and subject, not the
+// date, so that rewriting or resending a statement does not change
+// it.
+func (m Message) messageID(from string) string {
+	domain := from[strings.LastIndex(from, "@")+1:]
+	sum := sha256.Sum256([]byte(m.To + "\n" + m.Subject))
+	return fmt.Sprintf("<%x@%s>", sum[:12], domain)
+}
+
+// Sender delivers messages to an SMTP server.
+type Sender struct {
+	// Addr is the server's host:port.
+	Addr string
+
+	// Auth is optional.
+	Auth smtp.Auth
+}
+
+// Send delivers the message to its recipient.
+func (s Sender) Send(m Message) error {
+	from, err := mail.ParseAddress(m.From)
+	if err != nil {
+		return fmt.Errorf("from address: %w", err)
+	}
+	to, err := mail.ParseAddress(m.To)
+	if err != nil {
+		return fmt.Errorf("to address: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := m.Write(&buf); err != nil {
+		return err
+	}
+	return smtp.SendMail(s.Addr, s.Auth, from.Address, []string{to.Address}, buf.Bytes())
+}
+
+// Delivery statuses.
+const (
+	Sent         = "sent"
+	Written      = "written"
+	NoAddress    = "no address"
+	Consolidated = "consolidated"
+)
+
+// Delivery is one row of the delivery log.
+type Delivery struct {
+	Date        time.Time
+	AccountName string
+	To          string
+	Statement   string
+	File        string
+	Status      string
+}
+
+var logHeader = []string{"Date", "Account Name", "To", "Statement", "File", "Status"}
+
+// AppendLog appends deliveries to a CSV log, starting a new file with
+// the header row.
+func AppendLog(name string, rows []Delivery) error {
+	_, err := os.Stat(name)
+	exists := err == nil
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	f, err := os.OpenFile(name, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	cw := csv.NewWriter(f)
+	if !exists {
+		_ = cw.Write(logHeader)
+	}
+	for _, d := range rows {
+		_ = cw.Write([]string{
+			d.Date.Format(time.RFC3339),
+			d.AccountName,
+			d.To,
+			d.Statement,
+			d.File,
+			d.Status,
+		})
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,197 @@
+package email
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testMessage(html string) Message {
+	return Message{
+		From:           "Water Company <billing@water.com>",
+		To:             "Mister Miller <miller@example.com>",
+		Date:           time.Date(2025, 10, 15, 9, 0, 0, 0, time.UTC),
+		Subject:        "Statement 2025-Sep",
+		Text:           "Amount due: $400.00\n",
+		HTML:           html,
+		AttachmentName: "House2.pdf",
+		Attachment:     bytes.Repeat([]byte("%PDF-1.3 "), 20),
+	}
+}
+
+// parts reads the MIME parts of a message body.
+func parts(t *testing.T, contentType string, body io.Reader) map[string][]byte {
+	mt, params, err := mime.ParseMediaType(contentType)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(mt, "multipart/"))
+
+	r := map[string][]byte{}
+	mr := multipart.NewReader(body, params["boundary"])
+	for {
+		p, err := mr.NextRawPart()
+		if err == io.EOF {
+			return r
+		}
+		require.NoError(t, err)
+		ct := p.Header.Get("Content-Type")
+		if strings.HasPrefix(ct, "multipart/") {
+			for k, v := range parts(t, ct, p) {
+				r[k] = v
+			}
+			continue
+		}
+		var data []byte
+		switch p.Header.Get("Content-Transfer-Encoding") {
+		case "base64":
+			data, err = io.ReadAll(base64.NewDecoder(base64.StdEncoding, p))
+		default:
+			data, err = io.ReadAll(quotedprintable.NewReader(p))
+		}
+		require.NoError(t, err)
+		mt, _, err := mime.ParseMediaType(ct)
+		require.NoError(t, err)
+		r[mt] = data
+	}
+}
+
+func TestWrite(t *testing.T) {
+	for _, html := range []string{"", "<p>Amount due: $400.00</p>"} {
+		m := testMessage(html)
+		var buf bytes.Buffer
+		require.NoError(t, m.Write(&buf))
+
+		msg, err := mail.ReadMessage(&buf)
+		require.NoError(t, err)
+		require.Equal(t, `"Water Company" <billing@water.com>`, msg.Header.Get("From"))
+		require.Equal(t, `"Mister Miller" <miller@example.com>`, msg.Header.Get("To"))
+		require.Equal(t, "Statement 2025-Sep", msg.Header.Get("Subject"))
+		require.True(t, strings.HasSuffix(msg.Header.Get("Message-ID"), "@water.com>"))
+
+		date, err := msg.Header.Date()
+		require.NoError(t, err)
+		require.True(t, date.Equal(m.Date))
+
+		got := parts(t, msg.Header.Get("Content-Type"), msg.Body)
+		require.Equal(t, "Amount due: $400.00\r\n", string(got["text/plain"]))
+		require.Equal(t, m.Attachment, got["application/pdf"])
+		if html == "" {
+			require.Equal(t, 2, len(got))
+		} else {
+			require.Equal(t, html, string(got["text/html"]))
+		}
+	}
+
+	m := testMessage("")
+	m.To = "nobody"
+	require.Error(t, m.Write(io.Discard))
+}
+
+func TestMessageID(t *testing.T) {
+	m := testMessage("")
+	later := m
+	later.Date = m.Date.AddDate(0, 1, 0)
+	require.Equal(t, m.messageID("billing@water.com"), later.messageID("billing@water.com"))
+
+	other := m
+	other.Subject = "Statement 2025-Oct"
+	require.NotEqual(t, m.messageID("billing@water.com"), other.messageID("billing@water.com"))
+}
+
+// fakeServer accepts one SMTP session and returns the recipients and
+// message data.
+func fakeServer(t *testing.T) (string, <-chan []string, <-chan []byte) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { l.Close() })
+
+	rcpts := make(chan []string, 1)
+	data := make(chan []byte, 1)
+
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			return
+		}
+		tc := textproto.NewConn(c)
+		defer tc.Close()
+
+		var to []string
+		_ = tc.PrintfLine("220 localhost ESMTP")
+		for {
+			line, err := tc.ReadLine()
+			if err != nil {
+				return
+			}
+			cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+			switch cmd {
+			case "EHLO", "HELO":
+				_ = tc.PrintfLine("250 localhost")
+			case "RCPT":
+				to = append(to, line)
+				_ = tc.PrintfLine("250 OK")
+			case "DATA":
+				_ = tc.PrintfLine("354 Go ahead")
+				d, err := tc.ReadDotBytes()
+				if err != nil {
+					return
+				}
+				rcpts <- to
+				data <- d
+				_ = tc.PrintfLine("250 OK")
+			case "QUIT":
+				_ = tc.PrintfLine("221 Bye")
+				return
+			default:
+				_ = tc.PrintfLine("250 OK")
+			}
+		}
+	}()
+	return l.Addr().String(), rcpts, data
+}
+
+func TestSend(t *testing.T) {
+	addr, rcpts, data := fakeServer(t)
+
+	m := testMessage("")
+	require.NoError(t, Sender{Addr: addr}.Send(m))
+
+	require.Equal(t, []string{"RCPT TO:<miller@example.com>"}, <-rcpts)
+
+	msg, err := mail.ReadMessage(bytes.NewReader(<-data))
+	require.NoError(t, err)
+	require.Equal(t, "Statement 2025-Sep", msg.Header.Get("Subject"))
+	got := parts(t, msg.Header.Get("Content-Type"), msg.Body)
+	require.Equal(t, m.Attachment, got["application/pdf"])
+}
+
+func TestAppendLog(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "log.csv")
+	date := time.Date(2025, 10, 15, 9, 0, 0, 0, time.UTC)
+
+	require.NoError(t, AppendLog(name, []Delivery{
+		{date, "House2", "miller@example.com", "2025-Sep", "House2.eml", Sent},
+	}))
+	require.NoError(t, AppendLog(name, []Delivery{
+		{date, "House3", "", "2025-Sep", "", NoAddress},
+	}))
+
+	data, err := os.ReadFile(name)
+	require.NoError(t, err)
+	require.Equal(t, `Date,Account Name,To,Statement,File,Status
+2025-10-15T09:00:00Z,House2,miller@example.com,2025-Sep,House2.eml,sent
+2025-10-15T09:00:00Z,House3,,2025-Sep,,no address
+`, string(data))
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"strings"
 
+	billingbool "github.com/jmacd/caspar.water/cmd/internal/billing/bool"
 	"github.com/jmacd/caspar.water/cmd/internal/billing/csv"
 	"github.com/jmacd/caspar.water/cmd/internal/billing/currency"
 	"github.com/jmacd/caspar.water/cmd/internal/billing/period"
@@ -32,22 +33,72 @@ type Cycle struct {
 	// BillDate is the date the statement was prepared.
 	BillDate csv.Date
 
-	// Method describes the billing method, values include:
+	// Method describes the billing method, Normal by default;
+	// values include:
 	// - Baseline: the initial condition has no reserve.
 	// - FirstAdjustment: a billing cycle where the CommCtr
 	//   doubles in weight and the first cost-of-living
 	//   adjustment is applied.
-	Method Method
+	// - Metered: each connection pays BaseCharge plus a
+	//   volumetric charge for its meter readings.
+	Method Method `csv:",default=Normal"`
+
+	// BaseCharge is the fixed charge per unit of connection
+	// weight in a Metered cycle.
+	BaseCharge currency.Amount `csv:",optional"`
 
 	// Margin is the target ratio for earnings above cost.
-	Margin float64
+	Margin float64 `csv:",optional"`
 
-	// EffectiveConnections is the denominator
-	EffectiveConnections int
+	// EffectiveConnections is the denominator, the sum of the
+	// weights of the accounts billed for the period.  It is
+	// derived from the weights when zero or the column is
+	// omitted; an entered value must match.
+	EffectiveConnections int `csv:",optional"`
 
 	// Inactive is a comma/whitespace separated list of
 	// accounts that are inactive for the period.
-	Inactive Inactive
+	Inactive Inactive `csv:",optional"`
+
+	// Revision indicates that the row restates the actual
+	// expenses of an earlier, estimated row for the same period.
+	Revision billingbool.Bool `csv:",optional"`
+
+	// Projection, when set, computes the expenses from prior
+	// cycles, which are entered as zero.
+	Projection Projection `csv:",optional"`
+
+	// Service is optional, it names an additional service,
+	// e.g., Septic, billed to the accounts that list it, see
+	// user.User.Services.  Rows without a service are the
+	// business's primary service.
+	Service string `csv:",optional"`
+
+	// Itemized indicates that the expenses are summed from the
+	// expense items, see Itemize, and are entered as zero.
+	Itemized billingbool.Bool `csv:",optional"`
+
+	// Items are the expense items of an Itemized row.
+	Items []Item `csv:"-"`
+
+	// Capital is the share of expense-funded capital purchases,
+	// see package capital.
+	Capital currency.Amount `csv:"-"`
+
+	// Weights are the connection weights of the accounts billed
+	// for the period, by account name, see package logic.
+	Weights map[string]int `csv:"-"`
+}
+
+// Total is the sum of the cycle's expenses.
+func (c Cycle) Total() currency.Amount {
+	return currency.Sum(
+		c.Operations,
+		c.Utilities,
+		c.Taxes,
+		c.Insurance,
+		c.Capital,
+	)
 }
 
 type Method string
@@ -55,6 +106,7 @@ type Method string
 const (
 	NormalMethod       Method = "Normal"
 	IntroductoryMethod Method = "Introductory"
+	MeteredMethod      Method = "Metered"
 )
 
 func (m *Method) UnmarshalJSON(data []byte) error {
@@ -69,12 +121,60 @@ func (m *Method) UnmarshalJSON(data []byte) error {
 		*m = NormalMethod
 	case "introductory":
 		*m = IntroductoryMethod
+	case "metered":
+		*m = MeteredMethod
 	default:
 		return fmt.Errorf("invalid method: %q", str)
 	}
 	return nil
 }
 
+// Projection names a method of projecting expenses from prior
+// cycles.
+type Projection string
+
+const (
+	NoProjection       Projection = ""
+	TrailingAverage    Projection = "TrailingAverage"
+	SamePeriodLastYear Projection = "SamePeriodLastYear"
+	LinearTrend        Projection = "LinearTrend"
+)
+
+func (p *Projection) UnmarshalJSON(data []byte) error {
+	var s string
+	err := json.Unmarshal(data, &s)
+	if err != nil {
+		return err
+	}
+	str := strings.NewReplacer(" ", "", "-", "").Replace(strings.ToLower(s))
+	switch str {
+	case "":
+		*p = NoProjection
+	case "trailingaverage":
+		*p = TrailingAverage
+	case "sameperiodlastyear":
+		*p = SamePeriodLastYear
+	case "lineartrend":
+		*p = LinearTrend
+	default:
+		return fmt.Errorf("invalid projection: %q", s)
+	}
+	return nil
+}
+
+// Display describes the method on statements.
+func (p Projection) Display() string {
+	switch p {
+	case TrailingAverage:
+		return "trailing average"
+	case SamePeriodLastYear:
+		return "same period last year"
+	case LinearTrend:
+		return "linear trend"
+	}
+	return string(p)
+}
+
 type Inactive []string
 
 func (in *Inactive) UnmarshalJSON(data []byte) error {
@@ -97,40 +197,61 @@ func (in *Inactive) Contains(user user.User) bool {
 	return false
 }
 
-func SplitAnnual(cycles []Cycle) error {
-	// Every other period we split the taxes and insurance, which
-	// are yearly expenses paid during the October-March period.
-	for cycleNo := 0; cycleNo < len(cycles); cycleNo += 2 {
-		yearlyTax := cycles[cycleNo].Taxes.Split(2)
-		yearlyIns := cycles[cycleNo].Insurance.Split(2)
-
-		cycles[cycleNo].Taxes = yearlyTax[0]
-		cycles[cycleNo].Insurance = yearlyIns[0]
+// SplitAnnual spreads yearly expenses over the periods of a year.
+// Every perYear-th cycle, starting with the first, carries the taxes
+// and insurance paid that year, which are split evenly with the
+// following cycles; those cycles must enter zero for these accounts.
+func SplitAnnual(cycles []Cycle, perYear int) error {
+	for cycleNo := 0; cycleNo < len(cycles); cycleNo += perYear {
+		yearlyTax := cycles[cycleNo].Taxes.Split(perYear)
+		yearlyIns := cycles[cycleNo].Insurance.Split(perYear)
 
-		if len(cycles) > (cycleNo+1) &&
-			(!cycles[cycleNo+1].Taxes.IsZero() ||
-				!cycles[cycleNo+1].Insurance.IsZero()) {
-			return fmt.Errorf("taxes and insurance October-March not handled")
+		for i := 0; i < perYear; i++ {
+			// The final periods will be missing from a
+			// partial year.
+			if cycleNo+i >= len(cycles) {
+				break
+			}
+			if i != 0 &&
+				(!cycles[cycleNo+i].Taxes.IsZero() ||
+					!cycles[cycleNo+i].Insurance.IsZero()) {
+				return fmt.Errorf("taxes and insurance are entered once per year, not handled in cycle %d", cycleNo+i+1)
+			}
+			cycles[cycleNo+i].Taxes = yearlyTax[i]
+			cycles[cycleNo+i].Insurance = yearlyIns[i]
 		}
+	}
+	return nil
+}
 
-		// The final period will be missing every other cycle.
-		if cycleNo+1 < len(cycles) {
-			cycles[cycleNo+1].Taxes = yearlyTax[1]
-			cycles[cycleNo+1].Insurance = yearlyIns[1]
-		}
+// Bind binds the cycle's period to the business's schedule.
+func (c *Cycle) Bind(s period.Schedule) error {
+	p, err := s.Bind(c.PeriodStart)
+	if err != nil {
+		return err
 	}
+	c.PeriodStart = p
 	return nil
 }
 
 func (c Cycle) Validate() error {
-	if err := c.PeriodStart.Validate(); err != nil {
-		return err
+	if c.Projection != NoProjection && c.Itemized {
+		return fmt.Errorf("itemized expenses cannot be projected")
 	}
-	if c.Operations.Units() <= 0 {
-		return fmt.Errorf("expenses cannot be negative")
+	if c.Projection != NoProjection && c.Revision {
+		return fmt.Errorf("a revision cannot be projected")
 	}
-	if c.Utilities.Units() <= 0 {
-		return fmt.Errorf("expenses cannot be negative")
+	if c.Projection != NoProjection || c.Itemized {
+		if !c.Operations.IsZero() || !c.Utilities.IsZero() || !c.Insurance.IsZero() || !c.Taxes.IsZero() {
+			return fmt.Errorf("projected and itemized expenses must be entered as zero")
+		}
+	} else {
+		if c.Operations.Units() <= 0 {
+			return fmt.Errorf("expenses cannot be negative")
+		}
+		if c.Utilities.Units() <= 0 {
+			return fmt.Errorf("expenses cannot be negative")
+		}
 	}
 	if c.Insurance.Units() < 0 {
 		return fmt.Errorf("expenses cannot be negative")
@@ -141,5 +262,8 @@ func (c Cycle) Validate() error {
 	if c.Method == "" {
 		return fmt.Errorf("expenses method is empty")
 	}
+	if c.BaseCharge.Units() < 0 {
+		return fmt.Errorf("base charge cannot be negative")
+	}
 	return nil
 }
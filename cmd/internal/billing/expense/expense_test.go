@@ -0,0 +1,47 @@
+package expense
+
+import (
+	"testing"
+
+	"github.com/jmacd/caspar.water/cmd/internal/billing/currency"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitAnnual(t *testing.T) {
+	cycles := func(taxes ...int64) []Cycle {
+		var r []Cycle
+		for _, tx := range taxes {
+			r = append(r, Cycle{
+				Taxes:     currency.Units(tx),
+				Insurance: currency.Units(2 * tx),
+			})
+		}
+		return r
+	}
+	taxes := func(cs []Cycle) []int64 {
+		var r []int64
+		for _, c := range cs {
+			r = append(r, c.Taxes.Units())
+			require.Equal(t, 2*c.Taxes.Units(), c.Insurance.Units())
+		}
+		return r
+	}
+
+	for _, test := range []struct {
+		perYear int
+		input   []int64
+		expect  []int64
+	}{
+		{1, []int64{100, 200, 300}, []int64{100, 200, 300}},
+		{2, []int64{100, 0, 202, 0, 300}, []int64{50, 50, 101, 101, 150}},
+		{4, []int64{400, 0, 0, 0, 800, 0}, []int64{100, 100, 100, 100, 200, 200}},
+		{12, []int64{1200, 0, 0}, []int64{100, 100, 100}},
+	} {
+		cs := cycles(test.input...)
+		require.NoError(t, SplitAnnual(cs, test.perYear))
+		require.Equal(t, test.expect, taxes(cs))
+	}
+
+	require.Error(t, SplitAnnual(cycles(400, 0, 100, 0), 4))
+	require.Error(t, SplitAnnual(cycles(400, 100), 2))
+}
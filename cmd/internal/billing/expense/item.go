@@ -0,0 +1,179 @@
+package expense
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jmacd/caspar.water/cmd/internal/billing/constant"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/csv"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/currency"
+)
+
+// Category is the expense column an item is summed into.
+type Category string
+
+const (
+	OperationsCategory Category = "Operations"
+	UtilitiesCategory  Category = "Utilities"
+	InsuranceCategory  Category = "Insurance"
+	TaxesCategory      Category = "Taxes"
+)
+
+func (c *Category) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	for _, cat := range []Category{
+		OperationsCategory,
+		UtilitiesCategory,
+		InsuranceCategory,
+		TaxesCategory,
+	} {
+		if strings.EqualFold(strings.TrimSpace(s), string(cat)) {
+			*c = cat
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid expense category: %q", s)
+}
+
+// yearly indicates a category entered once per year.
+func (c Category) yearly() bool {
+	return c == InsuranceCategory || c == TaxesCategory
+}
+
+// Item is one expense transaction, e.g., a receipt or an invoice
+// paid.  Negative amounts are refunds or credits.
+type Item struct {
+	Date     csv.Date
+	Vendor   string
+	Category Category
+	Amount   currency.Amount
+
+	// Description is optional.
+	Description string `csv:",optional"`
+}
+
+func (i Item) Validate() error {
+	if i.Vendor == "" {
+		return fmt.Errorf("expense item requires a vendor")
+	}
+	if i.Category == "" {
+		return fmt.Errorf("expense item requires a category")
+	}
+	if i.Amount.IsZero() {
+		return fmt.Errorf("expense item from %s has no amount", i.Vendor)
+	}
+	return nil
+}
+
+// Itemize sums expense items into the rows marked Itemized.
+// Operations and utilities are summed into the cycle whose period
+// contains the item's date.  Taxes and insurance are entered once
+// per year, see SplitAnnual, so they are summed into the first cycle
+// of the year containing the item's date.  Every row of the year
+// lists the year's taxes and insurance items.  Items dated in a
+// period that has rows but none Itemized are an error; items outside
+// every row's period are ignored.
+func Itemize(rows []Cycle, items []Item, perYear int) error {
+	// Periods in row order, without revisions, as in Revisions.
+	var periods []Cycle
+	position := make([]int, len(rows))
+	itemized := map[int]bool{}
+	for r, row := range rows {
+		position[r] = -1
+		for p, c := range periods {
+			if c.PeriodStart.Starting().Date().Equal(row.PeriodStart.Starting().Date()) {
+				position[r] = p
+			}
+		}
+		if position[r] < 0 {
+			position[r] = len(periods)
+			periods = append(periods, row)
+		}
+		if row.Itemized {
+			itemized[position[r]] = true
+		}
+	}
+
+	find := func(it Item) int {
+		d := it.Date.Date()
+		for p, c := range periods {
+			start := c.PeriodStart.Starting().Date()
+			if it.Category.yearly() {
+				if p%perYear != 0 {
+					continue
+				}
+				if !d.Before(start) && d.Before(start.AddDate(1, 0, 0)) {
+					return p
+				}
+				continue
+			}
+			if !d.Before(start) && !d.After(c.PeriodStart.Closing().Date()) {
+				return p
+			}
+		}
+		return -1
+	}
+
+	byPosition := map[int][]Item{}
+	for _, it := range items {
+		p := find(it)
+		if p < 0 {
+			continue
+		}
+		if !itemized[p] {
+			name := periods[p].PeriodStart.Closing().Date().Format(constant.InvoiceDateLayout)
+			return fmt.Errorf("expense item from %s dated %s is in cycle %s, which is not itemized",
+				it.Vendor, it.Date.Date().Format(constant.CsvLayout), name)
+		}
+		byPosition[p] = append(byPosition[p], it)
+	}
+
+	for r := range rows {
+		row := &rows[r]
+		if !row.Itemized {
+			continue
+		}
+		p := position[r]
+		yearFirst := p - p%perYear
+		name := row.PeriodStart.Closing().Date().Format(constant.InvoiceDateLayout)
+
+		for _, it := range byPosition[p] {
+			row.add(it)
+		}
+		if p != yearFirst {
+			for _, it := range byPosition[yearFirst] {
+				if it.Category.yearly() {
+					row.Items = append(row.Items, it)
+				}
+			}
+		}
+		sort.SliceStable(row.Items, func(i, j int) bool {
+			return row.Items[i].Date.Before(row.Items[j].Date)
+		})
+		if row.Operations.Units() <= 0 || row.Utilities.Units() <= 0 ||
+			row.Insurance.Units() < 0 || row.Taxes.Units() < 0 {
+			return fmt.Errorf("itemized expenses of cycle %s cannot be negative, and require operations and utilities", name)
+		}
+	}
+	return nil
+}
+
+// add sums an item into the row.
+func (c *Cycle) add(it Item) {
+	c.Items = append(c.Items, it)
+	switch it.Category {
+	case OperationsCategory:
+		c.Operations = currency.Sum(c.Operations, it.Amount)
+	case UtilitiesCategory:
+		c.Utilities = currency.Sum(c.Utilities, it.Amount)
+	case InsuranceCategory:
+		c.Insurance = currency.Sum(c.Insurance, it.Amount)
+	case TaxesCategory:
+		c.Taxes = currency.Sum(c.Taxes, it.Amount)
+	}
+}
@@ -0,0 +1,73 @@
+package expense
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/jmacd/caspar.water/cmd/internal/billing/csv"
+	"github.com/stretchr/testify/require"
+)
+
+const itemizedHeader = "Period Start,Operations,Utilities,Insurance,Taxes,Bill Date,Method,Margin,Effective Connections,Itemized\n"
+
+const itemsHeader = "Date,Vendor,Category,Amount,Description\n"
+
+func readItems(t *testing.T, data string) []Item {
+	items, err := csv.Read[Item]("<input>", bytes.NewBufferString(itemsHeader+data))
+	require.NoError(t, err)
+	return items
+}
+
+func TestItemize(t *testing.T) {
+	rows, err := csv.Read[Cycle]("<input>", bytes.NewBufferString(itemizedHeader+`10/1/1914,$0.00,$0.00,$0.00,$0.00,5/1/1915,Normal,0.0,3,TRUE
+4/1/1915,$0.00,$0.00,$0.00,$0.00,10/15/1915,Normal,0.0,3,true
+10/1/1915,$300.00,$300.00,$0.00,$0.00,4/15/1916,Normal,0.0,3,
+`))
+	require.NoError(t, err)
+
+	items := readItems(t, `10/3/1914,Pump Co,Operations,$250.00,Chlorine
+11/1/1914,Power Co,utilities,$120.00,
+3/31/1915,Power Co,Utilities,$130.00,
+3/15/1915,Lab,Operations,$50.00,Coliform test
+1/10/1915,County,Taxes,$400.00,Property tax
+7/1/1915,Insurer,Insurance,$600.00,Liability
+7/1/1915,Insurer,Insurance,-$100.00,Refund
+6/1/1915,Pump Co,Operations,$300.00,
+6/1/1915,Power Co,Utilities,$240.00,
+2/1/1917,Power Co,Utilities,$999.00,After every cycle
+`)
+	require.NoError(t, Itemize(rows, items, 2))
+
+	require.Equal(t, "$300.00", rows[0].Operations.Display())
+	require.Equal(t, "$250.00", rows[0].Utilities.Display())
+	require.Equal(t, "$400.00", rows[0].Taxes.Display())
+	require.Equal(t, "$500.00", rows[0].Insurance.Display())
+	require.Equal(t, 7, len(rows[0].Items))
+	require.Equal(t, "Chlorine", rows[0].Items[0].Description)
+
+	// The second cycle lists the year's taxes and insurance, which
+	// SplitAnnual shares from the first.
+	require.Equal(t, "$300.00", rows[1].Operations.Display())
+	require.Equal(t, "$240.00", rows[1].Utilities.Display())
+	require.True(t, rows[1].Taxes.IsZero())
+	require.True(t, rows[1].Insurance.IsZero())
+	require.Equal(t, 5, len(rows[1].Items))
+
+	require.Equal(t, "$300.00", rows[2].Operations.Display())
+	require.Equal(t, 0, len(rows[2].Items))
+
+	// An item in a cycle that is not itemized.
+	err = Itemize(rows, readItems(t, `11/1/1915,Power Co,Utilities,$120.00,
+`), 2)
+	require.ErrorContains(t, err, "cycle 1916-Mar, which is not itemized")
+
+	// Bad category.
+	_, err = csv.Read[Item]("<input>", bytes.NewBufferString(itemsHeader+`11/1/1915,Power Co,Power,$120.00,
+`))
+	require.Error(t, err)
+
+	// Itemized rows enter zero.
+	_, err = csv.Read[Cycle]("<input>", bytes.NewBufferString(itemizedHeader+`10/1/1914,$1.00,$0.00,$0.00,$0.00,5/1/1915,Normal,0.0,3,TRUE
+`))
+	require.Error(t, err)
+}
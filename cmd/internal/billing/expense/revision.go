@@ -0,0 +1,88 @@
+package expense
+
+import (
+	"fmt"
+
+	"github.com/jmacd/caspar.water/cmd/internal/billing/constant"
+)
+
+// Revision restates the expenses of a cycle that was billed on an
+// estimate, once the actual amounts are known.
+type Revision struct {
+	// Original is the index of the revised cycle.
+	Original int
+
+	// Actual is the revision row.  Its BillDate is the date the
+	// actual expenses were entered.
+	Actual Cycle
+}
+
+// Revisions separates revision rows from the cycles they revise and
+// splits yearly expenses, see SplitAnnual.  Each revision follows
+// the estimated cycle it revises, at most once, dated after the
+// period closes.  Revision rows enter taxes and insurance the same
+// way as the original row: the first cycle of a year restates its
+// share of the yearly amounts, the others must enter zero.
+func Revisions(rows []Cycle, perYear int) ([]Cycle, []Revision, error) {
+	var cycles []Cycle
+	var revisions []Revision
+
+	find := func(c Cycle) int {
+		for i, o := range cycles {
+			if o.PeriodStart.Starting().Date().Equal(c.PeriodStart.Starting().Date()) {
+				return i
+			}
+		}
+		return -1
+	}
+
+	for _, row := range rows {
+		name := row.PeriodStart.Closing().Date().Format(constant.InvoiceDateLayout)
+		orig := find(row)
+
+		if !row.Revision {
+			if orig >= 0 {
+				return nil, nil, fmt.Errorf("cycle %s is repeated, mark the row as a revision", name)
+			}
+			cycles = append(cycles, row)
+			continue
+		}
+		if orig < 0 {
+			return nil, nil, fmt.Errorf("revision of cycle %s does not follow the original", name)
+		}
+		if !cycles[orig].BillDate.Before(cycles[orig].PeriodStart.Closing()) {
+			return nil, nil, fmt.Errorf("revision of cycle %s: the original was not estimated", name)
+		}
+		if row.BillDate.Before(row.PeriodStart.Closing()) {
+			return nil, nil, fmt.Errorf("revision of cycle %s is dated before the period closes", name)
+		}
+		for _, r := range revisions {
+			if r.Original == orig {
+				return nil, nil, fmt.Errorf("cycle %s is revised more than once", name)
+			}
+		}
+		revisions = append(revisions, Revision{
+			Original: orig,
+			Actual:   row,
+		})
+	}
+
+	if err := SplitAnnual(cycles, perYear); err != nil {
+		return nil, nil, err
+	}
+
+	for i := range revisions {
+		r := &revisions[i]
+		if r.Original%perYear == 0 {
+			r.Actual.Taxes = r.Actual.Taxes.Split(perYear)[0]
+			r.Actual.Insurance = r.Actual.Insurance.Split(perYear)[0]
+			continue
+		}
+		if !r.Actual.Taxes.IsZero() || !r.Actual.Insurance.IsZero() {
+			return nil, nil, fmt.Errorf("taxes and insurance are entered once per year, not handled in revision of cycle %d", r.Original+1)
+		}
+		r.Actual.Taxes = cycles[r.Original].Taxes
+		r.Actual.Insurance = cycles[r.Original].Insurance
+	}
+	return cycles, revisions, nil
+}
@@ -0,0 +1,65 @@
+package expense
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/jmacd/caspar.water/cmd/internal/billing/csv"
+	"github.com/stretchr/testify/require"
+)
+
+const revisionHeader = "Period Start,Operations,Utilities,Insurance,Taxes,Bill Date,Method,Margin,Effective Connections,Inactive,Revision\n"
+
+func readCycles(t *testing.T, data string) []Cycle {
+	cycles, err := csv.Read[Cycle]("<input>", bytes.NewBufferString(revisionHeader+data))
+	require.NoError(t, err)
+	return cycles
+}
+
+func TestRevisions(t *testing.T) {
+	cycles, revs, err := Revisions(readCycles(t, `10/1/1914,$300.00,$300.00,$600.00,$600.00,1/15/1915,Normal,0.0,3,,
+4/1/1915,$300.00,$300.00,$0.00,$0.00,7/15/1915,Normal,0.0,3,,
+10/1/1914,$450.00,$300.00,$800.00,$600.00,5/1/1915,Normal,0.0,3,,TRUE
+4/1/1915,$350.00,$300.00,$0.00,$0.00,10/15/1915,Normal,0.0,3,,true
+`), 2)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(cycles))
+	require.Equal(t, 2, len(revs))
+
+	require.Equal(t, 0, revs[0].Original)
+	require.Equal(t, "$450.00", revs[0].Actual.Operations.Display())
+	require.Equal(t, "$400.00", revs[0].Actual.Insurance.Display())
+	require.Equal(t, "$300.00", revs[0].Actual.Taxes.Display())
+
+	require.Equal(t, 1, revs[1].Original)
+	require.Equal(t, "$300.00", revs[1].Actual.Insurance.Display())
+	require.Equal(t, "$300.00", revs[1].Actual.Taxes.Display())
+}
+
+func TestRevisionsInvalid(t *testing.T) {
+	for _, test := range []string{
+		// Repeated without the revision mark.
+		`10/1/1914,$300.00,$300.00,$0.00,$0.00,1/15/1915,Normal,0.0,3,,
+10/1/1914,$300.00,$300.00,$0.00,$0.00,5/1/1915,Normal,0.0,3,,`,
+		// No original.
+		`10/1/1914,$300.00,$300.00,$0.00,$0.00,1/15/1915,Normal,0.0,3,,
+4/1/1915,$300.00,$300.00,$0.00,$0.00,10/15/1915,Normal,0.0,3,,TRUE`,
+		// Not estimated.
+		`10/1/1914,$300.00,$300.00,$0.00,$0.00,5/1/1915,Normal,0.0,3,,
+10/1/1914,$300.00,$300.00,$0.00,$0.00,6/1/1915,Normal,0.0,3,,TRUE`,
+		// Dated before the period closes.
+		`10/1/1914,$300.00,$300.00,$0.00,$0.00,1/15/1915,Normal,0.0,3,,
+10/1/1914,$300.00,$300.00,$0.00,$0.00,2/1/1915,Normal,0.0,3,,TRUE`,
+		// Revised twice.
+		`10/1/1914,$300.00,$300.00,$0.00,$0.00,1/15/1915,Normal,0.0,3,,
+10/1/1914,$300.00,$300.00,$0.00,$0.00,5/1/1915,Normal,0.0,3,,TRUE
+10/1/1914,$300.00,$300.00,$0.00,$0.00,6/1/1915,Normal,0.0,3,,TRUE`,
+		// Yearly amounts in the second cycle of a year.
+		`10/1/1914,$300.00,$300.00,$0.00,$0.00,5/1/1915,Normal,0.0,3,,
+4/1/1915,$300.00,$300.00,$0.00,$0.00,7/15/1915,Normal,0.0,3,,
+4/1/1915,$300.00,$300.00,$0.00,$100.00,10/15/1915,Normal,0.0,3,,TRUE`,
+	} {
+		_, _, err := Revisions(readCycles(t, test+"\n"), 2)
+		require.Error(t, err, "for %s", test)
+	}
+}
@@ -0,0 +1,169 @@
+package history
+
+import (
+	"fmt"
+
+	"github.com/jmacd/caspar.water/cmd/internal/billing/account"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/constant"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/csv"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/currency"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/invoice"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/ledger"
+	"github.com/jmacd/maroto/pkg/pdf"
+)
+
+// Line is one journal entry with the running balance, or a note
+// such as an issued notice.
+type Line struct {
+	Date    csv.Date
+	Kind    ledger.Kind
+	Memo    string
+	Amount  currency.Amount
+	Balance currency.Amount
+
+	// Note describes a line that is not a journal entry.
+	Note string
+}
+
+// Description names the entry on the statement.
+func (l Line) Description() string {
+	if l.Note != "" {
+		return l.Note
+	}
+	if l.Memo == "" {
+		return l.Kind.Display()
+	}
+	return l.Kind.Display() + ": " + l.Memo
+}
+
+// Statement is a customer's account history over a date range.
+type Statement struct {
+	From    csv.Date
+	To      csv.Date
+	Issued  csv.Date
+	Opening currency.Amount
+	Closing currency.Amount
+	Lines   []Line
+}
+
+var _ invoice.Document = &Statement{}
+
+// New lists the account's entries dated from..to inclusive, with the
+// opening balance the day before.
+func New(acct *account.Account, from, to, issued csv.Date) (*Statement, error) {
+	if to.Before(from) {
+		return nil, fmt.Errorf("history ends before it starts")
+	}
+	s := &Statement{
+		From:    from,
+		To:      to,
+		Issued:  issued,
+		Opening: acct.Balance(csv.DateFromTime(from.Date().AddDate(0, 0, -1))),
+	}
+	balance := s.Opening
+	for _, e := range acct.Entries() {
+		if e.Date.Before(from) || to.Before(e.Date) {
+			continue
+		}
+		net := acct.Net(e)
+		balance = currency.Sum(balance, net)
+		s.Lines = append(s.Lines, Line{
+			Date:    e.Date,
+			Kind:    e.Kind,
+			Memo:    e.Memo,
+			Amount:  net,
+			Balance: balance,
+		})
+	}
+	s.Closing = balance
+	return s, nil
+}
+
+// Note adds a line without an amount, e.g., a notice issued on the
+// date, after the entries of that date.  Notes outside the statement's
+// dates are ignored.
+func (s *Statement) Note(date csv.Date, text string) {
+	if date.Before(s.From) || s.To.Before(date) {
+		return
+	}
+	at := len(s.Lines)
+	balance := s.Closing
+	for i, l := range s.Lines {
+		if date.Before(l.Date) {
+			at = i
+			balance = s.Opening
+			if i != 0 {
+				balance = s.Lines[i-1].Balance
+			}
+			break
+		}
+	}
+	s.Lines = append(s.Lines[:at], append([]Line{{
+		Date:    date,
+		Note:    text,
+		Balance: balance,
+	}}, s.Lines[at:]...)...)
+}
+
+func (s *Statement) FullDate() string {
+	return s.Issued.Date().Format(constant.FullDateLayout)
+}
+
+func (s *Statement) InvoiceName() string {
+	return "History-" + s.To.Date().Format(constant.InvoiceDateLayout)
+}
+
+func (s *Statement) BodyText() (string, error) {
+	return fmt.Sprintf(
+		"This is the history of your account from %s through %s, listing every charge, payment, and adjustment with the balance after each.",
+		s.From.Date().Format(constant.FullDateLayout),
+		s.To.Date().Format(constant.FullDateLayout),
+	), nil
+}
+
+// MainContent renders the entries with their running balance.
+func (s *Statement) MainContent(m pdf.Maroto) {
+	rows := [][]string{
+		{
+			s.From.Date().Format(constant.CsvLayout),
+			"Opening balance",
+			"",
+			"",
+			s.Opening.Display(),
+		},
+	}
+	for _, l := range s.Lines {
+		var charge, credit string
+		switch {
+		case l.Note != "":
+		case l.Amount.Units() >= 0:
+			charge = l.Amount.Display()
+		default:
+			credit = currency.Difference(currency.Units(0), l.Amount).Display()
+		}
+		rows = append(rows, []string{
+			l.Date.Date().Format(constant.CsvLayout),
+			l.Description(),
+			charge,
+			credit,
+			l.Balance.Display(),
+		})
+	}
+	rows = append(rows, []string{}, []string{
+		s.To.Date().Format(constant.CsvLayout),
+		"Closing balance",
+		"",
+		"",
+		s.Closing.Display(),
+	})
+
+	m.Row(2, func() {
+		m.TableList([]string{
+			"Date",
+			"Description",
+			"Charges",
+			"Credits",
+			"Balance",
+		}, rows, invoice.TableStyle)
+	})
+}
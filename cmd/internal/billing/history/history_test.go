@@ -0,0 +1,68 @@
+package history
+
+import (
+	"testing"
+
+	"github.com/jmacd/caspar.water/cmd/internal/billing"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/account"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/csv"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/currency"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/ledger"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/user"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistory(t *testing.T) {
+	accts := account.NewAccounts()
+	accts.Register(user.User{AccountName: "A", UserName: "Alice"})
+	acct := accts.Lookup("A")
+
+	date := func(s string) csv.Date {
+		return internal.Must(csv.ParseDate(s))
+	}
+	enter := func(on string, kind ledger.Kind, units int64, memo string) {
+		require.NoError(t, acct.Enter(date(on), kind, currency.Units(units), memo))
+	}
+
+	enter("9/30/2022", ledger.Charge, 30000, "Statement 2022-Sep")
+	enter("3/31/2023", ledger.Charge, 30000, "Statement 2023-Mar")
+	enter("1/15/2023", ledger.Payment, 30000, "Check 101")
+	enter("6/1/2023", ledger.Credit, 2500, "Boil-water notice")
+	enter("6/2/2023", ledger.Payment, 20000, "")
+	enter("9/30/2023", ledger.Charge, 30000, "Statement 2023-Sep")
+	enter("1/2/2024", ledger.Payment, 10000, "")
+
+	stmt, err := New(acct, date("1/1/2023"), date("12/31/2023"), date("1/1/2024"))
+	require.NoError(t, err)
+
+	require.Equal(t, currency.Units(30000), stmt.Opening)
+	require.Equal(t, currency.Units(37500), stmt.Closing)
+	require.Equal(t, 5, len(stmt.Lines))
+
+	require.Equal(t, "Payment: Check 101", stmt.Lines[0].Description())
+	require.Equal(t, currency.Units(-30000), stmt.Lines[0].Amount)
+	require.Equal(t, currency.Units(0), stmt.Lines[0].Balance)
+
+	require.Equal(t, "Statement 2023-Mar", stmt.Lines[1].Memo)
+	require.Equal(t, currency.Units(30000), stmt.Lines[1].Balance)
+	require.Equal(t, currency.Units(7500), stmt.Lines[3].Balance)
+	require.Equal(t, "Payment", stmt.Lines[3].Description())
+
+	require.Equal(t, "January 1, 2024", stmt.FullDate())
+	require.Equal(t, "History-2023-Dec", stmt.InvoiceName())
+
+	// Notices are listed with the balance on their date.
+	stmt.Note(date("5/1/2023"), "Reminder notice")
+	stmt.Note(date("12/31/2023"), "Final notice")
+	stmt.Note(date("1/1/2022"), "Outside")
+	require.Equal(t, 7, len(stmt.Lines))
+	require.Equal(t, "Reminder notice", stmt.Lines[2].Description())
+	require.Equal(t, currency.Units(30000), stmt.Lines[2].Balance)
+	require.True(t, stmt.Lines[2].Amount.IsZero())
+	require.Equal(t, "Boil-water notice", stmt.Lines[3].Memo)
+	require.Equal(t, "Final notice", stmt.Lines[6].Description())
+	require.Equal(t, stmt.Closing, stmt.Lines[6].Balance)
+
+	_, err = New(acct, date("1/1/2024"), date("12/31/2023"), date("1/1/2024"))
+	require.Error(t, err)
+}
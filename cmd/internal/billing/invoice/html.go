@@ -0,0 +1,92 @@
+package invoice
+
+import (
+	"encoding/base64"
+	"html/template"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/jmacd/caspar.water/cmd/internal/billing/business"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/user"
+)
+
+// page is a standalone HTML document: the logo is inlined, and the
+// style follows the PDF.
+var page = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: Helvetica, Arial, sans-serif; font-size: 10pt; max-width: 155mm; margin: 25mm auto; }
+.logo { text-align: center; }
+.logo img { max-height: 30mm; max-width: 100%; }
+.row { display: flex; justify-content: space-between; }
+.bold { font-weight: bold; }
+.lines div { line-height: 5mm; }
+table { width: 100%; border-collapse: collapse; margin: 2mm 0; font-size: 9pt; }
+th, td { text-align: right; padding: 1mm; }
+th:first-child, td:first-child { text-align: left; }
+th { border-bottom: 1px solid #888;{{with .Color}} color: {{.}};{{end}} }
+tr.sep td { height: 3mm; }
+footer { margin-top: 10mm; text-align: center; font-size: 8pt; }
+</style>
+</head>
+<body>
+{{with .Logo}}<div class="logo"><img src="{{.}}" alt=""></div>
+{{end}}{{range .Elements}}{{if .Space}}<div style="height: {{.Space}}mm"></div>
+{{else if or .Text .Right}}<div class="row{{if .Bold}} bold{{end}}"><span>{{.Text}}</span><span>{{.Right}}</span></div>
+{{else if .Lines}}<div class="lines{{if .Bold}} bold{{end}}">{{range .Lines}}<div>{{.}}</div>{{end}}</div>
+{{else if .Body}}{{range .Paragraphs}}<p>{{.}}</p>
+{{end}}{{else if .Content}}{{range $.Content}}<table>
+<tr>{{range .Header}}<th>{{.}}</th>{{end}}</tr>
+{{range .Rows}}{{if .}}<tr>{{range .}}<td>{{.}}</td>{{end}}</tr>{{else}}<tr class="sep"><td></td></tr>{{end}}
+{{end}}</table>
+{{end}}{{end}}{{end}}{{with .Footer}}<footer>{{.}}</footer>
+{{end}}</body>
+</html>
+`))
+
+// HTML renders a document with its main content as a standalone
+// HTML page, e.g., to post or email.
+func (l *Layout) HTML(
+	w io.Writer,
+	bus business.Business,
+	user user.User,
+	doc Document,
+	content Content,
+) error {
+	elems, footer, err := l.document(bus, user, doc)
+	if err != nil {
+		return err
+	}
+	return page.Execute(w, struct {
+		Title    string
+		Logo     template.URL
+		Color    template.CSS
+		Elements []element
+		Content  Content
+		Footer   string
+	}{
+		Title:    bus.Name + ": " + doc.InvoiceName(),
+		Logo:     inlineImage(l.Logo),
+		Color:    template.CSS(l.Color),
+		Elements: elems,
+		Content:  content,
+		Footer:   footer,
+	})
+}
+
+// inlineImage returns a data URL of the image, empty when the file
+// cannot be read, as the PDF omits a missing logo.
+func inlineImage(name string) template.URL {
+	if name == "" {
+		return ""
+	}
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return ""
+	}
+	return template.URL("data:" + http.DetectContentType(data) + ";base64," + base64.StdEncoding.EncodeToString(data))
+}
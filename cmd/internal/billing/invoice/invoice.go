@@ -1,10 +1,7 @@
 package invoice
 
 import (
-	"strings"
-
 	"github.com/jmacd/caspar.water/cmd/internal/billing/business"
-	"github.com/jmacd/caspar.water/cmd/internal/billing/user"
 	"github.com/jmacd/maroto/pkg/color"
 	"github.com/jmacd/maroto/pkg/consts"
 	"github.com/jmacd/maroto/pkg/pdf"
@@ -50,162 +47,78 @@ var TableStyle = props.TableList{
 	},
 }
 
-func MakeInvoice(
-	bus business.Business,
-	user user.User,
-	doc Document,
-	mainContent func(pdf.Maroto),
-) (pdf.Maroto, error) {
-	m := pdf.NewMaroto(consts.Portrait, consts.Letter)
-	m.SetPageMargins(30, 25, 30)
-
-	const bigLine = 5
-	const smallLine = 4
-	const sepLine = 2
-
-	toStyle := lineStyle{
-		sz:    10,
-		ht:    bigLine,
-		top:   4,
-		txt:   consts.Bold,
-		align: consts.Left,
-		color: color.NewBlack(),
-	}
-
-	paymentStyle := lineStyle{
-		sz:    10,
-		ht:    bigLine,
-		top:   4,
-		align: consts.Left,
-		color: color.NewBlack(),
-	}
-
-	boldText := props.Text{
-		Top:    3,
-		Style:  consts.Bold,
-		Align:  consts.Left,
-		Family: consts.Helvetica,
-		Size:   10,
-	}
-
-	normText := props.Text{
-		Align:           consts.Left,
-		Family:          consts.Helvetica,
-		Size:            10,
-		VerticalPadding: 1,
+func registerHeader(m pdf.Maroto, logo string) {
+	if logo == "" {
+		return
 	}
-
-	// centerText := props.Text{
-	// 	Align:           consts.Center,
-	// 	Family:          consts.Helvetica,
-	// 	Size:            10,
-	// 	VerticalPadding: 1,
-	// }
-
-	rightText := props.Text{
-		Align:           consts.Right,
-		Family:          consts.Helvetica,
-		Size:            10,
-		VerticalPadding: 1,
-	}
-
 	m.RegisterHeader(func() {
 		m.Row(30, func() {
 			m.Col(0, func() {
-				_ = m.FileImage("assets/img/logo.jpg", props.Rect{
+				_ = m.FileImage(logo, props.Rect{
 					Percent: 100,
 					Center:  true,
 				})
 			})
 		})
 	})
+}
 
-	// m.RegisterFooter(func() {
-	// 	m.Row(0, func() {
-	// 		m.Col(0, func() {
-	// 			m.Text(bus.Contact, centerText)
+// Report prepares a company report, as opposed to a customer
+// document, with the layout's logo and a title and date above the
+// main content.
+func (l *Layout) Report(
+	bus business.Business,
+	title string,
+	date string,
+	mainContent func(pdf.Maroto),
+) pdf.Maroto {
+	m := pdf.NewMaroto(consts.Portrait, consts.Letter)
+	m.SetPageMargins(30, 25, 30)
 
-	// 		})
-	// 	})
-	// })
+	dateText := props.Text{
+		Top:    3,
+		Align:  consts.Right,
+		Family: consts.Helvetica,
+		Size:   10,
+	}
 
-	m.Row(4, func() {})
-	m.Row(6, func() {
-		m.Col(4, func() {
-			m.Text("To:", normText)
-		})
-		m.ColSpace(4)
-		m.Col(4, func() {
-			m.Text(doc.FullDate(), rightText)
-		})
-	})
+	registerHeader(m, l.Logo)
 
-	toStyle.multiLine(m, append([]string{user.UserName}, user.BillingAddress.Split()...))
 	m.Row(4, func() {})
-
 	m.Row(8, func() {
 		m.Col(8, func() {
-			m.Text("Invoice: "+doc.InvoiceName(), boldText)
+			m.Text(bus.Name+": "+title, boldText)
 		})
-		m.Row(4, func() {})
-	})
-
-	m.Row(8, func() {
-		m.Col(12, func() {
-			m.Text("Service address: "+user.ServiceAddress.OneLine(), boldText)
+		m.Col(4, func() {
+			m.Text(date, dateText)
 		})
 	})
 	m.Row(4, func() {})
 
-	body, err := doc.BodyText()
-	if err != nil {
-		return nil, err
-	}
-
-	if body != "" {
-		for _, para := range strings.Split(body, "\n\n") {
-			para = strings.TrimSpace(para)
-			para = strings.ReplaceAll(para, "\n", " ")
-
-			plines := m.GetLinesHeight(para, normText, 115)
-			m.Row(float64(plines), func() {
-				m.Col(0, func() {
-					m.Text(para, normText)
-				})
-			})
-		}
-		m.Row(1, func() {})
-	}
-
 	mainContent(m)
 
-	m.Row(2, func() {})
-	m.Row(4, func() {
-		m.Col(4, func() {
-			m.Text("Please send payment to:", normText)
-		})
-	})
-
-	paymentStyle.multiLine(m, append([]string{bus.Name}, bus.Address.Split()...))
+	return m
+}
 
-	m.Row(10, func() {})
-	m.Row(4, func() {
-		m.Col(4, func() {
-			m.Text("Thank you!", normText)
-		})
-	})
+// Table is a table of a document's main content.  An empty row
+// separates groups of rows.
+type Table struct {
+	Header []string
+	Rows   [][]string
+}
 
-	// m.RegisterFooter(func() {
+// Content is a document's main content, a sequence of tables.
+type Content []Table
 
-	// Having trouble with the footer being placed by itself on
-	// the following page.
-	// m.Row(80, func() {})
-	// m.Row(2, func() {
-	// 	m.Col(0, func() {
-	// 		m.Text(bus.Contact, centerText)
+// PDF renders the tables.
+func (c Content) PDF(m pdf.Maroto) {
+	c.render(m, TableStyle)
+}
 
-	// 	})
-	// })
-	//})
-	return m, nil
+func (c Content) render(m pdf.Maroto, style props.TableList) {
+	for _, t := range c {
+		m.Row(2, func() {
+			m.TableList(t.Header, t.Rows, style)
+		})
+	}
 }
@@ -0,0 +1,374 @@
+package invoice
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/jmacd/caspar.water/cmd/internal/billing/business"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/user"
+	"github.com/jmacd/maroto/pkg/color"
+	"github.com/jmacd/maroto/pkg/consts"
+	"github.com/jmacd/maroto/pkg/pdf"
+	"github.com/jmacd/maroto/pkg/props"
+	"github.com/spf13/afero"
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed layout.yaml
+var defaultLayout []byte
+
+// Layout describes a customer document: the logo, a sequence of
+// blocks, and a footer printed at the bottom of each page.  Text is a
+// text/template executed with Data.  The same layout renders the PDF
+// and the HTML document.  Color is the table header color, as
+// "#rrggbb", black by default.
+type Layout struct {
+	Logo   string  `yaml:"logo"`
+	Color  string  `yaml:"color"`
+	Blocks []Block `yaml:"blocks"`
+	Footer string  `yaml:"footer"`
+}
+
+// Block is one element of a layout, of which one kind is set.
+type Block struct {
+	// Space is a blank row, with height in millimeters.
+	Space float64 `yaml:"space"`
+
+	// Text is a line of text, with optional Right text aligned
+	// on the same line.
+	Text  string `yaml:"text"`
+	Right string `yaml:"right"`
+
+	// Lines is a block of lines, e.g., an address, one for each
+	// non-empty line of the executed template.
+	Lines string `yaml:"lines"`
+
+	// Body is the document's body text, by paragraph.
+	Body bool `yaml:"body"`
+
+	// Content is the document's main content.
+	Content bool `yaml:"content"`
+
+	// Bold and Height style a Text or Lines block.  The
+	// default height is 8 for bold text, 6 for normal text, and
+	// 5 for each line.
+	Bold   bool    `yaml:"bold"`
+	Height float64 `yaml:"height"`
+}
+
+// Data is the input of the layout's templates.
+type Data struct {
+	Business business.Business
+	User     user.User
+
+	// Date and Invoice are the document's FullDate and
+	// InvoiceName.
+	Date    string
+	Invoice string
+
+	// BillingAddress and PaymentAddress are address lines.
+	// ServiceAddress is empty on a consolidated statement.
+	BillingAddress []string
+	PaymentAddress []string
+	ServiceAddress string
+}
+
+// DefaultLayout returns the built-in statement layout.
+func DefaultLayout() *Layout {
+	l, err := ParseLayout(defaultLayout)
+	if err != nil {
+		panic(err)
+	}
+	return l
+}
+
+// ReadLayout reads a layout file.
+func ReadLayout(name string, fs afero.Fs) (*Layout, error) {
+	data, err := afero.ReadFile(fs, name)
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", name, err)
+	}
+	l, err := ParseLayout(data)
+	if err != nil {
+		return nil, fmt.Errorf("layout %s: %w", name, err)
+	}
+	return l, nil
+}
+
+func ParseLayout(data []byte) (*Layout, error) {
+	var l Layout
+	if err := yaml.Unmarshal(data, &l); err != nil {
+		return nil, err
+	}
+	if err := l.Validate(); err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+func (l *Layout) Validate() error {
+	if _, err := l.color(); err != nil {
+		return err
+	}
+	for i, b := range l.Blocks {
+		kinds := 0
+		for _, set := range []bool{b.Space != 0, b.Text != "", b.Lines != "", b.Body, b.Content} {
+			if set {
+				kinds++
+			}
+		}
+		if kinds != 1 {
+			return fmt.Errorf("block %d should have one of space, text, lines, body, or content", i+1)
+		}
+		if b.Right != "" && b.Text == "" {
+			return fmt.Errorf("block %d: right requires text", i+1)
+		}
+		for _, t := range []string{b.Text, b.Right, b.Lines} {
+			if _, err := template.New("block").Parse(t); err != nil {
+				return fmt.Errorf("block %d: %w", i+1, err)
+			}
+		}
+	}
+	if _, err := template.New("footer").Parse(l.Footer); err != nil {
+		return fmt.Errorf("footer: %w", err)
+	}
+	return nil
+}
+
+// color returns the table header color.
+func (l *Layout) color() (color.Color, error) {
+	if l.Color == "" {
+		return color.NewBlack(), nil
+	}
+	var r, g, b int
+	if n, err := fmt.Sscanf(l.Color, "#%02x%02x%02x", &r, &g, &b); err != nil || n != 3 || len(l.Color) != 7 {
+		return color.Color{}, fmt.Errorf("color should be #rrggbb: %q", l.Color)
+	}
+	return color.Color{Red: r, Green: g, Blue: b}, nil
+}
+
+// Content returns the main content of a PDF, with the layout's
+// table header color.
+func (l *Layout) Content(c Content) func(pdf.Maroto) {
+	style := TableStyle
+	style.HeaderProp.Color, _ = l.color()
+	return func(m pdf.Maroto) {
+		c.render(m, style)
+	}
+}
+
+// element is an executed block.
+type element struct {
+	Block
+	Lines      []string
+	Paragraphs []string
+}
+
+func execute(text string, data Data) (string, error) {
+	tmpl, err := template.New("block").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// document executes the layout for one document, returning its
+// elements and footer.
+func (l *Layout) document(bus business.Business, user user.User, doc Document) ([]element, string, error) {
+	data := Data{
+		Business:       bus,
+		User:           user,
+		Date:           doc.FullDate(),
+		Invoice:        doc.InvoiceName(),
+		BillingAddress: user.BillingAddress.Split(),
+		PaymentAddress: bus.Address.Split(),
+		ServiceAddress: user.ServiceAddress.OneLine(),
+	}
+	var elems []element
+	for _, b := range l.Blocks {
+		e := element{Block: b}
+		var err error
+		switch {
+		case b.Text != "":
+			if e.Text, err = execute(b.Text, data); err != nil {
+				return nil, "", err
+			}
+			if e.Right, err = execute(b.Right, data); err != nil {
+				return nil, "", err
+			}
+			if e.Text == "" && e.Right == "" {
+				continue
+			}
+		case b.Lines != "":
+			text, err := execute(b.Lines, data)
+			if err != nil {
+				return nil, "", err
+			}
+			for _, line := range strings.Split(text, "\n") {
+				if line = strings.TrimSpace(line); line != "" {
+					e.Lines = append(e.Lines, line)
+				}
+			}
+			if len(e.Lines) == 0 {
+				continue
+			}
+		case b.Body:
+			body, err := doc.BodyText()
+			if err != nil {
+				return nil, "", err
+			}
+			for _, para := range strings.Split(body, "\n\n") {
+				para = strings.TrimSpace(para)
+				para = strings.ReplaceAll(para, "\n", " ")
+				if para != "" {
+					e.Paragraphs = append(e.Paragraphs, para)
+				}
+			}
+		}
+		elems = append(elems, e)
+	}
+	footer, err := execute(l.Footer, data)
+	if err != nil {
+		return nil, "", err
+	}
+	return elems, footer, nil
+}
+
+// height returns the row height of a Text block, or of each line of
+// a Lines block.
+func (e element) height() float64 {
+	switch {
+	case e.Height != 0:
+		return e.Height
+	case len(e.Lines) != 0:
+		return 5
+	case e.Bold:
+		return 8
+	}
+	return 6
+}
+
+var (
+	normText = props.Text{
+		Align:           consts.Left,
+		Family:          consts.Helvetica,
+		Size:            10,
+		VerticalPadding: 1,
+	}
+
+	boldText = props.Text{
+		Top:    3,
+		Style:  consts.Bold,
+		Align:  consts.Left,
+		Family: consts.Helvetica,
+		Size:   10,
+	}
+
+	rightText = props.Text{
+		Align:           consts.Right,
+		Family:          consts.Helvetica,
+		Size:            10,
+		VerticalPadding: 1,
+	}
+
+	footerText = props.Text{
+		Align:  consts.Center,
+		Family: consts.Helvetica,
+		Size:   8,
+	}
+)
+
+// PDF renders a document with its main content.
+func (l *Layout) PDF(
+	bus business.Business,
+	user user.User,
+	doc Document,
+	mainContent func(pdf.Maroto),
+) (pdf.Maroto, error) {
+	elems, footer, err := l.document(bus, user, doc)
+	if err != nil {
+		return nil, err
+	}
+
+	m := pdf.NewMaroto(consts.Portrait, consts.Letter)
+	m.SetPageMargins(30, 25, 30)
+
+	registerHeader(m, l.Logo)
+
+	// The footer is registered before the content, so that each
+	// page reserves its height.  Maroto truncates the space above
+	// the footer to whole millimeters, so the footer can end up to
+	// 1mm past the page; it ends with an empty row, without
+	// columns, so that its text does not break onto a page by
+	// itself.
+	if footer != "" {
+		m.RegisterFooter(func() {
+			m.Row(5, func() {
+				m.Col(0, func() {
+					m.Text(footer, footerText)
+				})
+			})
+			m.Row(1, func() {})
+		})
+	}
+
+	for _, e := range elems {
+		switch {
+		case e.Space != 0:
+			m.Row(e.Space, func() {})
+		case e.Text != "" || e.Right != "":
+			style := normText
+			if e.Bold {
+				style = boldText
+			}
+			m.Row(e.height(), func() {
+				if e.Right == "" {
+					m.Col(0, func() {
+						m.Text(e.Text, style)
+					})
+					return
+				}
+				m.Col(8, func() {
+					m.Text(e.Text, style)
+				})
+				m.Col(4, func() {
+					m.Text(e.Right, rightText)
+				})
+			})
+		case len(e.Lines) != 0:
+			style := lineStyle{
+				sz:    10,
+				ht:    e.height(),
+				top:   4,
+				align: consts.Left,
+				color: color.NewBlack(),
+			}
+			if e.Bold {
+				style.txt = consts.Bold
+			}
+			style.multiLine(m, e.Lines)
+		case e.Body:
+			for _, para := range e.Paragraphs {
+				plines := m.GetLinesHeight(para, normText, 115)
+				m.Row(float64(plines), func() {
+					m.Col(0, func() {
+						m.Text(para, normText)
+					})
+				})
+			}
+			if len(e.Paragraphs) != 0 {
+				m.Row(1, func() {})
+			}
+		case e.Content:
+			mainContent(m)
+		}
+	}
+	return m, nil
+}
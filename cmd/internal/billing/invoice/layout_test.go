@@ -0,0 +1,131 @@
+package invoice
+
+import (
+	"bytes"
+	"regexp"
+	"testing"
+
+	"github.com/jmacd/caspar.water/cmd/internal/billing/business"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/user"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+type testDoc struct{}
+
+func (testDoc) FullDate() string    { return "May 1, 1915" }
+func (testDoc) InvoiceName() string { return "1915-Mar" }
+func (testDoc) BodyText() (string, error) {
+	return "First paragraph\ncontinued.\n\nSecond & last.\n", nil
+}
+
+var (
+	testBusiness = business.Business{
+		Name:    "Water Company",
+		Address: "1 Drive; Caspar, CA 91234",
+		Contact: "p: 555-555-5555",
+	}
+	testUser = user.User{
+		AccountName:    "House2",
+		UserName:       "Miller",
+		ServiceAddress: "2 Road; Caspar, CA 91234",
+		BillingAddress: "PO Box 2; Caspar, CA 91234",
+	}
+	testContent = Content{{
+		Header: []string{"Expense", "Cost"},
+		Rows: [][]string{
+			{"Operations", "$300.00"},
+			{},
+			{"Amount due", "$150.00"},
+		},
+	}}
+)
+
+func pages(t *testing.T, l *Layout, content Content) int {
+	m, err := l.PDF(testBusiness, testUser, testDoc{}, content.PDF)
+	require.NoError(t, err)
+	buf, err := m.Output()
+	require.NoError(t, err)
+	return len(regexp.MustCompile(`/Type /Page\b[^s]`).FindAll(buf.Bytes(), -1))
+}
+
+func TestDefaultLayoutHTML(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, DefaultLayout().HTML(&buf, testBusiness, testUser, testDoc{}, testContent))
+	html := buf.String()
+
+	for _, want := range []string{
+		"<title>Water Company: 1915-Mar</title>",
+		"<span>To:</span><span>May 1, 1915</span>",
+		"<div>Miller</div><div>PO Box 2</div><div>Caspar, CA 91234</div>",
+		"<span>Invoice: 1915-Mar</span>",
+		"Service address: 2 Road; Caspar, CA 91234",
+		"<p>First paragraph continued.</p>",
+		"<p>Second &amp; last.</p>",
+		"<th>Expense</th><th>Cost</th>",
+		"<tr><td>Operations</td><td>$300.00</td></tr>",
+		`<tr class="sep">`,
+		"<div>Water Company</div><div>1 Drive</div>",
+		"<footer>p: 555-555-5555</footer>",
+		"color: #0a0a96;",
+	} {
+		require.Contains(t, html, want)
+	}
+
+	// A consolidated statement has no service address.
+	consolidated := testUser
+	consolidated.ServiceAddress = ""
+	buf.Reset()
+	require.NoError(t, DefaultLayout().HTML(&buf, testBusiness, consolidated, testDoc{}, testContent))
+	require.NotContains(t, buf.String(), "Service address")
+}
+
+func TestLayoutFooter(t *testing.T) {
+	l := DefaultLayout()
+	l.Logo = ""
+	plain := *l
+	plain.Footer = ""
+
+	// The footer does not break onto a page by itself: pages
+	// with and without a footer break at the same content.
+	var c Content
+	for n := 1; n <= 14; n++ {
+		c = append(c, testContent...)
+		require.Equal(t, pages(t, &plain, c), pages(t, l, c), "%d tables", n)
+	}
+	require.Equal(t, 2, pages(t, l, c))
+}
+
+func TestReadLayout(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	afs := &afero.Afero{Fs: fs}
+
+	require.NoError(t, afs.WriteFile("layout.yaml", []byte(`
+blocks:
+  - text: "Statement {{.Invoice}} for {{.User.AccountName}}"
+    bold: true
+  - content: true
+footer: "Questions? {{.Business.Contact}}"
+`), 0644))
+	l, err := ReadLayout("layout.yaml", fs)
+	require.NoError(t, err)
+	require.Equal(t, "", l.Logo)
+
+	var buf bytes.Buffer
+	require.NoError(t, l.HTML(&buf, testBusiness, testUser, testDoc{}, testContent))
+	require.Contains(t, buf.String(), "<span>Statement 1915-Mar for House2</span>")
+	require.Contains(t, buf.String(), "<footer>Questions? p: 555-555-5555</footer>")
+	require.NotContains(t, buf.String(), "<img")
+
+	for _, bad := range []string{
+		"blocks:\n  - text: To\n    lines: From\n",
+		"blocks:\n  - right: To\n",
+		"blocks:\n  - text: \"{{.Invoice\"\n",
+		"color: blue\n",
+		"color: \"#0a0a9\"\n",
+	} {
+		require.NoError(t, afs.WriteFile("bad.yaml", []byte(bad), 0644))
+		_, err := ReadLayout("bad.yaml", fs)
+		require.Error(t, err, bad)
+	}
+}
@@ -0,0 +1,74 @@
+package latefee
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jmacd/caspar.water/cmd/internal/billing/currency"
+)
+
+// Policy describes the fee assessed when a statement's balance
+// remains unpaid at the next bill date.  The policy file has one row.
+type Policy struct {
+	// GraceDays is the time allowed after a bill date before the
+	// balance is overdue.
+	GraceDays int
+
+	// FlatFee is charged on any overdue balance.
+	FlatFee currency.Amount
+
+	// Rate is a fraction of the overdue balance charged in
+	// addition to the flat fee, e.g., 0.015.
+	Rate float64
+
+	// Cap is the largest fee assessed at once, zero for no cap.
+	Cap currency.Amount
+
+	// MinimumBalance is the smallest overdue balance that is
+	// charged a fee.
+	MinimumBalance currency.Amount
+}
+
+func (p Policy) Validate() error {
+	if p.GraceDays < 0 {
+		return fmt.Errorf("late fee grace days cannot be negative")
+	}
+	if p.FlatFee.Units() < 0 || p.Cap.Units() < 0 || p.MinimumBalance.Units() < 0 {
+		return fmt.Errorf("late fee amounts cannot be negative")
+	}
+	if p.Rate < 0 || p.Rate >= 1 {
+		return fmt.Errorf("late fee rate should be a fraction: %v", p.Rate)
+	}
+	if p.FlatFee.IsZero() && p.Rate == 0 {
+		return fmt.Errorf("late fee policy has no fee")
+	}
+	return nil
+}
+
+// Assess returns the fee for an overdue balance.
+func (p Policy) Assess(overdue currency.Amount) currency.Amount {
+	if overdue.Units() <= 0 || overdue.Units() < p.MinimumBalance.Units() {
+		return currency.Units(0)
+	}
+	fee := currency.Sum(p.FlatFee, overdue.Mul(currency.Decimal(p.Rate), currency.RoundDown))
+	if !p.Cap.IsZero() && fee.Units() > p.Cap.Units() {
+		fee = p.Cap
+	}
+	return fee
+}
+
+// Describe explains the policy for the statement.
+func (p Policy) Describe() string {
+	var parts []string
+	if !p.FlatFee.IsZero() {
+		parts = append(parts, p.FlatFee.Display())
+	}
+	if p.Rate != 0 {
+		parts = append(parts, fmt.Sprintf("%.4g%% of the overdue balance", 100*p.Rate))
+	}
+	desc := strings.Join(parts, " plus ")
+	if !p.Cap.IsZero() {
+		desc += ", up to " + p.Cap.Display()
+	}
+	return fmt.Sprintf("%s, for balances unpaid %d days after the bill date", desc, p.GraceDays)
+}
@@ -0,0 +1,45 @@
+package latefee
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/jmacd/caspar.water/cmd/internal/billing/csv"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/currency"
+	"github.com/stretchr/testify/require"
+)
+
+const header = "Grace Days,Flat Fee,Rate,Cap,Minimum Balance"
+
+func TestPolicyAssess(t *testing.T) {
+	policies, err := csv.Read[Policy]("<input>", bytes.NewBufferString(header+`
+30,"$10.00",0.015,"$25.00","$5.00"
+`))
+	require.NoError(t, err)
+	policy := policies[0]
+
+	for overdue, fee := range map[int64]int64{
+		-100:   0,
+		0:      0,
+		499:    0,
+		500:    1007,
+		10000:  1150,
+		100000: 2500,
+	} {
+		require.Equal(t, currency.Units(fee), policy.Assess(currency.Units(overdue)), "for %d", overdue)
+	}
+	require.Equal(t,
+		"$10.00 plus 1.5% of the overdue balance, up to $25.00, for balances unpaid 30 days after the bill date",
+		policy.Describe())
+}
+
+func TestPolicyInvalid(t *testing.T) {
+	for _, test := range []string{
+		`-1,"$10.00",0.015,"$25.00","$5.00"`,
+		`30,"$0.00",0,"$25.00","$5.00"`,
+		`30,"$10.00",1.5,"$25.00","$5.00"`,
+	} {
+		_, err := csv.Read[Policy]("<input>", bytes.NewBufferString(header+"\n"+test))
+		require.Error(t, err, "for %s", test)
+	}
+}
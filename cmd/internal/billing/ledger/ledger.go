@@ -0,0 +1,250 @@
+package ledger
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jmacd/caspar.water/cmd/internal/billing/csv"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/currency"
+)
+
+// Kind is the type of a journal entry.
+type Kind string
+
+const (
+	// Charge bills a customer for service.
+	Charge Kind = "Charge"
+	// Payment is money received from a customer.
+	Payment Kind = "Payment"
+	// Adjustment corrects a prior charge, positive or negative.
+	Adjustment Kind = "Adjustment"
+	// Credit reduces what a customer owes without payment.
+	Credit Kind = "Credit"
+	// Refund is money returned to a customer.
+	Refund Kind = "Refund"
+	// WriteOff forgives an uncollectible balance.
+	WriteOff Kind = "WriteOff"
+	// Transfer moves a balance from one customer account to another.
+	Transfer Kind = "Transfer"
+	// LateFee is assessed on an overdue balance.
+	LateFee Kind = "LateFee"
+	// Waiver forgives a late fee.
+	Waiver Kind = "Waiver"
+	// TrueUp settles the difference between an estimated charge
+	// and the actual, positive or negative.
+	TrueUp Kind = "TrueUp"
+)
+
+var kinds = []Kind{
+	Charge,
+	Payment,
+	Adjustment,
+	Credit,
+	Refund,
+	WriteOff,
+	Transfer,
+	LateFee,
+	Waiver,
+	TrueUp,
+}
+
+func (k *Kind) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	str := strings.ReplaceAll(strings.ToLower(s), "-", "")
+	for _, kind := range kinds {
+		if strings.ToLower(string(kind)) == str {
+			*k = kind
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid entry kind: %q", s)
+}
+
+// Display names the kind for statements.
+func (k Kind) Display() string {
+	switch k {
+	case WriteOff:
+		return "Write-off"
+	case LateFee:
+		return "Late fee"
+	case TrueUp:
+		return "True-up"
+	}
+	return string(k)
+}
+
+// Company accounts in the chart of accounts.  Customer balances are
+// kept in per-account receivables, see Receivable.
+const (
+	Cash        = "Cash"
+	Revenue     = "Revenue"
+	Credits     = "Credits"
+	BadDebt     = "BadDebt"
+	LateFees    = "LateFees"
+	receivables = "Receivable:"
+)
+
+// Receivable is the ledger account holding a customer's balance.
+func Receivable(accountName string) string {
+	return receivables + accountName
+}
+
+// Posting is one side of a journal entry.  Positive amounts are
+// debits and negative amounts are credits.
+type Posting struct {
+	Account string
+	Amount  currency.Amount
+}
+
+// Entry is a balanced journal entry.
+type Entry struct {
+	Seq      int
+	Date     csv.Date
+	Kind     Kind
+	Memo     string
+	Postings []Posting
+}
+
+// Amount returns the net posting to one ledger account.
+func (e Entry) Amount(account string) currency.Amount {
+	var total currency.Amount
+	for _, p := range e.Postings {
+		if p.Account == account {
+			total = currency.Sum(total, p.Amount)
+		}
+	}
+	return total
+}
+
+// Touches indicates whether the entry posts to the ledger account.
+func (e Entry) Touches(account string) bool {
+	for _, p := range e.Postings {
+		if p.Account == account {
+			return true
+		}
+	}
+	return false
+}
+
+// NewEntry builds the entry for a customer transaction of the given
+// kind.  Amounts are positive except for an Adjustment or TrueUp,
+// which may be negative to reduce a prior charge.  The counterpart names the
+// receiving customer for a Transfer and is otherwise ignored.
+func NewEntry(date csv.Date, kind Kind, accountName string, amount currency.Amount, counterpart, memo string) (Entry, error) {
+	if kind != Adjustment && kind != TrueUp && amount.Units() < 0 {
+		return Entry{}, fmt.Errorf("negative %s amount: %s", kind, amount.Display())
+	}
+	debit := func(acct string) []Posting {
+		return []Posting{
+			{Account: acct, Amount: amount},
+			{Account: Receivable(accountName), Amount: currency.Difference(currency.Units(0), amount)},
+		}
+	}
+	credit := func(acct string) []Posting {
+		return []Posting{
+			{Account: Receivable(accountName), Amount: amount},
+			{Account: acct, Amount: currency.Difference(currency.Units(0), amount)},
+		}
+	}
+	e := Entry{
+		Date: date,
+		Kind: kind,
+		Memo: memo,
+	}
+	switch kind {
+	case Charge, Adjustment, TrueUp:
+		e.Postings = credit(Revenue)
+	case Refund:
+		e.Postings = credit(Cash)
+	case Payment:
+		e.Postings = debit(Cash)
+	case Credit:
+		e.Postings = debit(Credits)
+	case WriteOff:
+		e.Postings = debit(BadDebt)
+	case LateFee:
+		e.Postings = credit(LateFees)
+	case Waiver:
+		e.Postings = debit(LateFees)
+	case Transfer:
+		if counterpart == "" || counterpart == accountName {
+			return Entry{}, fmt.Errorf("transfer requires a different destination account")
+		}
+		e.Postings = debit(Receivable(counterpart))
+	default:
+		return Entry{}, fmt.Errorf("invalid entry kind: %q", kind)
+	}
+	return e, nil
+}
+
+// Ledger is an append-only double-entry journal.
+type Ledger struct {
+	entries []Entry
+}
+
+func New() *Ledger {
+	return &Ledger{}
+}
+
+// Post appends a journal entry, which must balance.
+func (l *Ledger) Post(e Entry) error {
+	if len(e.Postings) < 2 {
+		return fmt.Errorf("journal entry needs two sides: %s %s", e.Kind, e.Memo)
+	}
+	var sum currency.Amount
+	for _, p := range e.Postings {
+		sum = currency.Sum(sum, p.Amount)
+	}
+	if !sum.IsZero() {
+		return fmt.Errorf("unbalanced journal entry: %s %s: %s", e.Kind, e.Memo, sum.Display())
+	}
+	e.Seq = len(l.entries) + 1
+	l.entries = append(l.entries, e)
+	return nil
+}
+
+// Balance sums the postings to a ledger account through a date.
+func (l *Ledger) Balance(account string, on csv.Date) currency.Amount {
+	var total currency.Amount
+	for _, e := range l.entries {
+		if !e.Date.Date().After(on.Date()) {
+			total = currency.Sum(total, e.Amount(account))
+		}
+	}
+	return total
+}
+
+// Entries returns the entries posting to a ledger account, ordered by
+// date and then by sequence.
+func (l *Ledger) Entries(account string) []Entry {
+	var out []Entry
+	for _, e := range l.entries {
+		if e.Touches(account) {
+			out = append(out, e)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].Date.Before(out[j].Date)
+	})
+	return out
+}
+
+// TrialBalance returns the balance of every ledger account, which
+// sum to zero.
+func (l *Ledger) TrialBalance(on csv.Date) map[string]currency.Amount {
+	tb := map[string]currency.Amount{}
+	for _, e := range l.entries {
+		if e.Date.Date().After(on.Date()) {
+			continue
+		}
+		for _, p := range e.Postings {
+			tb[p.Account] = currency.Sum(tb[p.Account], p.Amount)
+		}
+	}
+	return tb
+}
@@ -0,0 +1,78 @@
+package ledger
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/jmacd/caspar.water/cmd/internal/billing"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/csv"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/currency"
+	"github.com/stretchr/testify/require"
+)
+
+const header = "Date,Kind,Account Name,Amount,To Account,Memo"
+
+func TestLedgerPost(t *testing.T) {
+	data := header + `
+1/1/2023,Charge,Name1,"$100.00",,Statement
+2/1/2023,Payment,Name1,"$60.00",,Check 101
+3/1/2023,Adjustment,Name1,"-$10.00",,Meter misread
+3/2/2023,Credit,Name1,"$5.00",,Boil-water notice
+3/3/2023,Refund,Name1,"$2.00",,Overpayment
+4/1/2023,Transfer,Name1,"$20.00",Name2,Account changeover
+5/1/2023,Write-off,Name2,"$20.00",,Uncollectible
+`
+	records, err := csv.Read[Record]("<input>", bytes.NewBufferString(data))
+	require.NoError(t, err)
+	require.Equal(t, 7, len(records))
+
+	l := New()
+	for _, rec := range records {
+		e, err := rec.Entry()
+		require.NoError(t, err)
+		require.NoError(t, l.Post(e))
+	}
+
+	date := func(s string) csv.Date {
+		return internal.Must(csv.ParseDate(s))
+	}
+
+	require.Equal(t, currency.Units(10000), l.Balance(Receivable("Name1"), date("1/1/2023")))
+	require.Equal(t, currency.Units(4000), l.Balance(Receivable("Name1"), date("2/1/2023")))
+	require.Equal(t, currency.Units(2700), l.Balance(Receivable("Name1"), date("3/3/2023")))
+	require.Equal(t, currency.Units(700), l.Balance(Receivable("Name1"), date("4/1/2023")))
+	require.Equal(t, currency.Units(2000), l.Balance(Receivable("Name2"), date("4/1/2023")))
+	require.Equal(t, currency.Units(0), l.Balance(Receivable("Name2"), date("5/1/2023")))
+
+	require.Equal(t, 6, len(l.Entries(Receivable("Name1"))))
+	require.Equal(t, 2, len(l.Entries(Receivable("Name2"))))
+
+	var sum currency.Amount
+	for _, amt := range l.TrialBalance(date("12/31/2023")) {
+		sum = currency.Sum(sum, amt)
+	}
+	require.True(t, sum.IsZero())
+}
+
+func TestLedgerInvalid(t *testing.T) {
+	for _, test := range []string{
+		`1/1/2023,Charge,Name1,"-$100.00",,Negative`,
+		`1/1/2023,Transfer,Name1,"$100.00",,No destination`,
+		`1/1/2023,Transfer,Name1,"$100.00",Name1,Same destination`,
+		`1/1/2023,Payment,Name1,"$100.00",,`,
+		`1/1/2023,Gift,Name1,"$100.00",,Unknown`,
+		`1/1/2023,Credit,,"$100.00",,No account`,
+	} {
+		data := header + "\n" + test
+		_, err := csv.Read[Record]("<input>", bytes.NewBufferString(data))
+		require.Error(t, err, "for %s", test)
+	}
+
+	require.Error(t, New().Post(Entry{
+		Kind: Adjustment,
+		Postings: []Posting{
+			{Account: Cash, Amount: currency.Units(1)},
+			{Account: Revenue, Amount: currency.Units(1)},
+		},
+	}))
+}
@@ -0,0 +1,45 @@
+package ledger
+
+import (
+	"fmt"
+
+	"github.com/jmacd/caspar.water/cmd/internal/billing/csv"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/currency"
+)
+
+// Record is one row of the journal CSV file, an auditable correction
+// to a customer account.
+type Record struct {
+	Date        csv.Date
+	Kind        Kind
+	AccountName string
+	Amount      currency.Amount
+
+	// ToAccount is the destination of a Transfer.
+	ToAccount string `csv:",optional"`
+
+	// Memo explains the entry.
+	Memo string `csv:",optional"`
+}
+
+func (r Record) Validate() error {
+	if err := r.Date.Validate(); err != nil {
+		return err
+	}
+	if r.AccountName == "" {
+		return fmt.Errorf("empty journal account name")
+	}
+	if r.Kind == "" {
+		return fmt.Errorf("empty journal entry kind")
+	}
+	if r.Memo == "" {
+		return fmt.Errorf("journal entry requires a memo")
+	}
+	_, err := r.Entry()
+	return err
+}
+
+// Entry converts the record to a journal entry.
+func (r Record) Entry() (Entry, error) {
+	return NewEntry(r.Date, r.Kind, r.AccountName, r.Amount, r.ToAccount, r.Memo)
+}
@@ -0,0 +1,60 @@
+package logic
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/jmacd/caspar.water/cmd/internal/billing/account"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/adhoc"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/constant"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/csv"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/currency"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/ledger"
+)
+
+// Invoice is a one-off invoice shown on a statement.
+type Invoice struct {
+	Number  string
+	Date    string
+	JobName string
+	Amount  string
+
+	amount currency.Amount
+}
+
+// postInvoices charges the one-off invoices, grouped by account name.
+func postInvoices(accts *account.Accounts, log *adhoc.Log) (map[string][]adhoc.Invoice, error) {
+	byAccount := map[string][]adhoc.Invoice{}
+	for _, inv := range log.Invoices() {
+		acct := accts.Lookup(inv.AccountName)
+		if acct == nil {
+			return nil, fmt.Errorf("invoice %d account not found: %s", inv.Number, inv.AccountName)
+		}
+		if err := acct.Enter(inv.Date, ledger.Charge, inv.Amount, inv.Memo()); err != nil {
+			return nil, err
+		}
+		byAccount[inv.AccountName] = append(byAccount[inv.AccountName], inv)
+	}
+	return byAccount, nil
+}
+
+// dueInvoices returns the account's invoices since its previous
+// statement through the bill date, and their sum.
+func dueInvoices(invs []adhoc.Invoice, prev *billed, billDate csv.Date) ([]Invoice, currency.Amount) {
+	var shown []Invoice
+	var sum currency.Amount
+	for _, inv := range invs {
+		if !since(prev, billDate, inv.Date) {
+			continue
+		}
+		shown = append(shown, Invoice{
+			Number:  strconv.Itoa(inv.Number),
+			Date:    inv.Date.Date().Format(constant.FullDateLayout),
+			JobName: inv.JobName,
+			Amount:  inv.Amount.Display(),
+			amount:  inv.Amount,
+		})
+		sum = currency.Sum(sum, inv.Amount)
+	}
+	return shown, sum
+}
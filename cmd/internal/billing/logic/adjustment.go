@@ -0,0 +1,77 @@
+package logic
+
+import (
+	"fmt"
+
+	"github.com/jmacd/caspar.water/cmd/internal/billing/account"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/adjustment"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/constant"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/csv"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/currency"
+)
+
+// Adjustment is a refund, service credit, or one-off adjustment shown
+// on a statement.
+type Adjustment struct {
+	Date   string
+	Kind   string
+	Reason string
+	Amount string // Effect on the balance
+
+	amount currency.Amount
+}
+
+// postAdjustments enters the adjustments of the adjustments file,
+// grouped by account name.
+func postAdjustments(accts *account.Accounts, adjs []adjustment.Adjustment) (map[string][]adjustment.Adjustment, error) {
+	if err := adjustment.Check(adjs); err != nil {
+		return nil, err
+	}
+	byAccount := map[string][]adjustment.Adjustment{}
+	for _, a := range adjs {
+		if accts.Lookup(a.AccountName) == nil {
+			return nil, fmt.Errorf("adjustment account not found: %s", a.AccountName)
+		}
+		e, err := a.Entry()
+		if err != nil {
+			return nil, err
+		}
+		if err := accts.Ledger().Post(e); err != nil {
+			return nil, err
+		}
+		byAccount[a.AccountName] = append(byAccount[a.AccountName], a)
+	}
+	return byAccount, nil
+}
+
+// dueAdjustments returns the account's adjustments since its previous
+// statement through the bill date, and their net effect on the
+// balance.
+func dueAdjustments(adjs []adjustment.Adjustment, prev *billed, billDate csv.Date) ([]Adjustment, currency.Amount) {
+	var shown []Adjustment
+	var net currency.Amount
+	for _, a := range adjs {
+		if !since(prev, billDate, a.Date) {
+			continue
+		}
+		amt := a.Net()
+		shown = append(shown, Adjustment{
+			Date:   a.Date.Date().Format(constant.FullDateLayout),
+			Kind:   a.Kind.Display(),
+			Reason: a.Reason,
+			Amount: amt.Display(),
+			amount: amt,
+		})
+		net = currency.Sum(net, amt)
+	}
+	return shown, net
+}
+
+// since indicates the date is after the previous statement, if any,
+// and on or before the bill date.
+func since(prev *billed, billDate, date csv.Date) bool {
+	if date.Date().After(billDate.Date()) {
+		return false
+	}
+	return prev == nil || date.Date().After(prev.date.Date())
+}
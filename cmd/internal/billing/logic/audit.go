@@ -0,0 +1,141 @@
+package logic
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/jmacd/caspar.water/cmd/internal/billing/currency"
+)
+
+// Audit lists how the cents of a cycle's total were assigned: the
+// exact cost with margin, its rounding, each party's exact share and
+// the leftover cents it received.  The parties are the effective
+// connections or, when prorated, the accounts.
+type Audit struct {
+	Cycle    string
+	Expenses currency.Amount
+	Margin   string // As entered
+	Exact    string // Expenses with margin, before rounding
+	Rounding currency.Rounding
+	Total    currency.Amount
+	Billed   currency.Amount // Sum of the parties, equal to Total
+
+	// Metered cycles are not allocated, each account pays for
+	// its consumption.
+	Metered bool
+
+	Parties []AuditParty
+	Cents   []AuditCent
+}
+
+// AuditParty is one party's share of the total.
+type AuditParty struct {
+	Party       int
+	AccountName string
+	Weight      int64
+	Exact       string          // Exact share
+	Down        currency.Amount // Exact share rounded down
+	Leftover    int             // Leftover cents received
+	Amount      currency.Amount
+}
+
+// AuditCent is the assignment of one leftover cent.
+type AuditCent struct {
+	Cent        int
+	Party       int
+	AccountName string
+}
+
+// exactText displays an exact number of cents in dollars, to a
+// hundredth of a cent.
+func exactText(cents *big.Rat) string {
+	return "$" + new(big.Rat).Quo(cents, big.NewRat(100, 1)).FloatString(4)
+}
+
+// Audit reports the allocation of the cycle's total.
+func (cs *CompanyStatement) Audit() Audit {
+	div := cs.division
+	a := Audit{
+		Cycle:    cs.Name(),
+		Expenses: cs.Expenses.Total(),
+		Margin:   currency.Decimal(cs.Expenses.Margin).FloatString(4),
+		Exact:    exactText(div.exact),
+		Rounding: div.rounding,
+		Total:    div.total,
+		Metered:  div.rates != nil,
+	}
+	if a.Rounding == "" {
+		a.Rounding = currency.RoundDown
+	}
+	if a.Metered {
+		return a
+	}
+	for i, w := range div.alloc.Weights {
+		p := AuditParty{
+			Party:       i + 1,
+			AccountName: div.parties[i],
+			Weight:      w,
+			Exact:       exactText(div.alloc.Exact[i]),
+			Down:        currency.RoundDown.Round(div.alloc.Exact[i]),
+			Leftover:    div.alloc.Received(i),
+			Amount:      div.alloc.Parts[i],
+		}
+		a.Parties = append(a.Parties, p)
+		a.Billed = currency.Sum(a.Billed, p.Amount)
+	}
+	for k, i := range div.alloc.Leftover {
+		a.Cents = append(a.Cents, AuditCent{
+			Cent:        k + 1,
+			Party:       i + 1,
+			AccountName: div.parties[i],
+		})
+	}
+	return a
+}
+
+// WriteCSV writes the audit items, a blank line, the parties, a blank
+// line, and the leftover cents.
+func (a Audit) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"Item", "Value"})
+	_ = cw.WriteAll([][]string{
+		{"Cycle", a.Cycle},
+		{"Expenses", a.Expenses.Display()},
+		{"Margin", a.Margin},
+		{"Exact total", a.Exact},
+		{"Rounding", string(a.Rounding)},
+		{"Total", a.Total.Display()},
+	})
+	if a.Metered {
+		_ = cw.Write([]string{"Method", "Metered"})
+		cw.Flush()
+		return cw.Error()
+	}
+	_ = cw.Write([]string{"Billed", a.Billed.Display()})
+	_ = cw.Write(nil)
+	_ = cw.Write([]string{"Party", "Account Name", "Weight", "Exact Share", "Rounded Down", "Leftover Cents", "Amount"})
+	for _, p := range a.Parties {
+		_ = cw.Write([]string{
+			fmt.Sprint(p.Party),
+			p.AccountName,
+			fmt.Sprint(p.Weight),
+			p.Exact,
+			p.Down.Display(),
+			fmt.Sprint(p.Leftover),
+			p.Amount.Display(),
+		})
+	}
+	_ = cw.Write(nil)
+	_ = cw.Write([]string{"Leftover Cent", "Party", "Account Name"})
+	for _, c := range a.Cents {
+		_ = cw.Write([]string{
+			fmt.Sprint(c.Cent),
+			fmt.Sprint(c.Party),
+			c.AccountName,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,190 @@
+package logic
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/jmacd/caspar.water/cmd/internal/billing/business"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/email"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/user"
+	"github.com/spf13/afero"
+)
+
+// Email body templates are read from the statements directory.
+const (
+	EmailTextFile = "email.txt"
+	EmailHTMLFile = "email.html"
+)
+
+// defaultEmailText is used without an email.txt template.
+const defaultEmailText = `Dear {{.User.UserName}},
+
+Your {{.Business.Name}} statement for {{.StartFullDate}} through {{.CloseFullDate}} is attached.
+
+Amount due: {{.TotalDue}}
+
+Questions? {{.Business.Contact}}
+`
+
+// EmailTemplates are the bodies of emailed statements.  HTML is
+// optional.
+type EmailTemplates struct {
+	Text *template.Template
+	HTML *htmltemplate.Template
+}
+
+// EmailVars are the email template variables, the statement's
+// variables with the user and business.
+type EmailVars struct {
+	*Vars
+	User     user.User
+	Business business.Business
+}
+
+// ReadEmailTemplates parses the email templates in the statements
+// directory.
+func ReadEmailTemplates(dir string, fs afero.Fs) (EmailTemplates, error) {
+	var t EmailTemplates
+
+	text := defaultEmailText
+	if data, err := afero.ReadFile(fs, path.Join(dir, EmailTextFile)); err == nil {
+		text = string(data)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return t, err
+	}
+	var err error
+	if t.Text, err = template.New(EmailTextFile).Parse(text); err != nil {
+		return t, err
+	}
+
+	data, err := afero.ReadFile(fs, path.Join(dir, EmailHTMLFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return t, nil
+	} else if err != nil {
+		return t, err
+	}
+	t.HTML, err = htmltemplate.New(EmailHTMLFile).Parse(string(data))
+	return t, err
+}
+
+// FindCycle returns the cycle by its closing month, e.g.,
+// "2025-Sep", or the last cycle when the name is empty.
+func (r *Result) FindCycle(name string) (*CompanyStatement, error) {
+	if name == "" && len(r.Cycles) != 0 {
+		return r.Cycles[len(r.Cycles)-1], nil
+	}
+	for _, cs := range r.Cycles {
+		if cs.Name() == name {
+			return cs, nil
+		}
+	}
+	return nil, fmt.Errorf("cycle not found: %q", name)
+}
+
+// Deliver writes an RFC 5322 .eml file beside each of the cycle's
+// statement PDFs, written by Output, and sends it when the sender is
+// not nil.  Statements of accounts without an email address are not
+// sent.  A payer's consolidated statement is sent to the payer in
+// place of its accounts' statements.  Every statement is listed in
+// the returned deliveries; the first send error stops delivery.
+func Deliver(result *Result, cs *CompanyStatement, tmpls EmailTemplates, sender *email.Sender, now time.Time) ([]email.Delivery, error) {
+	consolidated := cs.consolidated()
+
+	var log []email.Delivery
+	deliver := func(u user.User, vars *Vars, pdfPath string) error {
+		d := email.Delivery{
+			Date:        now,
+			AccountName: u.AccountName,
+			To:          u.Email,
+			Statement:   vars.InvoiceName(),
+		}
+		switch {
+		case consolidated[u.AccountName]:
+			d.Status = email.Consolidated
+			log = append(log, d)
+			return nil
+		case u.Email == "":
+			d.Status = email.NoAddress
+			log = append(log, d)
+			return nil
+		case result.Business.Email == "":
+			return fmt.Errorf("business email is required to send statements")
+		}
+
+		msg, err := statementMessage(result.Business, u, vars, pdfPath, tmpls, now)
+		if err != nil {
+			return fmt.Errorf("%s: %w", u.AccountName, err)
+		}
+
+		d.File = strings.TrimSuffix(pdfPath, ".pdf") + ".eml"
+		var buf bytes.Buffer
+		if err := msg.Write(&buf); err != nil {
+			return fmt.Errorf("%s: %w", u.AccountName, err)
+		}
+		if err := os.WriteFile(d.File, buf.Bytes(), 0644); err != nil {
+			return err
+		}
+
+		d.Status = email.Written
+		if sender != nil {
+			if err := sender.Send(msg); err != nil {
+				return fmt.Errorf("send %s: %w", u.AccountName, err)
+			}
+			d.Status = email.Sent
+		}
+		log = append(log, d)
+		return nil
+	}
+
+	for _, stmt := range cs.Statements {
+		if err := deliver(stmt.User, stmt.Vars, stmt.PdfPath); err != nil {
+			return log, err
+		}
+	}
+	for _, ps := range cs.Payers {
+		if err := deliver(ps.User, ps.Vars, ps.PdfPath); err != nil {
+			return log, err
+		}
+	}
+	return log, nil
+}
+
+func statementMessage(bus business.Business, u user.User, stmtVars *Vars, pdfPath string, tmpls EmailTemplates, now time.Time) (email.Message, error) {
+	vars := EmailVars{
+		Vars:     stmtVars,
+		User:     u,
+		Business: bus,
+	}
+	pdf, err := os.ReadFile(pdfPath)
+	if err != nil {
+		return email.Message{}, err
+	}
+
+	var text, html bytes.Buffer
+	if err := tmpls.Text.Execute(&text, vars); err != nil {
+		return email.Message{}, err
+	}
+	if tmpls.HTML != nil {
+		if err := tmpls.HTML.Execute(&html, vars); err != nil {
+			return email.Message{}, err
+		}
+	}
+	return email.Message{
+		From:           bus.Email,
+		To:             u.Email,
+		Date:           now,
+		Subject:        bus.Name + " statement " + stmtVars.InvoiceName(),
+		Text:           text.String(),
+		HTML:           html.String(),
+		AttachmentName: filepath.Base(pdfPath),
+		Attachment:     pdf,
+	}, nil
+}
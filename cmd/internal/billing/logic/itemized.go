@@ -0,0 +1,60 @@
+package logic
+
+import (
+	"github.com/jmacd/caspar.water/cmd/internal/billing/constant"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/expense"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/invoice"
+)
+
+// ExpenseItem is one line of the statement's expense appendix.
+type ExpenseItem struct {
+	Date        string
+	Vendor      string
+	Category    string
+	Amount      string
+	Description string
+}
+
+// expenseItems lists an itemized cycle's expenses for the appendix.
+func expenseItems(cycle expense.Cycle) []ExpenseItem {
+	var items []ExpenseItem
+	for _, it := range cycle.Items {
+		items = append(items, ExpenseItem{
+			Date:        it.Date.Date().Format(constant.CsvLayout),
+			Vendor:      it.Vendor,
+			Category:    string(it.Category),
+			Amount:      it.Amount.Display(),
+			Description: it.Description,
+		})
+	}
+	return items
+}
+
+// appendix is the table of itemized expenses.  Taxes and insurance
+// are the yearly items, of which the statement bills one cycle's
+// share.
+func (vars *Vars) appendix() invoice.Content {
+	if len(vars.Items) == 0 {
+		return nil
+	}
+	var rows [][]string
+	for _, it := range vars.Items {
+		rows = append(rows, []string{
+			it.Date,
+			it.Vendor,
+			it.Category,
+			it.Description,
+			it.Amount,
+		})
+	}
+	return invoice.Content{{
+		Header: []string{
+			"Itemized expenses",
+			"Vendor",
+			"Category",
+			"Description",
+			"Amount",
+		},
+		Rows: rows,
+	}}
+}
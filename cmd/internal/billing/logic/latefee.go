@@ -0,0 +1,55 @@
+package logic
+
+import (
+	"github.com/jmacd/caspar.water/cmd/internal/billing/account"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/constant"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/csv"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/currency"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/latefee"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/ledger"
+)
+
+// billed is the amount due on an account's previous statement.
+type billed struct {
+	date csv.Date
+	due  currency.Amount
+}
+
+// assessLateFee posts a late fee when the amount due on the
+// account's previous statement remains unpaid at this bill date,
+// after the grace period.  Unbilled is the part of the balance that
+// is this statement's charges.  It returns the overdue balance, the
+// fee, and the waivers posted since the previous statement.
+func assessLateFee(policy *latefee.Policy, acct *account.Account, prev *billed, billDate csv.Date, unbilled currency.Amount) (overdue, fee, waived currency.Amount, _ error) {
+	for _, e := range acct.Entries() {
+		if e.Kind != ledger.Waiver || e.Date.Date().After(billDate.Date()) {
+			continue
+		}
+		if prev != nil && !e.Date.Date().After(prev.date.Date()) {
+			continue
+		}
+		waived = currency.Difference(waived, acct.Net(e))
+	}
+
+	if policy == nil || prev == nil {
+		return
+	}
+	dueDate := prev.date.Date().AddDate(0, 0, policy.GraceDays)
+	if !billDate.Date().After(dueDate) {
+		return
+	}
+
+	overdue = currency.Difference(currency.Sum(acct.Balance(billDate), waived), unbilled)
+	if prev.due.Units() < overdue.Units() {
+		overdue = prev.due
+	}
+	if overdue.Units() < 0 {
+		overdue = currency.Units(0)
+	}
+	fee = policy.Assess(overdue)
+	if fee.IsZero() {
+		return
+	}
+	memo := "Late fee on the balance due " + prev.date.Date().Format(constant.FullDateLayout)
+	return overdue, fee, waived, acct.Enter(billDate, ledger.LateFee, fee, memo)
+}
@@ -1,38 +1,38 @@
 package logic
 
-// TODO Show the service address, otherwise we get 4 near-identical statements.
-
 import (
 	"bytes"
 	"fmt"
+	"math/big"
 	"math/rand"
 	"os"
 	"path"
+	"strings"
 	"text/template"
 
 	"github.com/jmacd/caspar.water/cmd/internal/billing/account"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/adhoc"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/adjustment"
 	"github.com/jmacd/caspar.water/cmd/internal/billing/business"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/capital"
 	"github.com/jmacd/caspar.water/cmd/internal/billing/constant"
 	"github.com/jmacd/caspar.water/cmd/internal/billing/csv"
 	"github.com/jmacd/caspar.water/cmd/internal/billing/currency"
 	"github.com/jmacd/caspar.water/cmd/internal/billing/expense"
 	"github.com/jmacd/caspar.water/cmd/internal/billing/invoice"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/latefee"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/ledger"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/meter"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/payer"
 	"github.com/jmacd/caspar.water/cmd/internal/billing/payment"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/projection"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/rate"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/registry"
 	"github.com/jmacd/caspar.water/cmd/internal/billing/user"
-	"github.com/jmacd/maroto/pkg/color"
-	"github.com/jmacd/maroto/pkg/pdf"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/weight"
 	"github.com/spf13/afero"
 )
 
-var (
-	// Caspar water == blue.
-	cwcColor = color.Color{
-		Red:   10,
-		Green: 10,
-		Blue:  150,
-	}
-)
-
 type (
 	Inputs struct {
 		UsersFile     string
@@ -40,12 +40,84 @@ type (
 		CyclesFile    string
 		PaymentsFile  string
 		StatementsDir string
+
+		// MetersFile and RatesFile are optional, required
+		// for Metered cycles.
+		MetersFile string
+		RatesFile  string
+
+		// JournalFile is optional, it lists corrections posted
+		// to customer accounts.
+		JournalFile string
+
+		// AdjustmentsFile is optional, it lists refunds,
+		// service credits, and one-off adjustments shown on
+		// the next statement.
+		AdjustmentsFile string
+
+		// InvoicesFile is optional, it lists one-off invoices
+		// charged to customer accounts, see package adhoc.  A
+		// missing file has no invoices.
+		InvoicesFile string
+
+		// PayersFile is optional, it lists payers responsible
+		// for several accounts.
+		PayersFile string
+
+		// LateFeeFile is optional, it has one row describing
+		// the late fee policy.
+		LateFeeFile string
+
+		// ExpensesFile is optional, it lists the expense items
+		// of Itemized cycles.
+		ExpensesFile string
+
+		// CapitalFile is optional, it lists capital purchases
+		// amortized over their useful life.
+		CapitalFile string
+
+		// WeightsFile is optional, it assigns accounts a
+		// weight or a meter size by effective date.
+		// MeterSizesFile lists the equivalent dwelling units
+		// of each meter size.
+		WeightsFile    string
+		MeterSizesFile string
+
+		// RegistryFile is optional, it lists the issued
+		// statements of closed cycles.  A missing file is an
+		// empty registry.
+		RegistryFile string
+
+		// LayoutFile is optional, it describes the statement
+		// layout, see invoice.Layout.  HTML writes each
+		// statement as HTML beside the PDF.
+		LayoutFile string
+		HTML       bool
 	}
 
 	Vars struct {
 		tmpl  *template.Template
 		cycle expense.Cycle
 
+		owes         currency.Amount
+		priorBalance currency.Amount
+		totalDue     currency.Amount
+		lateFee      currency.Amount
+		waived       currency.Amount
+		trueUp       currency.Amount
+		adjusted     currency.Amount
+		invoiced     currency.Amount
+		fraction     *big.Rat
+		gallons      meter.Gallons
+
+		// Account
+		AccountName    string
+		ServiceAddress string
+
+		// PeriodName is Monthly, Quarterly, Semi-annual,
+		// or Annual.
+		PeriodName string
+
 		// Timestamps
 		StartFullDate       string
 		StartMonthDate      string
@@ -65,7 +137,7 @@ type (
 		Margin   string
 
 		// Money top shelf
-		TotalCost    string // Semi-annual period
+		TotalCost    string // Total for the period
 		Pay          string // Share of period total
 		PriorBalance string // Unpaid balance
 		TotalDue     string // Pay + PriorBalance
@@ -76,6 +148,67 @@ type (
 		Utilities  string
 		Taxes      string
 		Insurance  string
+		Capital    string // Share of capital purchases, empty without a capital file
+
+		// Metered billing
+		Metered          bool
+		OpenReading      string // Meter register at the start
+		OpenReadingDate  string
+		CloseReading     string // Meter register at the close
+		CloseReadingDate string
+		Gallons          string // Consumption for the period
+		BaseCharge       string // Fixed charge
+		UsageCharge      string // Sum of the tiers
+		Tiers            []rate.Tier
+
+		// Late fees
+		LateFeePolicy string // Explanation of the policy
+		Overdue       string // Unpaid balance from the prior statement
+		LateFee       string // Fee assessed on this statement
+		LateFeeWaived string // Waivers since the prior statement
+		HasLateFee    bool
+
+		// Projected expenses
+		Projected        bool
+		ProjectionMethod string
+		Projection       []projection.Estimate
+
+		// Expense items, when the business prints the
+		// appendix
+		Items []ExpenseItem
+
+		// True-ups of estimated statements
+		TrueUps   []TrueUp
+		TrueUp    string // Sum of the true-ups
+		HasTrueUp bool
+
+		// Refunds, credits, and adjustments since the prior
+		// statement.
+		Adjustments   []Adjustment
+		HasAdjustment bool
+
+		// One-off invoices since the prior statement.
+		Invoices   []Invoice
+		HasInvoice bool
+
+		// Services lists each account on a payer's
+		// consolidated statement.
+		Services []*Vars
+
+		// Sections are the account's additional services, and
+		// Service names the primary service when there are
+		// sections.  Pay is the primary service's share.
+		Service  string
+		Sections []*Section
+
+		// Proration, when service starts or ends during the
+		// period.
+		Prorated    bool
+		ServiceFrom string // First day of service in the period
+		ServiceTo   string // Last day of service in the period
+		ServiceDays int
+		PeriodDays  int
+		FinalBill   bool
 	}
 
 	UserStatement struct {
@@ -86,19 +219,49 @@ type (
 
 	CompanyStatement struct {
 		Expenses   expense.Cycle
+		Projection *projection.Cycle
 		Template   *template.Template
 		Statements []*UserStatement
+		Payers     []*PayerStatement
+
+		// Amortizations are the capital purchases active in
+		// the cycle.
+		Amortizations []capital.Share
+
+		division *division
 	}
 
 	Result struct {
-		Accounts *account.Accounts
-		Business business.Business
-		Cycles   []*CompanyStatement
+		Accounts    *account.Accounts
+		Business    business.Business
+		Cycles      []*CompanyStatement
+		Projections projection.Report
+		Registry    *registry.Registry
+		Purchases   []capital.Purchase
+
+		// PendingRevisions are the revised cycles whose
+		// true-ups wait for the next statement.
+		PendingRevisions []string
+
+		// Layout renders the statements, and HTML
+		// indicates that each is also written as HTML.
+		Layout *invoice.Layout
+		HTML   bool
 	}
 )
 
 var _ invoice.Document = &Vars{}
 
+// fractionText displays an exact fraction to four places, and
+// percentText as a percentage to two.
+func fractionText(r *big.Rat) string {
+	return r.FloatString(4)
+}
+
+func percentText(r *big.Rat) string {
+	return new(big.Rat).Mul(r, big.NewRat(100, 1)).FloatString(2) + "%"
+}
+
 func (vars *Vars) FullDate() string {
 	return vars.IssueFullDate
 }
@@ -108,6 +271,9 @@ func (vars *Vars) InvoiceName() string {
 	if vars.Estimated {
 		n += "-Estimate"
 	}
+	if vars.FinalBill {
+		n += "-Final"
+	}
 	return n
 }
 
@@ -121,52 +287,293 @@ func (vars *Vars) BodyText() (string, error) {
 	return textBuf.String(), nil
 }
 
-func getPayment(user user.User, charges []currency.Amount, cycle expense.Cycle) (currency.Amount, float64, int, []currency.Amount) {
-	if cycle.Inactive.Contains(user) {
-		return currency.Units(0), 0, 0, charges
+// getWeight returns the user's number of effective connections,
+// see weigh.
+func getWeight(user user.User, cycle expense.Cycle) int {
+	return cycle.Weights[user.AccountName]
+}
+
+// share is one account's portion of a cycle.
+type share struct {
+	owes     currency.Amount
+	fraction *big.Rat
+	weight   int
+	metered  rate.Charge
+	usage    meter.Usage
+
+	// days is the days of service of periodDays, less when
+	// the share is prorated.
+	days       int
+	periodDays int
+
+	// rounding is the part of owes above the base charge for
+	// the weight, the account's $0.01 rounding differences.
+	rounding currency.Amount
+}
+
+// division is the cost of a cycle divided among the users billed
+// for the period.
+type division struct {
+	// exact is the cost with margin in cents, and total is
+	// exact rounded by the business's rounding mode.  The total
+	// is split into charges for each effective connection; base
+	// is the smallest charge.
+	exact    *big.Rat
+	rounding currency.Rounding
+	total    currency.Amount
+	base     currency.Amount
+
+	shares map[string]share
+
+	// alloc divides the total among its parties, the
+	// connections or, when prorated, the accounts; parties
+	// names the account of each.  Metered cycles are not
+	// allocated.
+	alloc   currency.Allocation
+	parties []string
+
+	// rates is the schedule of a Metered cycle.
+	rates rate.Schedule
+}
+
+// computeShares divides the cost of a cycle among the users billed
+// for the period.  The cost with margin is exact, rounded once to the
+// cent, and allocated in exact proportion to the weights; the leftover
+// cents are assigned one at a time, see currency.Allocate.
+func computeShares(cycle expense.Cycle, users []user.User, readings *meter.Readings, blocks []rate.Block, rounding currency.Rounding) (*division, error) {
+	closeMonthDate := cycle.PeriodStart.Closing().Date().Format(constant.InvoiceDateLayout)
+
+	sumExpenses := cycle.Total()
+
+	savingsRate := new(big.Rat).Add(big.NewRat(1, 1), currency.Decimal(cycle.Margin))
+	exact := new(big.Rat).Mul(sumExpenses.Rat(), savingsRate)
+	total := rounding.Round(exact)
+
+	var rates rate.Schedule
+	if cycle.Method == expense.MeteredMethod {
+		if readings == nil {
+			return nil, fmt.Errorf("metered cycle %v requires meter readings", closeMonthDate)
+		}
+		var err error
+		if rates, err = rate.ScheduleFor(blocks, cycle.PeriodStart); err != nil {
+			return nil, fmt.Errorf("rate schedule %v: %w", closeMonthDate, err)
+		}
+		if rates == nil {
+			return nil, fmt.Errorf("metered cycle %v has no rate schedule", closeMonthDate)
+		}
 	}
 
-	pay := charges[0]
-	charges = charges[1:]
-	weight := 1
+	div := &division{
+		exact:    exact,
+		rounding: rounding,
+		total:    total,
+		rates:    rates,
+	}
+	if prorated(cycle, users) {
+		prorate(div, cycle, users)
+	} else if err := allocate(div, cycle, users); err != nil {
+		return nil, err
+	}
+	shares := div.shares
 
-	if user.Commercial && cycle.Method == expense.NormalMethod {
-		weight = 2
-		pay = currency.Sum(pay, charges[0])
-		charges = charges[1:]
+	if rates != nil {
+		for _, user := range users {
+			sh, ok := shares[user.AccountName]
+			if !ok || sh.weight == 0 {
+				continue
+			}
+			usage, err := readings.Usage(user.AccountName, cycle.PeriodStart)
+			if err != nil {
+				return nil, err
+			}
+			sh.usage = usage
+			sh.metered = rates.Compute(cycle.BaseCharge, sh.weight, usage.Gallons())
+			sh.metered.Base = prorateUnits(sh.metered.Base, sh.days, sh.periodDays)
+			sh.owes = sh.metered.Total()
+			shares[user.AccountName] = sh
+		}
 	}
+	return div, nil
+}
 
-	fraction := float64(weight) / float64(cycle.EffectiveConnections)
-	return pay, fraction, weight, charges
+// leftoverOrder returns the order in which n parties receive the
+// leftover cents of a cycle: order[k] is the party of the k'th cent.
+// The order is a deterministic shuffle, so the cents are shared by
+// different users.
+func leftoverOrder(cycle expense.Cycle, n int) []int {
+	perm := make([]int, n)
+	for i := range perm {
+		perm[i] = i
+	}
+	rand.New(rand.NewSource(cycle.PeriodStart.Closing().Date().UnixNano())).Shuffle(n, func(i, j int) {
+		perm[i], perm[j] = perm[j], perm[i]
+	})
+	order := make([]int, n)
+	for party, k := range perm {
+		order[k] = party
+	}
+	return order
+}
+
+// allocate divides the total among the effective connections, each
+// account billed for the charges of its weight.  The leftover cents
+// go to connections in the order of leftoverOrder.
+func allocate(div *division, cycle expense.Cycle, users []user.User) error {
+	n := cycle.EffectiveConnections
+
+	weights := make([]int64, n)
+	for conn := range weights {
+		weights[conn] = 1
+	}
+	div.alloc = currency.Allocate(div.total, weights, leftoverOrder(cycle, n))
+	div.base = currency.RoundDown.Round(new(big.Rat).Quo(div.total.Rat(), big.NewRat(int64(n), 1)))
+	div.parties = make([]string, n)
+
+	div.shares = map[string]share{}
+	next := 0
+	for _, user := range users {
+		if !user.Serves(cycle.PeriodStart) {
+			continue
+		}
+		w := getWeight(user, cycle)
+		if next+w > n {
+			return fmt.Errorf("logic error: %d connections exceed the effective connections %d", next+w, n)
+		}
+		sh := share{
+			owes:     currency.Sum(div.alloc.Parts[next : next+w]...),
+			fraction: big.NewRat(int64(w), int64(n)),
+			weight:   w,
+		}
+		for conn := next; conn < next+w; conn++ {
+			div.parties[conn] = user.AccountName
+		}
+		next += w
+		sh.rounding = currency.Difference(sh.owes, currency.Units(div.base.Units()*int64(w)))
+		sh.days, sh.periodDays = user.ServiceDays(cycle.PeriodStart)
+		div.shares[user.AccountName] = sh
+	}
+	// The effective connections are the sum of the weights, see
+	// weigh, so every charge is billed.
+	if next != n {
+		return fmt.Errorf("logic error: %d connections unbilled in cycle %v", n-next,
+			cycle.PeriodStart.Closing().Date().Format(constant.InvoiceDateLayout))
+	}
+	return nil
 }
 
 func Logic(inputs Inputs, fs afero.Fs) (*Result, error) {
 	accts := account.NewAccounts()
 
+	// Business, whose billing period schedule the periods of
+	// the other files are bound to.
+	business, err := csv.ReadFile[business.Business](inputs.BusinessFile, fs)
+	if err != nil {
+		return nil, err
+	}
+	if len(business) != 1 {
+		return nil, fmt.Errorf("business file should have one row: %d", len(business))
+	}
+	schedule := business[0].Schedule()
+	if err := schedule.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", inputs.BusinessFile, err)
+	}
+
 	// Users
 	users, err := csv.ReadFile[user.User](inputs.UsersFile, fs)
 	if err != nil {
 		return nil, err
 	}
+	for i := range users {
+		if err := users[i].Bind(schedule); err != nil {
+			return nil, fmt.Errorf("%s: account %s: %w", inputs.UsersFile, users[i].AccountName, err)
+		}
+	}
 
-	// Business
-	business, err := csv.ReadFile[business.Business](inputs.BusinessFile, fs)
+	// Expense cycles
+	rows, err := csv.ReadFile[expense.Cycle](inputs.CyclesFile, fs)
 	if err != nil {
 		return nil, err
 	}
-	if len(business) != 1 {
-		return nil, fmt.Errorf("business file should have one row: %d", len(business))
+	for i := range rows {
+		if err := rows[i].Bind(schedule); err != nil {
+			return nil, fmt.Errorf("%s: period %s: %w", inputs.CyclesFile,
+				rows[i].PeriodStart.Starting().Date().Format(constant.CsvLayout), err)
+		}
+	}
+	rows, services, err := separateServices(rows, business[0].Service, users, schedule.PerYear())
+	if err != nil {
+		return nil, err
 	}
 
-	// Expense cycles
-	cycles, err := csv.ReadFile[expense.Cycle](inputs.CyclesFile, fs)
+	// Itemized expenses
+	if inputs.ExpensesFile != "" {
+		items, err := csv.ReadFile[expense.Item](inputs.ExpensesFile, fs)
+		if err != nil {
+			return nil, err
+		}
+		if err := expense.Itemize(rows, items, schedule.PerYear()); err != nil {
+			return nil, err
+		}
+	}
+
+	projections, err := projection.Project(rows, schedule.PerYear())
 	if err != nil {
 		return nil, err
 	}
 
-	if err := expense.SplitAnnual(cycles); err != nil {
+	cycles, revisions, err := expense.Revisions(rows, schedule.PerYear())
+	if err != nil {
 		return nil, err
 	}
+	for _, svc := range services {
+		if err := svc.check(cycles); err != nil {
+			return nil, err
+		}
+	}
+
+	// Capital purchases
+	var purchases []capital.Purchase
+	if inputs.CapitalFile != "" {
+		if purchases, err = csv.ReadFile[capital.Purchase](inputs.CapitalFile, fs); err != nil {
+			return nil, err
+		}
+	}
+	amortizations := make([][]capital.Share, len(cycles))
+	for i := range cycles {
+		amortizations[i] = capital.Amortize(purchases, cycles[i].PeriodStart)
+		cycles[i].Capital = capital.Expense(amortizations[i])
+	}
+	for i := range revisions {
+		revisions[i].Actual.Capital = cycles[revisions[i].Original].Capital
+	}
+
+	// Connection weights
+	var assigns []weight.Assignment
+	var equivs []weight.Equivalent
+	if inputs.WeightsFile != "" {
+		if assigns, err = csv.ReadFile[weight.Assignment](inputs.WeightsFile, fs); err != nil {
+			return nil, err
+		}
+	}
+	if inputs.MeterSizesFile != "" {
+		if equivs, err = csv.ReadFile[weight.Equivalent](inputs.MeterSizesFile, fs); err != nil {
+			return nil, err
+		}
+	}
+	weights, err := weight.NewSchedule(equivs, assigns)
+	if err != nil {
+		return nil, err
+	}
+	for i := range cycles {
+		if err := weigh(&cycles[i], users, weights); err != nil {
+			return nil, err
+		}
+	}
+	for i := range revisions {
+		if err := weigh(&revisions[i].Actual, users, weights); err != nil {
+			return nil, err
+		}
+	}
 
 	// Payments ledger
 	payments, err := csv.ReadFile[payment.Payment](inputs.PaymentsFile, fs)
@@ -174,29 +581,155 @@ func Logic(inputs Inputs, fs afero.Fs) (*Result, error) {
 		return nil, err
 	}
 
+	// Meter readings and volumetric rates
+	var readings *meter.Readings
+	var blocks []rate.Block
+
+	if inputs.MetersFile != "" {
+		list, err := csv.ReadFile[meter.Reading](inputs.MetersFile, fs)
+		if err != nil {
+			return nil, err
+		}
+		if readings, err = meter.NewReadings(list); err != nil {
+			return nil, err
+		}
+	}
+	if inputs.RatesFile != "" {
+		if blocks, err = csv.ReadFile[rate.Block](inputs.RatesFile, fs); err != nil {
+			return nil, err
+		}
+		for i := range blocks {
+			if err := blocks[i].Bind(schedule); err != nil {
+				return nil, fmt.Errorf("%s: period %s: %w", inputs.RatesFile,
+					blocks[i].PeriodStart.Starting().Date().Format(constant.CsvLayout), err)
+			}
+		}
+	}
+
 	for _, user := range users {
 		accts.Register(user)
 	}
 
+	// Payers
+	var payers []payer.Payer
+	if inputs.PayersFile != "" {
+		if payers, err = csv.ReadFile[payer.Payer](inputs.PayersFile, fs); err != nil {
+			return nil, err
+		}
+	}
+	payerPays, err := newPayerPayments(accts, payers)
+	if err != nil {
+		return nil, err
+	}
+
 	for _, pay := range payments {
+		if payerPays.add(pay) {
+			continue
+		}
 		acct := accts.Lookup(pay.AccountName)
 		if acct == nil {
 			return nil, fmt.Errorf("payment account not found: %s", pay.AccountName)
 		}
-		acct.EnterPayment(pay)
+		if err := acct.EnterPayment(pay); err != nil {
+			return nil, err
+		}
+	}
+
+	// Late fee policy
+	var lateFees *latefee.Policy
+	if inputs.LateFeeFile != "" {
+		policy, err := csv.ReadFile[latefee.Policy](inputs.LateFeeFile, fs)
+		if err != nil {
+			return nil, err
+		}
+		if len(policy) != 1 {
+			return nil, fmt.Errorf("late fee file should have one row: %d", len(policy))
+		}
+		lateFees = &policy[0]
+	}
+	lastBill := map[string]*billed{}
+
+	// Journal corrections
+	if inputs.JournalFile != "" {
+		records, err := csv.ReadFile[ledger.Record](inputs.JournalFile, fs)
+		if err != nil {
+			return nil, err
+		}
+		for _, rec := range records {
+			if err := accts.Post(rec); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Refunds, credits, and adjustments
+	var adjustments map[string][]adjustment.Adjustment
+	if inputs.AdjustmentsFile != "" {
+		adjs, err := csv.ReadFile[adjustment.Adjustment](inputs.AdjustmentsFile, fs)
+		if err != nil {
+			return nil, err
+		}
+		if adjustments, err = postAdjustments(accts, adjs); err != nil {
+			return nil, fmt.Errorf("%s: %w", inputs.AdjustmentsFile, err)
+		}
+	}
+
+	// One-off invoices
+	var invoices map[string][]adhoc.Invoice
+	if inputs.InvoicesFile != "" {
+		invs, err := adhoc.Read(inputs.InvoicesFile, fs)
+		if err != nil {
+			return nil, err
+		}
+		if invoices, err = postInvoices(accts, invs); err != nil {
+			return nil, fmt.Errorf("%s: %w", inputs.InvoicesFile, err)
+		}
+	}
+
+	// Issued statements
+	issued := registry.New()
+	if inputs.RegistryFile != "" {
+		if issued, err = registry.Read(inputs.RegistryFile, fs); err != nil {
+			return nil, err
+		}
+	}
+
+	// Statement layout
+	layout := invoice.DefaultLayout()
+	if inputs.LayoutFile != "" {
+		if layout, err = invoice.ReadLayout(inputs.LayoutFile, fs); err != nil {
+			return nil, err
+		}
 	}
 
 	result := &Result{
-		Accounts: accts,
-		Business: business[0],
+		Accounts:    accts,
+		Business:    business[0],
+		Projections: projections,
+		Registry:    issued,
+		Purchases:   purchases,
+		Layout:      layout,
+		HTML:        inputs.HTML,
+	}
+
+	trueUps := &trueUps{
+		revisions: revisions,
+		rounding:  result.Business.Rounding,
 	}
 
-	for _, cycle := range cycles {
+	for cycleNo, cycle := range cycles {
 		compStmt := &CompanyStatement{
-			Expenses: cycle,
+			Expenses:      cycle,
+			Amortizations: amortizations[cycleNo],
 		}
 		result.Cycles = append(result.Cycles, compStmt)
 
+		for i := range projections {
+			if projections[i].PeriodStart.Starting().Date().Equal(cycle.PeriodStart.Starting().Date()) {
+				compStmt.Projection = &projections[i]
+			}
+		}
+
 		startFullDate := cycle.PeriodStart.Starting().Date().Format(constant.FullDateLayout)
 		closeFullDate := cycle.PeriodStart.Closing().Date().Format(constant.FullDateLayout)
 		startMonthDate := cycle.PeriodStart.Starting().Date().Format(constant.InvoiceDateLayout)
@@ -210,54 +743,83 @@ func Logic(inputs Inputs, fs afero.Fs) (*Result, error) {
 			return nil, fmt.Errorf("mkdir: %s: %w", outputPath, err)
 		}
 
-		compStmt.Template = template.New(inputText)
-
-		if _, err = compStmt.Template.ParseFS(afero.NewIOFS(fs), inputTextPath); err != nil {
+		text, err := afero.ReadFile(fs, inputTextPath)
+		if err != nil {
 			return nil, fmt.Errorf("%s: no statement template found: %w", inputTextPath, err)
 		}
+		if compStmt.Template, err = template.New(inputText).Parse(string(text)); err != nil {
+			return nil, fmt.Errorf("%s: %w", inputTextPath, err)
+		}
 
-		sumExpenses := currency.Sum(
-			cycle.Operations,
-			cycle.Utilities,
-			cycle.Taxes,
-			cycle.Insurance,
-		)
+		sumExpenses := cycle.Total()
 
 		savingsRate := 1 + cycle.Margin
-		total := sumExpenses.Scale(savingsRate)
 
-		// Check that effective connection count is not exceeded
-		realCount := 0
-		for _, user := range users {
-			if cycle.Inactive.Contains(user) {
+		div, err := computeShares(cycle, users, readings, blocks, result.Business.Rounding)
+		if err != nil {
+			return nil, err
+		}
+		compStmt.division = div
+		rates := div.rates
+
+		var svcDivs []serviceDivision
+		for _, svc := range services {
+			c, ok := svc.cycle(cycle)
+			if !ok {
 				continue
 			}
-			realCount++
-			if user.Commercial && cycle.Method == expense.NormalMethod {
-				realCount++
+			d, err := computeShares(c, svc.users, nil, nil, result.Business.Rounding)
+			if err != nil {
+				return nil, fmt.Errorf("service %s: %w", svc.name, err)
 			}
+			svcDivs = append(svcDivs, serviceDivision{name: svc.name, cycle: c, div: d})
 		}
-		if realCount > cycle.EffectiveConnections {
-			return nil, fmt.Errorf("logic error: too many connections found: %v > %v", realCount, cycle.EffectiveConnections)
-		}
+		trueUps.billed = append(trueUps.billed, div.shares)
 
 		fmt.Printf("Billing cycle %v..%v cycles %v savingsRate %.3f\n", startMonthDate, closeMonthDate, sumExpenses.Display(), savingsRate)
 
-		charges := total.Split(cycle.EffectiveConnections)
-
-		// Deterministically shuffle the $0.01 rounding
-		// differences so they are shared by different users.
-		rand.New(rand.NewSource(cycle.PeriodStart.Closing().Date().UnixNano())).Shuffle(len(charges), func(i, j int) {
-			charges[i], charges[j] = charges[j], charges[i]
-		})
+		var projectionMethod string
+		var estimates []projection.Estimate
+		if compStmt.Projection != nil {
+			projectionMethod = compStmt.Projection.Method.Display()
+			estimates = compStmt.Projection.Estimates
+		}
 
 		marginStr := fmt.Sprintf("%.0f%%", 100*(savingsRate-1))
 
 		// If the bill date is prior to
 		estimatedBilling := cycle.BillDate.Before(cycle.PeriodStart.Closing())
 
+		// Payer payments are allocated on what each account
+		// owes on the payment date, so the cycle's charges are
+		// entered between the payments through its closing date
+		// and those through its bill date.
+		closing := cycle.PeriodStart.Closing()
+		settled := cycle.BillDate
+		if closing.Before(settled) {
+			settled = closing
+		}
+		if err := payerPays.settle(&settled); err != nil {
+			return nil, err
+		}
+		charged, err := enterCharges(accts, users, cycle, div, svcDivs, closeMonthDate)
+		if err != nil {
+			return nil, err
+		}
+		if err := payerPays.settle(&cycle.BillDate); err != nil {
+			return nil, err
+		}
+
+		// The estimated bill date is modified below.
+		issueDate := cycle.BillDate
+
+		adjust, err := trueUps.due(cycleNo, issueDate, users, readings, blocks)
+		if err != nil {
+			return nil, err
+		}
+
 		for _, user := range users {
-			if user.FirstPeriodStart.Starting().Date().After(cycle.PeriodStart.Starting().Date()) {
+			if !user.Serves(cycle.PeriodStart) {
 				continue
 			}
 
@@ -267,35 +829,102 @@ func Logic(inputs Inputs, fs afero.Fs) (*Result, error) {
 			}
 			compStmt.Statements = append(compStmt.Statements, userStmt)
 
-			owes, fraction, weight, reduced := getPayment(user, charges, cycle)
-			charges = reduced
+			sh := div.shares[user.AccountName]
+			owes, fraction, weight, metered := sh.owes, sh.fraction, sh.weight, sh.metered
 
-			pctStr := fmt.Sprintf("%.2f%%", fraction*100)
-			fracStr := fmt.Sprintf("%.4f", fraction)
+			pctStr := percentText(fraction)
+			fracStr := fractionText(fraction)
 
 			acct := accts.Lookup(user.AccountName)
-			priorBalance := acct.Balance(cycle.BillDate)
 
-			acct.EnterAmountDue(cycle.PeriodStart.Closing(), owes)
+			// unbilled is the part of a balance through a
+			// date that is this statement's charges.
+			unbilled := func(date csv.Date) currency.Amount {
+				if date.Before(closing) {
+					return currency.Units(0)
+				}
+				return charged[user.AccountName]
+			}
+
+			overdue, lateFee, waived, err := assessLateFee(lateFees, acct, lastBill[user.AccountName], issueDate, unbilled(issueDate))
+			if err != nil {
+				return nil, err
+			}
+
+			userTrueUps := adjust[user.AccountName]
+			trueUp, err := postTrueUps(acct, issueDate, userTrueUps)
+			if err != nil {
+				return nil, err
+			}
+
+			userAdjs, adjusted := dueAdjustments(adjustments[user.AccountName], lastBill[user.AccountName], issueDate)
+			userInvs, invoiced := dueInvoices(invoices[user.AccountName], lastBill[user.AccountName], issueDate)
+
+			// The prior balance excludes this statement's
+			// late fee, waivers, true-ups, adjustments, and
+			// invoices, shown separately.
+			priorBalance := currency.Sum(acct.Balance(cycle.BillDate), waived)
+			priorBalance = currency.Difference(priorBalance, currency.Sum(lateFee, trueUp, adjusted, invoiced, unbilled(cycle.BillDate)))
+
+			pay := owes
+			var sections []*Section
+			for _, sd := range svcDivs {
+				sec, ok := sd.section(user.AccountName)
+				if !ok {
+					continue
+				}
+				owes = currency.Sum(owes, sec.owes)
+				sections = append(sections, sec)
+			}
 
 			if estimatedBilling {
 				cycle.BillDate = cycle.PeriodStart.Closing()
 			}
 
 			totalDue := acct.Balance(cycle.BillDate)
+			lastBill[user.AccountName] = &billed{
+				date: issueDate,
+				due:  totalDue,
+			}
+
+			var lateFeePolicy string
+			if lateFees != nil {
+				lateFeePolicy = lateFees.Describe()
+			}
 
 			var lastPay string
 			var lastPayDate string
-			if lp := acct.LastPayment(); !lp.Amount.IsZero() {
+			if lp := acct.LastPayment(cycle.BillDate); !lp.Amount.IsZero() {
 				lastPay = lp.Amount.Display()
 				lastPayDate = lp.Date.Date().Format(constant.FullDateLayout)
 			}
 
+			var items []ExpenseItem
+			if result.Business.ExpenseAppendix {
+				items = expenseItems(cycle)
+			}
+
 			userStmt.Vars = &Vars{
 				tmpl:  compStmt.Template,
 				cycle: cycle,
 
+				owes:         owes,
+				priorBalance: priorBalance,
+				totalDue:     totalDue,
+				lateFee:      lateFee,
+				waived:       waived,
+				trueUp:       trueUp,
+				adjusted:     adjusted,
+				invoiced:     invoiced,
+				fraction:     fraction,
+				gallons:      metered.Gallons,
+
+				AccountName:    user.AccountName,
+				ServiceAddress: user.ServiceAddress.OneLine(),
+
+				PeriodName:          schedule.Length.Name(),
 				StartFullDate:       startFullDate,
+				StartMonthDate:      startMonthDate,
 				CloseFullDate:       closeFullDate,
 				CloseMonthDate:      closeMonthDate,
 				IssueFullDate:       issueFullDate,
@@ -313,7 +942,7 @@ func Logic(inputs Inputs, fs afero.Fs) (*Result, error) {
 
 				// Top shelf
 				TotalCost:    sumExpenses.Display(),
-				Pay:          owes.Display(),
+				Pay:          pay.Display(),
 				TotalDue:     totalDue.Display(),
 				PriorBalance: priorBalance.Display(),
 				LastPayment:  lastPay,
@@ -323,81 +952,390 @@ func Logic(inputs Inputs, fs afero.Fs) (*Result, error) {
 				Utilities:  cycle.Utilities.Display(),
 				Taxes:      cycle.Taxes.Display(),
 				Insurance:  cycle.Insurance.Display(),
+
+				// Metered
+				Metered:     rates != nil,
+				Gallons:     metered.Gallons.Display(),
+				BaseCharge:  metered.Base.Display(),
+				UsageCharge: metered.Usage.Display(),
+				Tiers:       metered.Tiers,
+
+				// Late fees
+				LateFeePolicy: lateFeePolicy,
+				Overdue:       overdue.Display(),
+				LateFee:       lateFee.Display(),
+				LateFeeWaived: waived.Display(),
+				HasLateFee:    !lateFee.IsZero() || !waived.IsZero(),
+
+				// Projection
+				Projected:        compStmt.Projection != nil,
+				ProjectionMethod: projectionMethod,
+				Projection:       estimates,
+
+				// Appendix
+				Items: items,
+
+				// True-ups
+				TrueUps:   userTrueUps,
+				TrueUp:    trueUp.Display(),
+				HasTrueUp: len(userTrueUps) != 0,
+
+				// Adjustments
+				Adjustments:   userAdjs,
+				HasAdjustment: len(userAdjs) != 0,
+
+				// One-off invoices
+				Invoices:   userInvs,
+				HasInvoice: len(userInvs) != 0,
+
+				// Proration
+				Prorated:    sh.days != sh.periodDays,
+				ServiceDays: sh.days,
+				PeriodDays:  sh.periodDays,
+				FinalBill:   user.Final(cycle.PeriodStart),
+			}
+			if inputs.CapitalFile != "" {
+				userStmt.Vars.Capital = cycle.Capital.Display()
+			}
+			if rates != nil && weight != 0 {
+				userStmt.Vars.OpenReading = sh.usage.Open.Gallons.Display()
+				userStmt.Vars.OpenReadingDate = sh.usage.Open.Date.Date().Format(constant.FullDateLayout)
+				userStmt.Vars.CloseReading = sh.usage.Close.Gallons.Display()
+				userStmt.Vars.CloseReadingDate = sh.usage.Close.Date.Date().Format(constant.FullDateLayout)
+			}
+			if len(sections) != 0 {
+				userStmt.Vars.Service = result.Business.Service
+				userStmt.Vars.Sections = sections
+			}
+			if userStmt.Vars.Prorated {
+				from, to := user.ServicePeriod(cycle.PeriodStart)
+				userStmt.Vars.ServiceFrom = from.Date().Format(constant.FullDateLayout)
+				userStmt.Vars.ServiceTo = to.Date().Format(constant.FullDateLayout)
+			}
+		}
+
+		for _, p := range payers {
+			if !p.Consolidate {
+				continue
+			}
+			if ps := consolidate(p, compStmt, outputPath); ps != nil {
+				compStmt.Payers = append(compStmt.Payers, ps)
 			}
 		}
 	}
+	if err := payerPays.settle(nil); err != nil {
+		return nil, err
+	}
+	result.PendingRevisions = trueUps.pending()
 	return result, nil
 }
 
-func (vars *Vars) mainContent(m pdf.Maroto) {
-	m.Row(2, func() {
-		m.TableList([]string{
-			"Expense",
-			"Cost",
-			"",
-		}, [][]string{
-			{
-				"Operations",
-				vars.cycle.Operations.Display(),
-			},
-			{
-				"Utilities",
-				vars.cycle.Utilities.Display(),
-			},
-			{
-				"Insurance",
-				vars.cycle.Insurance.Display(),
+// enterCharges enters the charges of each account served in the
+// cycle on its closing date, the account's share and its share of
+// each service, and returns their sum by account name.
+func enterCharges(accts *account.Accounts, users []user.User, cycle expense.Cycle, div *division, svcDivs []serviceDivision, closeMonthDate string) (map[string]currency.Amount, error) {
+	charged := map[string]currency.Amount{}
+	for _, user := range users {
+		if !user.Serves(cycle.PeriodStart) {
+			continue
+		}
+		acct := accts.Lookup(user.AccountName)
+		owes := div.shares[user.AccountName].owes
+		if err := acct.EnterAmountDue(cycle.PeriodStart.Closing(), owes, statementMemo(closeMonthDate)); err != nil {
+			return nil, err
+		}
+		for _, sd := range svcDivs {
+			sec, ok := sd.section(user.AccountName)
+			if !ok {
+				continue
+			}
+			if err := acct.EnterAmountDue(cycle.PeriodStart.Closing(), sec.owes, statementMemo(closeMonthDate+" "+sd.name)); err != nil {
+				return nil, err
+			}
+			owes = currency.Sum(owes, sec.owes)
+		}
+		charged[user.AccountName] = owes
+	}
+	return charged, nil
+}
+
+// meterRows are the meter readings, the consumption, and its charges
+// by tier, in place of the expenses on a metered statement.
+func (vars *Vars) meterRows() [][]string {
+	var rows [][]string
+	if vars.OpenReading != "" {
+		rows = append(rows,
+			[]string{
+				"Meter reading " + vars.OpenReadingDate,
+				vars.OpenReading,
 			},
-			{
-				"Taxes",
-				vars.cycle.Taxes.Display(),
+			[]string{
+				"Meter reading " + vars.CloseReadingDate,
+				vars.CloseReading,
 			},
-			{},
-			{
-				"Subtotal (Semi-annual)",
-				vars.TotalCost,
+		)
+	}
+	rows = append(rows,
+		[]string{
+			"Consumption",
+			vars.Gallons,
+		},
+		[]string{},
+		[]string{
+			"Base charge",
+			vars.BaseCharge,
+		},
+	)
+	for _, tier := range vars.Tiers {
+		rows = append(rows, []string{
+			tier.Description() + " @ " + tier.PerThousand(),
+			tier.Charge(),
+		})
+	}
+	return append(rows, []string{
+		"Usage charge",
+		vars.UsageCharge,
+	})
+}
+
+func (vars *Vars) expenseRows() [][]string {
+	rows := [][]string{
+		{
+			"Operations",
+			vars.cycle.Operations.Display(),
+		},
+		{
+			"Utilities",
+			vars.cycle.Utilities.Display(),
+		},
+		{
+			"Insurance",
+			vars.cycle.Insurance.Display(),
+		},
+		{
+			"Taxes",
+			vars.cycle.Taxes.Display(),
+		},
+	}
+	if !vars.cycle.Capital.IsZero() {
+		rows = append(rows, []string{
+			"Capital",
+			vars.cycle.Capital.Display(),
+		})
+	}
+	return append(rows,
+		[]string{},
+		[]string{
+			"Subtotal (" + vars.PeriodName + ")",
+			vars.TotalCost,
+		},
+	)
+}
+
+// Content is the statement's tables, followed by the expense
+// appendix.
+func (vars *Vars) Content() invoice.Content {
+	if len(vars.Services) != 0 {
+		return append(vars.consolidatedContent(), vars.appendix()...)
+	}
+
+	rows := vars.expenseRows()
+	header := "Expense"
+	if vars.Metered {
+		rows = vars.meterRows()
+		header = "Water use"
+	}
+	if vars.Prorated {
+		rows = append(rows,
+			[]string{},
+			[]string{
+				"Service " + vars.ServiceFrom + " to " + vars.ServiceTo,
+				fmt.Sprintf("%d of %d days", vars.ServiceDays, vars.PeriodDays),
 			},
-			{
+		)
+	}
+	if !vars.Metered {
+		rows = append(rows,
+			[]string{
 				"Share",
 				"× " + vars.Fraction,
 			},
-			{
+			[]string{
 				"Margin",
 				"× " + vars.Margin,
 			},
-			{},
-			{
-				"New balance",
+		)
+	}
+	var tables invoice.Content
+	if len(vars.Sections) != 0 {
+		header = vars.Service
+		rows = append(rows,
+			[]string{},
+			[]string{
+				vars.Service + " charge",
 				vars.Pay,
 			},
-			{
-				"Prior balance",
-				vars.PriorBalance,
-			},
-			{
-				"Amount due",
-				vars.TotalDue,
+		)
+		tables = append(tables, invoice.Table{
+			Header: []string{
+				header,
+				"Cost",
+				"",
 			},
-		}, invoice.TableStyle)
+			Rows: rows,
+		})
+		for _, s := range vars.Sections {
+			tables = append(tables, s.table(vars.PeriodName))
+		}
+		header = "Statement"
+		rows = nil
+	} else {
+		rows = append(rows, []string{})
+	}
+	rows = append(rows,
+		[]string{
+			"New balance",
+			vars.owes.Display(),
+		},
+		[]string{
+			"Prior balance",
+			vars.PriorBalance,
+		},
+	)
+	if vars.HasLateFee {
+		rows = append(rows, []string{
+			"Late fee",
+			vars.LateFee,
+		})
+	}
+	if !vars.waived.IsZero() {
+		rows = append(rows, []string{
+			"Late fee waived",
+			currency.Difference(currency.Units(0), vars.waived).Display(),
+		})
+	}
+	for _, tu := range vars.TrueUps {
+		rows = append(rows, []string{
+			"True-up (" + tu.Period + " estimate)",
+			tu.Amount,
+		})
+	}
+	for _, inv := range vars.Invoices {
+		rows = append(rows, []string{
+			"Invoice " + inv.Number + " (" + inv.JobName + ")",
+			inv.Amount,
+		})
+	}
+	for _, a := range vars.Adjustments {
+		rows = append(rows, []string{
+			a.Kind + " (" + a.Reason + ")",
+			a.Amount,
+		})
+	}
+	rows = append(rows,
+		[]string{
+			"Amount due",
+			vars.TotalDue,
+		},
+	)
+	if vars.FinalBill {
+		rows = append(rows, []string{
+			"Final bill",
+			"",
+		})
+	}
+
+	tables = append(tables, invoice.Table{
+		Header: []string{
+			header,
+			"Cost",
+			"",
+		},
+		Rows: rows,
 	})
+	return append(tables, vars.appendix()...)
 }
 
+// consolidated lists the accounts billed on a payer's statement
+// instead of their own.
+func (cs *CompanyStatement) consolidated() map[string]bool {
+	r := map[string]bool{}
+	for _, ps := range cs.Payers {
+		for _, svc := range ps.Vars.Services {
+			r[svc.AccountName] = true
+		}
+	}
+	return r
+}
+
+// Name is the closing month of the cycle, which names its
+// statement directory.
+func (cs *CompanyStatement) Name() string {
+	return cs.Expenses.PeriodStart.Closing().Date().Format(constant.InvoiceDateLayout)
+}
+
+// Output writes the statement PDFs, and HTML when configured.  Output
+// is refused when a statement of a closed cycle would change, and the
+// statements of closed cycles are only written when missing.
 func Output(result *Result) error {
+	chs, err := result.Changes()
+	if err != nil {
+		return err
+	}
+	if len(chs) != 0 {
+		return ChangesError(chs)
+	}
 	for _, cycle := range result.Cycles {
-		for _, stmt := range cycle.Statements {
-			print, err := invoice.MakeInvoice(
-				result.Business,
-				stmt.User,
-				stmt.Vars,
-				stmt.Vars.mainContent,
-			)
-			if err != nil {
+		consolidated := cycle.consolidated()
+		closed := result.Registry.Closed(cycle.Name())
+
+		for _, ps := range cycle.Payers {
+			if closed && exists(ps.PdfPath) {
+				continue
+			}
+			if err := result.write(ps.User, ps.Vars, ps.PdfPath); err != nil {
 				return err
 			}
-			if err := print.OutputFileAndClose(stmt.PdfPath); err != nil {
+		}
+
+		for _, stmt := range cycle.Statements {
+			if consolidated[stmt.User.AccountName] || (closed && exists(stmt.PdfPath)) {
+				continue
+			}
+			if err := result.write(stmt.User, stmt.Vars, stmt.PdfPath); err != nil {
 				return err
 			}
 		}
 	}
 	return nil
 }
+
+// write renders a statement as PDF and, optionally, as HTML with the
+// same name.
+func (r *Result) write(u user.User, vars *Vars, pdfPath string) error {
+	content := vars.Content()
+	print, err := r.Layout.PDF(r.Business, u, vars, r.Layout.Content(content))
+	if err != nil {
+		return err
+	}
+	if err := print.OutputFileAndClose(pdfPath); err != nil {
+		return err
+	}
+	if !r.HTML {
+		return nil
+	}
+	f, err := os.Create(strings.TrimSuffix(pdfPath, ".pdf") + ".html")
+	if err != nil {
+		return err
+	}
+	if err := r.Layout.HTML(f, r.Business, u, vars, content); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func exists(name string) bool {
+	_, err := os.Stat(name)
+	return err == nil
+}
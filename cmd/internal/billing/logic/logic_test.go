@@ -1,39 +1,44 @@
 package logic
 
 import (
+	"bytes"
+	"io"
+	"math/big"
+	"net/mail"
+	"os"
 	"testing"
+	"time"
 
+	"github.com/jmacd/caspar.water/cmd/internal/billing/csv"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/currency"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/email"
 	"github.com/spf13/afero"
 	"github.com/stretchr/testify/require"
 )
 
-func TestLogic(t *testing.T) {
-
-	fs := afero.NewMemMapFs()
-	afs := &afero.Afero{Fs: fs}
-
-	require.NoError(t, afs.WriteFile("users.csv", []byte(`
+// baseline is the fixture of TestLogic: four accounts, the last
+// inactive, billed for four semi-annual cycles, with a statement
+// template for each.  See newFixture.
+var baseline = map[string]string{
+	"users.csv": `
 Account Name,User Name,Service Address,Billing Address,First Period Start,Commercial
 School,School,"1 Road; Caspar, CA 91234","1 Road; Caspar, CA 91234",10/1/1914,TRUE
 House2,Miller,"2 Road; Caspar, CA 91234","2 Road; Caspar, CA 91234",10/1/1914,FALSE
 House3,Sawyer,"3 Road; Caspar, CA 91234","3 Road; Caspar, CA 91234",10/1/1914,FALSE
 House4,Vacant,"4 Road; Caspar, CA 91234","4 Road; Caspar, CA 91234",10/1/1914,FALSE
-`), 0644))
-
-	require.NoError(t, afs.WriteFile("business.csv", []byte(`
+`,
+	"business.csv": `
 Name,Address,Contact
 "Water Company","1 Drive; Caspar, CA 91234",p: 555-555-5555; e: test@water.com
-`), 0644))
-
-	require.NoError(t, afs.WriteFile("cycles.csv", []byte(`
+`,
+	"cycles.csv": `
 Period Start,Operations,Utilities,Insurance,Taxes,Bill Date,Method,Margin,Effective Connections,Inactive
 10/1/1914,"$300.00",$300.00,"$600.00","$600.00",5/1/1915,Introductory,0.0,3,House4
 4/1/1915,"$300.00","$300.00","$0.00","$0.00",10/15/1915,Introductory,0.0,3,House4
 10/1/1915,"$300.00",$300.00,"$600.00","$600.00",4/16/1916,Normal,0.0,4,House4
 4/1/1916,"$300.00","$300.00","$0.00","$0.00",10/10/1916,Normal,0.1,4,House4
-`), 0644))
-
-	require.NoError(t, afs.WriteFile("payments.csv", []byte(`
+`,
+	"payments.csv": `
 Date,Account Name,Amount
 6/1/1915,School,$400.00
 6/1/1915,House2,$400.00
@@ -44,21 +49,36 @@ Date,Account Name,Amount
 5/1/1916,School,$600.00
 5/1/1916,House2,$300.00
 5/1/1916,House3,$300.00
-`), 0644))
+`,
+	"stmts/1915-Mar.txt": "hello world\n",
+	"stmts/1915-Sep.txt": "hello world\n",
+	"stmts/1916-Mar.txt": "hello world\n",
+	"stmts/1916-Sep.txt": "hello world\n",
+}
 
+// newFixture writes the baseline fixture with the files in
+// overrides replaced or added, and returns the inputs that read it.
+func newFixture(t *testing.T, overrides map[string]string) (*afero.Afero, Inputs) {
+	afs := &afero.Afero{Fs: afero.NewMemMapFs()}
 	require.NoError(t, afs.Mkdir("stmts", 0644))
-	require.NoError(t, afs.WriteFile("stmts/1915-Mar.txt", []byte("hello world\n"), 0644))
-	require.NoError(t, afs.WriteFile("stmts/1915-Sep.txt", []byte("hello world\n"), 0644))
-	require.NoError(t, afs.WriteFile("stmts/1916-Mar.txt", []byte("hello world\n"), 0644))
-	require.NoError(t, afs.WriteFile("stmts/1916-Sep.txt", []byte("hello world\n"), 0644))
-
-	result, err := Logic(Inputs{
+	for _, files := range []map[string]string{baseline, overrides} {
+		for name, data := range files {
+			require.NoError(t, afs.WriteFile(name, []byte(data), 0644))
+		}
+	}
+	return afs, Inputs{
 		UsersFile:     "users.csv",
 		BusinessFile:  "business.csv",
 		CyclesFile:    "cycles.csv",
 		PaymentsFile:  "payments.csv",
 		StatementsDir: "stmts",
-	}, fs)
+	}
+}
+
+func TestLogic(t *testing.T) {
+	afs, inputs := newFixture(t, nil)
+
+	result, err := Logic(inputs, afs)
 	require.NoError(t, err)
 
 	require.Equal(t, 4, len(result.Cycles))
@@ -102,3 +122,1290 @@ Date,Account Name,Amount
 	require.Equal(t, cycle3.Statements[2].Vars.TotalDue, "$330.00")
 	require.Equal(t, cycle3.Statements[3].Vars.TotalDue, "$0.00")
 }
+
+func TestLogicMetered(t *testing.T) {
+	afs, inputs := newFixture(t, map[string]string{
+		"cycles.csv": `
+Period Start,Operations,Utilities,Insurance,Taxes,Bill Date,Method,Base Charge,Margin,Effective Connections,Inactive
+10/1/1914,"$300.00",$300.00,"$600.00","$600.00",5/1/1915,Metered,$50.00,0.0,4,House4
+`,
+		"payments.csv": `
+Date,Account Name,Amount
+6/1/1915,School,$100.00
+`,
+		"meters.csv": `
+Date,Account Name,Gallons
+10/1/1914,School,0
+3/31/1915,School,5000
+9/15/1914,House2,1000
+3/30/1915,House2,3000
+10/1/1914,House3,20000
+3/31/1915,House3,30000
+`,
+		"rates.csv": `
+Period Start,Up To Gallons,Per Thousand Gallons
+10/1/1914,3000,$4.00
+10/1/1914,6000,$6.00
+10/1/1914,0,$9.00
+`,
+		"payers.csv": `
+Payer Name,User Name,Billing Address,Accounts,Allocation,Consolidate
+Landlord,Miller and Sawyer,"9 Road; Caspar, CA 91234","House2, House3",Proportional,TRUE
+`,
+		"stmts/1915-Mar.txt": "{{.Gallons}}\n",
+	})
+
+	inputs.MetersFile = "meters.csv"
+	inputs.RatesFile = "rates.csv"
+	inputs.PayersFile = "payers.csv"
+	result, err := Logic(inputs, afs)
+	require.NoError(t, err)
+
+	require.Equal(t, 1, len(result.Cycles))
+	stmts := result.Cycles[0].Statements
+	require.Equal(t, 4, len(stmts))
+
+	// School: 2 x $50 base, 3,000 gal @ $4 + 2,000 gal @ $6
+	require.True(t, stmts[0].Vars.Metered)
+	require.Equal(t, "5,000 gal", stmts[0].Vars.Gallons)
+	require.Equal(t, "$100.00", stmts[0].Vars.BaseCharge)
+	require.Equal(t, "$24.00", stmts[0].Vars.UsageCharge)
+	require.Equal(t, 2, len(stmts[0].Vars.Tiers))
+	require.Equal(t, "$124.00", stmts[0].Vars.TotalDue)
+
+	// House2: $50 base, 2,000 gal @ $4
+	require.Equal(t, "$58.00", stmts[1].Vars.TotalDue)
+
+	// House3: $50 base, 3,000 @ $4 + 3,000 @ $6 + 4,000 @ $9
+	require.Equal(t, "$116.00", stmts[2].Vars.TotalDue)
+	require.Equal(t, 3, len(stmts[2].Vars.Tiers))
+
+	// House4 is inactive.
+	require.Equal(t, "$0.00", stmts[3].Vars.TotalDue)
+
+	text, err := stmts[2].Vars.BodyText()
+	require.NoError(t, err)
+	require.Equal(t, "10,000 gal\n", text)
+
+	// The statement shows the readings and usage, not the
+	// expenses and the share.
+	table := stmts[1].Vars.Content()[0]
+	require.Equal(t, "Water use", table.Header[0])
+	require.Equal(t, [][]string{
+		{"Meter reading September 15, 1914", "1,000 gal"},
+		{"Meter reading March 30, 1915", "3,000 gal"},
+		{"Consumption", "2,000 gal"},
+		{},
+		{"Base charge", "$50.00"},
+	}, table.Rows[:5])
+	for _, row := range table.Rows {
+		if len(row) != 0 {
+			require.NotContains(t, []string{"Operations", "Share", "Margin"}, row[0])
+			require.NotContains(t, row[0], "Subtotal")
+		}
+	}
+
+	// The payer's statement lists the consumption of each
+	// address, without the expenses.
+	content := result.Cycles[0].Payers[0].Vars.Content()
+	require.Equal(t, []string{"Service address", "Consumption", "New balance", "Prior balance", "Amount due"}, content[0].Header)
+	require.Equal(t, []string{"Total", "12,000 gal", "$174.00", "$0.00", "$174.00"}, content[0].Rows[len(content[0].Rows)-1])
+}
+
+func TestLogicPayers(t *testing.T) {
+	// Output writes to the real filesystem, and the invoice
+	// header expects a logo.
+	logo, err := os.ReadFile("../../../../assets/img/logo.jpg")
+	require.NoError(t, err)
+	t.Chdir(t.TempDir())
+	require.NoError(t, os.MkdirAll("assets/img", 0777))
+	require.NoError(t, os.WriteFile("assets/img/logo.jpg", logo, 0644))
+	const stmts = "stmts"
+
+	afs, inputs := newFixture(t, map[string]string{
+		"users.csv": `
+Account Name,User Name,Service Address,Billing Address,First Period Start,Commercial
+School,School,"1 Road; Caspar, CA 91234","1 Road; Caspar, CA 91234",10/1/1914,TRUE
+House2,Miller,"2 Road; Caspar, CA 91234","9 Road; Caspar, CA 91234",10/1/1914,FALSE
+House3,Sawyer,"3 Road; Caspar, CA 91234","9 Road; Caspar, CA 91234",10/1/1914,FALSE
+House4,Vacant,"4 Road; Caspar, CA 91234","4 Road; Caspar, CA 91234",10/1/1914,FALSE
+`,
+		"business.csv": `
+Name,Address,Contact,Email
+"Water Company","1 Drive; Caspar, CA 91234",p: 555-555-5555; e: test@water.com,billing@water.com
+`,
+		"cycles.csv": `
+Period Start,Operations,Utilities,Insurance,Taxes,Bill Date,Method,Margin,Effective Connections,Inactive
+10/1/1914,"$300.00",$300.00,"$600.00","$600.00",5/1/1915,Introductory,0.0,3,House4
+4/1/1915,"$300.00","$300.00","$0.00","$0.00",10/15/1915,Introductory,0.0,3,House4
+`,
+		"payments.csv": `
+Date,Account Name,Amount
+6/1/1915,School,$400.00
+6/1/1915,Landlord,$600.00
+`,
+		"payers.csv": `
+Payer Name,User Name,Billing Address,Accounts,Allocation,Consolidate,Email
+Landlord,Miller and Sawyer,"9 Road; Caspar, CA 91234","House2, House3",Proportional,TRUE,landlord@example.com
+`,
+		"stmts/1915-Sep.txt": "{{.Percent}}\n",
+	})
+
+	inputs.PayersFile = "payers.csv"
+	result, err := Logic(inputs, afs)
+	require.NoError(t, err)
+
+	// The $600.00 payment was split evenly between two $400.00
+	// balances.
+	cycle1 := result.Cycles[1]
+	require.Equal(t, "$400.00", cycle1.Statements[0].Vars.TotalDue)
+	require.Equal(t, "$500.00", cycle1.Statements[1].Vars.TotalDue)
+	require.Equal(t, "$500.00", cycle1.Statements[2].Vars.TotalDue)
+
+	require.Equal(t, 1, len(cycle1.Payers))
+	ps := cycle1.Payers[0]
+	require.Equal(t, "Miller and Sawyer", ps.User.UserName)
+	require.Equal(t, 2, len(ps.Vars.Services))
+	require.Equal(t, "2 Road; Caspar, CA 91234", ps.Vars.Services[0].ServiceAddress)
+	require.Equal(t, "$800.00", ps.Vars.Pay)
+	require.Equal(t, "$200.00", ps.Vars.PriorBalance)
+	require.Equal(t, "$1,000.00", ps.Vars.TotalDue)
+
+	text, err := ps.Vars.BodyText()
+	require.NoError(t, err)
+	require.Equal(t, "66.67%\n", text)
+
+	result.HTML = true
+	require.NoError(t, Output(result))
+	for _, name := range []string{"School.pdf", "House4.pdf", "Landlord.pdf", "Landlord.html"} {
+		_, err := os.Stat(stmts + "/1915-Sep/" + name)
+		require.NoError(t, err, "for %s", name)
+	}
+	_, err = os.Stat(stmts + "/1915-Sep/House2.pdf")
+	require.True(t, os.IsNotExist(err))
+
+	// The HTML statement has the same tables, and an inline logo.
+	html, err := os.ReadFile(stmts + "/1915-Sep/Landlord.html")
+	require.NoError(t, err)
+	require.Contains(t, string(html), `<img src="data:image/jpeg;base64,`)
+	require.Contains(t, string(html), "<th>Service address</th>")
+	require.Contains(t, string(html), "<td>2 Road; Caspar, CA 91234</td><td>$400.00</td>")
+	require.NotContains(t, string(html), "Service address: ")
+
+	// The consolidated statement is emailed to the payer.
+	tmpls, err := ReadEmailTemplates(stmts, afs)
+	require.NoError(t, err)
+	log, err := Deliver(result, cycle1, tmpls, nil, time.Date(1915, 10, 16, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	status := map[string]string{}
+	for _, d := range log {
+		status[d.AccountName] = d.Status
+	}
+	require.Equal(t, map[string]string{
+		"School":   email.NoAddress,
+		"House2":   email.Consolidated,
+		"House3":   email.Consolidated,
+		"House4":   email.NoAddress,
+		"Landlord": email.Written,
+	}, status)
+	require.Equal(t, "landlord@example.com", log[len(log)-1].To)
+	require.Equal(t, stmts+"/1915-Sep/Landlord.eml", log[len(log)-1].File)
+}
+
+func TestLogicPayerAfterClosing(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	afs, inputs := newFixture(t, map[string]string{
+		"users.csv": `
+Account Name,User Name,Service Address,Billing Address,First Period Start,Commercial
+House2,Miller,"2 Road; Caspar, CA 91234","9 Road; Caspar, CA 91234",10/1/1914,FALSE
+House3,Sawyer,"3 Road; Caspar, CA 91234","9 Road; Caspar, CA 91234",10/1/1914,FALSE
+`,
+		"cycles.csv": `
+Period Start,Operations,Utilities,Insurance,Taxes,Bill Date,Method,Margin,Effective Connections
+10/1/1914,"$400.00",$400.00,"$0.00","$0.00",5/1/1915,Normal,0.0,2
+4/1/1915,"$300.00","$300.00","$0.00","$0.00",10/15/1915,Normal,0.0,2
+`,
+		// The payer's payment after the second cycle closes is
+		// divided in proportion to the balances including its
+		// charges, $300.00 and $700.00.
+		"payments.csv": `
+Date,Account Name,Amount
+5/15/1915,House2,$400.00
+10/5/1915,Landlord,"$1,000.00"
+`,
+		"payers.csv": `
+Payer Name,User Name,Billing Address,Accounts
+Landlord,Miller and Sawyer,"9 Road; Caspar, CA 91234","House2, House3"
+`,
+	})
+
+	inputs.PayersFile = "payers.csv"
+	result, err := Logic(inputs, afs)
+	require.NoError(t, err)
+
+	cycle1 := result.Cycles[1]
+	for i, prior := range []string{"-$300.00", "-$300.00"} {
+		vars := cycle1.Statements[i].Vars
+		require.Equal(t, "$300.00", vars.Pay)
+		require.Equal(t, prior, vars.PriorBalance)
+		require.Equal(t, "$0.00", vars.TotalDue)
+	}
+	entries := result.Accounts.Lookup("House3").Entries()
+	last := entries[len(entries)-1]
+	require.Equal(t, "Payer Landlord", last.Memo)
+	require.Equal(t, "-$700.00", result.Accounts.Lookup("House3").Net(last).Display())
+}
+
+func TestLogicLateFees(t *testing.T) {
+	afs, inputs := newFixture(t, map[string]string{
+		"cycles.csv": `
+Period Start,Operations,Utilities,Insurance,Taxes,Bill Date,Method,Margin,Effective Connections,Inactive
+10/1/1914,"$300.00",$300.00,"$600.00","$600.00",5/1/1915,Introductory,0.0,3,House4
+4/1/1915,"$300.00","$300.00","$0.00","$0.00",10/15/1915,Introductory,0.0,3,House4
+10/1/1915,"$300.00",$300.00,"$600.00","$600.00",4/16/1916,Normal,0.0,4,House4
+`,
+		"payments.csv": `
+Date,Account Name,Amount
+6/1/1915,School,$400.00
+6/1/1915,House2,$400.00
+6/1/1915,House3,$200.00
+12/1/1915,School,$400.00
+12/1/1915,House2,$400.00
+12/1/1915,House3,$600.00
+`,
+		"latefee.csv": `
+Grace Days,Flat Fee,Rate,Cap,Minimum Balance
+30,$10.00,0.015,$12.00,$1.00
+`,
+		"journal.csv": `
+Date,Kind,Account Name,Amount,To Account,Memo
+4/16/1916,Waiver,House3,$10.18,,Goodwill
+`,
+		"stmts/1915-Sep.txt": "{{if .HasLateFee}}{{.LateFee}}: {{.LateFeePolicy}}{{end}}\n",
+	})
+
+	inputs.JournalFile = "journal.csv"
+	inputs.LateFeeFile = "latefee.csv"
+	result, err := Logic(inputs, afs)
+	require.NoError(t, err)
+
+	// No prior statement, no fee.
+	cycle0 := result.Cycles[0]
+	require.False(t, cycle0.Statements[2].Vars.HasLateFee)
+	require.Equal(t, "$400.00", cycle0.Statements[2].Vars.TotalDue)
+
+	// House3 left $200.00 unpaid: $10.00 + 1.5% capped at $12.00.
+	cycle1 := result.Cycles[1]
+	require.False(t, cycle1.Statements[1].Vars.HasLateFee)
+	require.Equal(t, "$400.00", cycle1.Statements[1].Vars.TotalDue)
+
+	house3 := cycle1.Statements[2].Vars
+	require.True(t, house3.HasLateFee)
+	require.Equal(t, "$200.00", house3.Overdue)
+	require.Equal(t, "$12.00", house3.LateFee)
+	require.Equal(t, "$200.00", house3.PriorBalance)
+	require.Equal(t, "$612.00", house3.TotalDue)
+
+	text, err := house3.BodyText()
+	require.NoError(t, err)
+	require.Equal(t, "$12.00: $10.00 plus 1.5% of the overdue balance, up to $12.00, for balances unpaid 30 days after the bill date\n", text)
+
+	// House3 left $12.00 unpaid, the $10.18 fee was waived.
+	house3 = result.Cycles[2].Statements[2].Vars
+	require.Equal(t, "$12.00", house3.Overdue)
+	require.Equal(t, "$10.18", house3.LateFee)
+	require.Equal(t, "$10.18", house3.LateFeeWaived)
+	require.Equal(t, "$12.00", house3.PriorBalance)
+	require.Equal(t, "$312.00", house3.TotalDue)
+}
+
+func TestLogicTrueUp(t *testing.T) {
+	afs, inputs := newFixture(t, map[string]string{
+		// The first cycle is billed on an estimate and revised once
+		// the actual operations cost is known.
+		"cycles.csv": `
+Period Start,Operations,Utilities,Insurance,Taxes,Bill Date,Method,Margin,Effective Connections,Inactive,Revision
+10/1/1914,"$300.00",$300.00,"$600.00","$600.00",1/15/1915,Introductory,0.0,3,House4,
+4/1/1915,"$300.00","$300.00","$0.00","$0.00",10/15/1915,Introductory,0.0,3,House4,
+10/1/1914,"$450.00",$300.00,"$600.00","$600.00",5/1/1915,Introductory,0.0,3,House4,TRUE
+`,
+		"payments.csv": `
+Date,Account Name,Amount
+6/1/1915,School,$400.00
+6/1/1915,House2,$400.00
+6/1/1915,House3,$400.00
+`,
+		"stmts/1915-Sep.txt": "{{range .TrueUps}}{{.Period}}: {{.Estimated}} to {{.Actual}} is {{.Amount}}{{end}}",
+	})
+
+	result, err := Logic(inputs, afs)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(result.Cycles))
+
+	cycle0 := result.Cycles[0]
+	require.True(t, cycle0.Statements[0].Vars.Estimated)
+	require.Equal(t, "$400.00", cycle0.Statements[0].Vars.TotalDue)
+	require.False(t, cycle0.Statements[0].Vars.HasTrueUp)
+
+	cycle1 := result.Cycles[1]
+	for i := 0; i < 3; i++ {
+		vars := cycle1.Statements[i].Vars
+		require.True(t, vars.HasTrueUp)
+		require.Equal(t, "$50.00", vars.TrueUp)
+		require.Equal(t, "$0.00", vars.PriorBalance)
+		require.Equal(t, "$400.00", vars.Pay)
+		require.Equal(t, "$450.00", vars.TotalDue)
+
+		text, err := vars.BodyText()
+		require.NoError(t, err)
+		require.Equal(t, "1915-Mar: $400.00 to $450.00 is $50.00", text)
+	}
+	require.False(t, cycle1.Statements[3].Vars.HasTrueUp)
+	require.Equal(t, "$0.00", cycle1.Statements[3].Vars.TotalDue)
+
+	entries := result.Accounts.Lookup("House2").Entries()
+	require.Equal(t, "True-up of the 1915-Mar estimate", entries[3].Memo)
+	require.Empty(t, result.PendingRevisions)
+
+	// A revision billed after the last statement waits.
+	require.NoError(t, afs.WriteFile("cycles.csv", []byte(`
+Period Start,Operations,Utilities,Insurance,Taxes,Bill Date,Method,Margin,Effective Connections,Inactive,Revision
+10/1/1914,"$300.00",$300.00,"$600.00","$600.00",1/15/1915,Introductory,0.0,3,House4,
+4/1/1915,"$300.00","$300.00","$0.00","$0.00",10/15/1915,Introductory,0.0,3,House4,
+10/1/1914,"$450.00",$300.00,"$600.00","$600.00",11/1/1915,Introductory,0.0,3,House4,TRUE
+`), 0644))
+	result, err = Logic(inputs, afs)
+	require.NoError(t, err)
+	require.Equal(t, []string{"1915-Mar"}, result.PendingRevisions)
+}
+
+func TestLogicSchedule(t *testing.T) {
+	afs, inputs := newFixture(t, map[string]string{
+		"users.csv": `
+Account Name,User Name,Service Address,Billing Address,First Period Start
+House1,Smith,"1 Road; Caspar, CA 91234","1 Road; Caspar, CA 91234",2/1/2024
+House2,Miller,"2 Road; Caspar, CA 91234","2 Road; Caspar, CA 91234",2/1/2024
+`,
+		"quarterly.csv": `
+Name,Address,Contact,Billing Period,Anchor Month
+"Water Company","1 Drive; Caspar, CA 91234",p: 555-555-5555; e: test@water.com,Quarterly,2
+`,
+		"semiannual.csv": `
+Name,Address,Contact
+"Water Company","1 Drive; Caspar, CA 91234",p: 555-555-5555; e: test@water.com
+`,
+		"cycles.csv": `
+Period Start,Operations,Utilities,Insurance,Taxes,Bill Date,Margin
+2/1/2024,"$100.00",$100.00,"$0.00","$0.00",5/1/2024,0.0
+5/1/2024,"$100.00",$100.00,"$0.00","$0.00",8/1/2024,0.0
+`,
+		"payments.csv": `
+Date,Account Name,Amount
+6/1/2024,House1,$100.00
+`,
+		"stmts/2024-Apr.txt": "hello world\n",
+		"stmts/2024-Jul.txt": "hello world\n",
+	})
+
+	withBusiness := func(businessFile string) Inputs {
+		inputs.BusinessFile = businessFile
+		return inputs
+	}
+
+	// The periods are bound to each business's schedule, one
+	// run does not affect the next.
+	for range 2 {
+		result, err := Logic(withBusiness("quarterly.csv"), afs)
+		require.NoError(t, err)
+		require.Equal(t, 2, len(result.Cycles))
+		require.Equal(t, "2024-Apr", result.Cycles[0].Name())
+		require.Equal(t, "2024-Jul", result.Cycles[1].Name())
+		require.Equal(t, "Quarterly", result.Cycles[0].Statements[0].Vars.PeriodName)
+		require.Equal(t, "$100.00", result.Cycles[1].Statements[0].Vars.Pay)
+
+		_, err = Logic(withBusiness("semiannual.csv"), afs)
+		require.ErrorContains(t, err, "users.csv: account House1: semi-annual periods start in")
+	}
+}
+
+func TestLogicProjection(t *testing.T) {
+	afs, inputs := newFixture(t, map[string]string{
+		"users.csv": `
+Account Name,User Name,Service Address,Billing Address,First Period Start,Commercial
+House2,Miller,"2 Road; Caspar, CA 91234","2 Road; Caspar, CA 91234",10/1/1914,FALSE
+House3,Sawyer,"3 Road; Caspar, CA 91234","3 Road; Caspar, CA 91234",10/1/1914,FALSE
+`,
+		"cycles.csv": `
+Period Start,Operations,Utilities,Insurance,Taxes,Bill Date,Method,Margin,Effective Connections,Projection
+10/1/1914,"$300.00",$100.00,"$200.00","$200.00",5/1/1915,Normal,0.0,2,
+4/1/1915,"$500.00","$300.00","$0.00","$0.00",10/15/1915,Normal,0.0,2,
+10/1/1915,"$0.00","$0.00","$0.00","$0.00",1/15/1916,Normal,0.0,2,Trailing Average
+`,
+		"payments.csv": `
+Date,Account Name,Amount
+6/1/1915,House2,$300.00
+6/1/1915,House3,$300.00
+`,
+		"stmts/1916-Mar.txt": "{{.ProjectionMethod}}{{range .Projection}}; {{.Expense}} {{.Amount.Display}} {{.Basis}}{{end}}",
+	})
+
+	result, err := Logic(inputs, afs)
+	require.NoError(t, err)
+	require.Equal(t, 3, len(result.Cycles))
+	require.Equal(t, 1, len(result.Projections))
+	require.False(t, result.Cycles[1].Statements[0].Vars.Projected)
+
+	vars := result.Cycles[2].Statements[0].Vars
+	require.True(t, vars.Projected)
+	require.True(t, vars.Estimated)
+	require.Equal(t, "$400.00", vars.Operations)
+	require.Equal(t, "$200.00", vars.Utilities)
+	require.Equal(t, "$100.00", vars.Taxes)
+	require.Equal(t, "$400.00", vars.Pay)
+
+	text, err := vars.BodyText()
+	require.NoError(t, err)
+	require.Equal(t, "trailing average"+
+		"; Operations $400.00 average of 1915-Mar, 1915-Sep"+
+		"; Utilities $200.00 average of 1915-Mar, 1915-Sep"+
+		"; Taxes $200.00 average of 1915-Mar"+
+		"; Insurance $200.00 average of 1915-Mar", text)
+}
+
+func TestLogicEmail(t *testing.T) {
+	logo, err := os.ReadFile("../../../../assets/img/logo.jpg")
+	require.NoError(t, err)
+	t.Chdir(t.TempDir())
+	require.NoError(t, os.MkdirAll("assets/img", 0777))
+	require.NoError(t, os.WriteFile("assets/img/logo.jpg", logo, 0644))
+	const stmts = "stmts"
+
+	afs, inputs := newFixture(t, map[string]string{
+		"users.csv": `
+Account Name,User Name,Service Address,Billing Address,First Period Start,Commercial,Email
+School,School,"1 Road; Caspar, CA 91234","1 Road; Caspar, CA 91234",10/1/1914,TRUE,school@example.com
+House2,Miller,"2 Road; Caspar, CA 91234","2 Road; Caspar, CA 91234",10/1/1914,FALSE,Miller <miller@example.com>
+House3,Sawyer,"3 Road; Caspar, CA 91234","3 Road; Caspar, CA 91234",10/1/1914,FALSE,
+`,
+		"business.csv": `
+Name,Address,Contact,Email
+"Water Company","1 Drive; Caspar, CA 91234",p: 555-555-5555; e: test@water.com,billing@water.com
+`,
+		"cycles.csv": `
+Period Start,Operations,Utilities,Insurance,Taxes,Bill Date,Method,Margin,Effective Connections,Inactive
+10/1/1914,"$300.00",$300.00,"$600.00","$600.00",5/1/1915,Introductory,0.0,3,
+`,
+		"payments.csv": `
+Date,Account Name,Amount
+6/1/1915,School,$400.00
+`,
+		"stmts/email.txt":  "{{.User.UserName}} owes {{.TotalDue}} to {{.Business.Name}}\n",
+		"stmts/email.html": "<p>{{.User.UserName}} owes {{.TotalDue}}</p>",
+	})
+
+	result, err := Logic(inputs, afs)
+	require.NoError(t, err)
+	require.NoError(t, Output(result))
+
+	cycle, err := result.FindCycle("")
+	require.NoError(t, err)
+	require.Equal(t, "1915-Mar", cycle.Name())
+	_, err = result.FindCycle("1915-Sep")
+	require.Error(t, err)
+
+	tmpls, err := ReadEmailTemplates(stmts, afs)
+	require.NoError(t, err)
+
+	now := time.Date(1915, 5, 2, 0, 0, 0, 0, time.UTC)
+	log, err := Deliver(result, cycle, tmpls, nil, now)
+	require.NoError(t, err)
+	require.Equal(t, 3, len(log))
+	require.Equal(t, email.Written, log[0].Status)
+	require.Equal(t, email.Written, log[1].Status)
+	require.Equal(t, email.NoAddress, log[2].Status)
+	require.Equal(t, "1915-Mar", log[1].Statement)
+	require.Equal(t, stmts+"/1915-Mar/House2.eml", log[1].File)
+
+	f, err := os.Open(log[1].File)
+	require.NoError(t, err)
+	defer f.Close()
+	msg, err := mail.ReadMessage(f)
+	require.NoError(t, err)
+	require.Equal(t, `"Miller" <miller@example.com>`, msg.Header.Get("To"))
+	require.Equal(t, "Water Company statement 1915-Mar", msg.Header.Get("Subject"))
+	body, err := io.ReadAll(msg.Body)
+	require.NoError(t, err)
+	require.Contains(t, string(body), "Miller owes $400.00 to Water Company")
+	require.Contains(t, string(body), "<p>Miller owes $400.00</p>")
+	require.Contains(t, string(body), "filename=House2.pdf")
+
+	// Without the business address there is no sender.
+	result.Business.Email = ""
+	_, err = Deliver(result, cycle, tmpls, nil, now)
+	require.Error(t, err)
+}
+
+func TestLogicSummary(t *testing.T) {
+	afs, inputs := newFixture(t, map[string]string{
+		// Four effective connections, derived from the weights, and
+		// $0.03 of rounding to distribute.
+		"cycles.csv": `
+Period Start,Operations,Utilities,Insurance,Taxes,Bill Date,Method,Margin,Effective Connections,Inactive
+10/1/1914,"$300.03",$300.00,"$0.00","$0.00",5/1/1915,Normal,0.1,0,House4
+`,
+		"payments.csv": `
+Date,Account Name,Amount
+6/1/1915,School,$100.00
+6/1/1915,House2,$500.00
+`,
+	})
+
+	result, err := Logic(inputs, afs)
+	require.NoError(t, err)
+
+	cycle, err := result.FindCycle("1915-Mar")
+	require.NoError(t, err)
+	asOf, err := csv.ParseDate("12/31/1915")
+	require.NoError(t, err)
+	s := cycle.Summary(result.Accounts, asOf)
+
+	require.Equal(t, "$600.03", s.Expenses.Display())
+	require.Equal(t, "$660.03", s.Total.Display())
+	require.Equal(t, "$60.00", s.Margin.Display())
+	require.Equal(t, []string{"House4"}, s.Inactive)
+	require.Equal(t, 4, s.Connections)
+	require.Equal(t, "$165.00", s.Base.Display())
+	require.Equal(t, 3, s.RoundedUp)
+	require.Equal(t, s.Total, s.Billed)
+
+	// Every rounded cent is billed to an account.
+	var rounded currency.Amount
+	for _, a := range s.Accounts {
+		rounded = currency.Sum(rounded, a.Rounding)
+	}
+	require.Equal(t, int64(3), rounded.Units())
+
+	require.Equal(t, 3, len(s.Accounts))
+	require.Equal(t, "School", s.Accounts[0].AccountName)
+	require.Equal(t, 2, s.Accounts[0].Weight)
+	require.Equal(t, "$100.00", s.Accounts[0].Collected.Display())
+	require.Equal(t, s.Accounts[1].Billed, s.Accounts[1].Collected)
+	require.True(t, s.Accounts[2].Collected.IsZero())
+	require.Equal(t, currency.Difference(s.Billed, s.Collected), s.Outstanding)
+
+	// The margin's share of collections goes to the reserve,
+	// 0.1/1.1 of $265.01 is exactly $24.0918..., rounded down.
+	l, err := result.Reserve(nil, asOf)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(l.Entries))
+	require.Equal(t, "$265.01", s.Collected.Display())
+	require.Equal(t, s.Collected.Mul(big.NewRat(1, 11), currency.RoundDown), l.Balance)
+	require.Equal(t, "$24.09", l.Balance.Display())
+
+	basis, err := result.SimulationBasis(l, nil)
+	require.NoError(t, err)
+	require.Equal(t, 4, basis.Connections)
+	require.Equal(t, l.Balance, basis.Balance)
+
+	var buf bytes.Buffer
+	require.NoError(t, s.WriteCSV(&buf))
+	require.Contains(t, buf.String(), "Item,Value\nMethod,Normal\nExpenses,$600.03\n")
+	require.Contains(t, buf.String(), "\n\nAccount Name,Weight,Billed,Rounding,Collected,Outstanding\n")
+}
+
+func TestLogicRegistry(t *testing.T) {
+	afs, inputs := newFixture(t, map[string]string{
+		"users.csv": `
+Account Name,User Name,Service Address,Billing Address,First Period Start,Commercial
+School,School,"1 Road; Caspar, CA 91234","1 Road; Caspar, CA 91234",10/1/1914,TRUE
+House2,Miller,"2 Road; Caspar, CA 91234","2 Road; Caspar, CA 91234",10/1/1914,FALSE
+House3,Sawyer,"3 Road; Caspar, CA 91234","3 Road; Caspar, CA 91234",10/1/1914,FALSE
+`,
+		"cycles.csv": `
+Period Start,Operations,Utilities,Insurance,Taxes,Bill Date,Method,Margin,Effective Connections,Inactive
+10/1/1914,"$300.00",$300.00,"$0.00","$0.00",5/1/1915,Normal,0.0,4,
+4/1/1915,"$300.00","$300.00","$0.00","$0.00",10/15/1915,Normal,0.0,4,
+`,
+	})
+
+	inputs.RegistryFile = "issued.csv"
+	run := func(payments string) *Result {
+		require.NoError(t, afs.WriteFile("payments.csv", []byte(payments), 0644))
+		result, err := Logic(inputs, afs)
+		require.NoError(t, err)
+		return result
+	}
+
+	result := run(`
+Date,Account Name,Amount
+6/1/1915,House2,$150.00
+`)
+	cs, err := result.FindCycle("1915-Mar")
+	require.NoError(t, err)
+	require.NoError(t, result.Issue(cs))
+	require.Error(t, result.Issue(cs))
+	require.True(t, result.Registry.Closed("1915-Mar"))
+	require.False(t, result.Registry.Closed("1915-Sep"))
+
+	rec, ok := result.Registry.Lookup("School", "1915-Mar")
+	require.True(t, ok)
+	require.Equal(t, "$300.00", rec.TotalDue.Display())
+
+	// The hash covers a fixed list of what the statement
+	// shows; it changes only with the statement.
+	require.Equal(t, "sha256:d6c2fa3fbcd033dcde0452b5f89567db3876fad1735c3e974d2ccea45ce89c47", rec.Hash)
+	school := *cs.Statements[0].Vars
+	school.LateFeePolicy = "A variable the statement does not show"
+	hash, err := statementHash(cs.Statements[0].User, &school)
+	require.NoError(t, err)
+	require.Equal(t, rec.Hash, hash)
+	school.owes = currency.Units(1)
+	hash, err = statementHash(cs.Statements[0].User, &school)
+	require.NoError(t, err)
+	require.NotEqual(t, rec.Hash, hash)
+
+	// The registry file round trips.
+	name := t.TempDir() + "/issued.csv"
+	require.NoError(t, result.Registry.Write(name))
+	data, err := os.ReadFile(name)
+	require.NoError(t, err)
+	require.NoError(t, afs.WriteFile("issued.csv", data, 0644))
+
+	// A payment after the bill date changes only the open cycle.
+	result = run(`
+Date,Account Name,Amount
+6/1/1915,House2,$150.00
+7/1/1915,House3,$150.00
+`)
+	chs, err := result.Changes()
+	require.NoError(t, err)
+	require.Equal(t, 0, len(chs), "%v", chs)
+
+	// A payment dated before the closed cycle's bill date is
+	// refused.
+	result = run(`
+Date,Account Name,Amount
+4/1/1915,House3,$50.00
+6/1/1915,House2,$150.00
+`)
+	chs, err = result.Changes()
+	require.NoError(t, err)
+	require.Equal(t, 1, len(chs))
+	require.Equal(t, "House3 1915-Mar: prior balance $0.00 => -$50.00; total due $150.00 => $100.00", chs[0].String())
+
+	err = Output(result)
+	require.ErrorContains(t, err, "issued statements would change")
+	require.ErrorContains(t, err, "House3 1915-Mar")
+}
+
+func TestLogicItemized(t *testing.T) {
+	afs, inputs := newFixture(t, map[string]string{
+		"users.csv": `
+Account Name,User Name,Service Address,Billing Address,First Period Start,Commercial
+House2,Miller,"2 Road; Caspar, CA 91234","2 Road; Caspar, CA 91234",10/1/1914,FALSE
+House3,Sawyer,"3 Road; Caspar, CA 91234","3 Road; Caspar, CA 91234",10/1/1914,FALSE
+`,
+		"business.csv": `
+Name,Address,Contact,Expense Appendix
+"Water Company","1 Drive; Caspar, CA 91234",p: 555-555-5555; e: test@water.com,TRUE
+`,
+		"cycles.csv": `
+Period Start,Operations,Utilities,Insurance,Taxes,Bill Date,Method,Margin,Effective Connections,Inactive,Itemized
+10/1/1914,"$0.00",$0.00,"$0.00","$0.00",5/1/1915,Normal,0.0,2,,TRUE
+`,
+		"expenses.csv": `
+Date,Vendor,Category,Amount,Description
+10/3/1914,Pump Co,Operations,$250.00,Chlorine
+11/1/1914,Power Co,Utilities,$150.00,
+1/10/1915,County,Taxes,$200.00,Property tax
+`,
+		"payments.csv": `
+Date,Account Name,Amount
+6/1/1915,House2,$150.00
+`,
+	})
+
+	inputs.ExpensesFile = "expenses.csv"
+	result, err := Logic(inputs, afs)
+	require.NoError(t, err)
+
+	vars := result.Cycles[0].Statements[0].Vars
+	require.Equal(t, "$250.00", vars.Operations)
+	require.Equal(t, "$150.00", vars.Utilities)
+	require.Equal(t, "$100.00", vars.Taxes)
+	require.Equal(t, "$250.00", vars.Pay)
+	require.Equal(t, []ExpenseItem{
+		{"10/3/1914", "Pump Co", "Operations", "$250.00", "Chlorine"},
+		{"11/1/1914", "Power Co", "Utilities", "$150.00", ""},
+		{"1/10/1915", "County", "Taxes", "$200.00", "Property tax"},
+	}, vars.Items)
+}
+
+func TestLogicItemizedRegistry(t *testing.T) {
+	afs, inputs := newFixture(t, map[string]string{
+		"users.csv": `
+Account Name,User Name,Service Address,Billing Address,First Period Start,Commercial
+House2,Miller,"2 Road; Caspar, CA 91234","2 Road; Caspar, CA 91234",10/1/1914,FALSE
+House3,Sawyer,"3 Road; Caspar, CA 91234","3 Road; Caspar, CA 91234",10/1/1914,FALSE
+`,
+		"payments.csv": `
+Date,Account Name,Amount
+6/1/1915,House2,$150.00
+`,
+	})
+
+	inputs.RegistryFile = "issued.csv"
+
+	// Issue the first cycle without itemized expenses.
+	require.NoError(t, afs.WriteFile("business.csv", []byte(`
+Name,Address,Contact
+"Water Company","1 Drive; Caspar, CA 91234",p: 555-555-5555; e: test@water.com
+`), 0644))
+	require.NoError(t, afs.WriteFile("cycles.csv", []byte(`
+Period Start,Operations,Utilities,Insurance,Taxes,Bill Date,Method,Margin,Effective Connections
+10/1/1914,"$300.00",$300.00,"$0.00","$0.00",5/1/1915,Normal,0.0,2
+`), 0644))
+	result, err := Logic(inputs, afs)
+	require.NoError(t, err)
+	require.NoError(t, result.Issue(result.Cycles[0]))
+	name := t.TempDir() + "/issued.csv"
+	require.NoError(t, result.Registry.Write(name))
+	data, err := os.ReadFile(name)
+	require.NoError(t, err)
+	require.NoError(t, afs.WriteFile("issued.csv", data, 0644))
+
+	// Upgrade to itemized expenses with the appendix, for the
+	// next cycle.
+	require.NoError(t, afs.WriteFile("business.csv", []byte(`
+Name,Address,Contact,Expense Appendix
+"Water Company","1 Drive; Caspar, CA 91234",p: 555-555-5555; e: test@water.com,TRUE
+`), 0644))
+	require.NoError(t, afs.WriteFile("cycles.csv", []byte(`
+Period Start,Operations,Utilities,Insurance,Taxes,Bill Date,Method,Margin,Effective Connections,Itemized
+10/1/1914,"$300.00",$300.00,"$0.00","$0.00",5/1/1915,Normal,0.0,2,
+4/1/1915,"$0.00",$0.00,"$0.00","$0.00",10/15/1915,Normal,0.0,2,TRUE
+`), 0644))
+	require.NoError(t, afs.WriteFile("expenses.csv", []byte(`
+Date,Vendor,Category,Amount,Description
+4/3/1915,Pump Co,Operations,$250.00,Chlorine
+5/1/1915,Power Co,Utilities,$150.00,
+`), 0644))
+	inputs.ExpensesFile = "expenses.csv"
+	result, err = Logic(inputs, afs)
+	require.NoError(t, err)
+	require.Empty(t, result.Cycles[0].Statements[0].Vars.Items)
+	require.Equal(t, 2, len(result.Cycles[1].Statements[0].Vars.Items))
+
+	// The issued statements verify.
+	chs, err := result.Changes()
+	require.NoError(t, err)
+	require.Empty(t, chs)
+}
+
+func TestLogicCapital(t *testing.T) {
+	afs, inputs := newFixture(t, map[string]string{
+		"users.csv": `
+Account Name,User Name,Service Address,Billing Address,First Period Start,Commercial
+House2,Miller,"2 Road; Caspar, CA 91234","2 Road; Caspar, CA 91234",10/1/1914,FALSE
+House3,Sawyer,"3 Road; Caspar, CA 91234","3 Road; Caspar, CA 91234",10/1/1914,FALSE
+`,
+		"cycles.csv": `
+Period Start,Operations,Utilities,Insurance,Taxes,Bill Date,Method,Margin,Effective Connections,Inactive
+10/1/1914,"$300.00",$300.00,"$0.00","$0.00",5/1/1915,Normal,0.5,2,
+4/1/1915,"$300.00",$300.00,"$0.00","$0.00",11/1/1915,Normal,0.0,2,
+`,
+		"capital.csv": `
+Name,Date,Cost,Life Years,Funding
+Pump,6/1/1915,$800.00,2,Expense
+Chlorinator,7/1/1915,$200.00,5,Reserve
+`,
+		"payments.csv": `
+Date,Account Name,Amount
+6/1/1915,House2,$450.00
+6/1/1915,House3,$450.00
+`,
+	})
+
+	inputs.CapitalFile = "capital.csv"
+	result, err := Logic(inputs, afs)
+	require.NoError(t, err)
+
+	require.True(t, result.Cycles[0].Expenses.Capital.IsZero())
+	require.Equal(t, 0, len(result.Cycles[0].Amortizations))
+
+	// A quarter of the pump is billed, the chlorinator is not.
+	vars := result.Cycles[1].Statements[0].Vars
+	require.Equal(t, "$200.00", vars.Capital)
+	require.Equal(t, "$800.00", vars.TotalCost)
+	require.Equal(t, "$400.00", vars.Pay)
+
+	asOf, err := csv.ParseDate("12/31/1915")
+	require.NoError(t, err)
+	s := result.Cycles[1].Summary(result.Accounts, asOf)
+	require.Equal(t, 2, len(s.Amortizations))
+	require.Equal(t, "$20.00", s.Amortizations[1].Amount.Display())
+
+	var buf bytes.Buffer
+	require.NoError(t, s.WriteCSV(&buf))
+	require.Contains(t, buf.String(), "Capital in expenses,$200.00\n")
+	require.Contains(t, buf.String(), "\nPump,Expense,1 of 4,$800.00,$200.00,$600.00\n")
+
+	// The chlorinator is withdrawn from the margin of the first
+	// cycle.
+	l, err := result.Reserve(nil, asOf)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(l.Entries))
+	require.Equal(t, "Capital purchase: Chlorinator", l.Entries[1].Description)
+	require.Equal(t, "$100.00", l.Balance.Display())
+
+	// Statements issued before the capital file was added still
+	// verify.
+	inputs.CapitalFile = ""
+	inputs.RegistryFile = "issued.csv"
+	before, err := Logic(inputs, afs)
+	require.NoError(t, err)
+	require.Empty(t, before.Cycles[0].Statements[0].Vars.Capital)
+	require.NoError(t, before.Issue(before.Cycles[0]))
+	name := t.TempDir() + "/issued.csv"
+	require.NoError(t, before.Registry.Write(name))
+	issued, err := os.ReadFile(name)
+	require.NoError(t, err)
+	require.NoError(t, afs.WriteFile("issued.csv", issued, 0644))
+
+	inputs.CapitalFile = "capital.csv"
+	after, err := Logic(inputs, afs)
+	require.NoError(t, err)
+	require.True(t, after.Registry.Closed("1915-Mar"))
+	chs, err := after.Changes()
+	require.NoError(t, err)
+	require.Empty(t, chs)
+}
+
+func TestLogicWeights(t *testing.T) {
+	afs, inputs := newFixture(t, map[string]string{
+		"users.csv": `
+Account Name,User Name,Service Address,Billing Address,First Period Start,Commercial
+School,School,"1 Road; Caspar, CA 91234","1 Road; Caspar, CA 91234",10/1/1914,TRUE
+House2,Miller,"2 Road; Caspar, CA 91234","2 Road; Caspar, CA 91234",10/1/1914,FALSE
+House3,Sawyer,"3 Road; Caspar, CA 91234","3 Road; Caspar, CA 91234",10/1/1914,FALSE
+`,
+		// The connections are derived from the weights.
+		"cycles.csv": `
+Period Start,Operations,Utilities,Insurance,Taxes,Bill Date,Method,Margin,Effective Connections,Inactive
+10/1/1914,"$300.00",$300.00,"$0.00","$0.00",5/1/1915,Normal,0.0,0,
+4/1/1915,"$300.00",$300.00,"$0.00","$0.00",11/1/1915,Normal,0.0,0,
+`,
+		// House2 installs a 1" meter, House3 has an explicit weight,
+		// and the School keeps its Commercial weight.
+		"weights.csv": `
+Account Name,Effective Date,Weight,Meter Size
+House2,4/1/1915,,1
+House3,10/1/1914,1,
+`,
+		"sizes.csv": `
+Meter Size,Equivalents
+5/8,1
+1,3
+`,
+		"payments.csv": `
+Date,Account Name,Amount
+6/1/1915,School,$300.00
+`,
+	})
+
+	inputs.WeightsFile = "weights.csv"
+	inputs.MeterSizesFile = "sizes.csv"
+	result, err := Logic(inputs, afs)
+	require.NoError(t, err)
+
+	require.Equal(t, 4, result.Cycles[0].Expenses.EffectiveConnections)
+	require.Equal(t, 6, result.Cycles[1].Expenses.EffectiveConnections)
+
+	for i, pays := range [][]string{
+		{"$300.00", "$150.00", "$150.00"},
+		{"$200.00", "$300.00", "$100.00"},
+	} {
+		for j, pay := range pays {
+			require.Equal(t, pay, result.Cycles[i].Statements[j].Vars.Pay)
+		}
+	}
+	require.Equal(t, 3, result.Cycles[1].Statements[1].Vars.UserWeight)
+
+	// An entered count must match the weights.
+	require.NoError(t, afs.WriteFile("cycles.csv", []byte(`
+Period Start,Operations,Utilities,Insurance,Taxes,Bill Date,Method,Margin,Effective Connections,Inactive
+10/1/1914,"$300.00",$300.00,"$0.00","$0.00",5/1/1915,Normal,0.0,4,
+4/1/1915,"$300.00",$300.00,"$0.00","$0.00",11/1/1915,Normal,0.0,4,
+`), 0644))
+	_, err = Logic(inputs, afs)
+	require.ErrorContains(t, err, "effective connections 4 do not match the account weights 6")
+}
+
+func TestLogicProration(t *testing.T) {
+	afs, inputs := newFixture(t, map[string]string{
+		// House2 connects in February, House3 is sold in December.
+		"users.csv": `
+Account Name,User Name,Service Address,Billing Address,First Period Start,Service Start,Service End
+School,School,"1 Road; Caspar, CA 91234","1 Road; Caspar, CA 91234",10/1/1914,,
+House2,Miller,"2 Road; Caspar, CA 91234","2 Road; Caspar, CA 91234",10/1/1914,2/1/1915,
+House3,Sawyer,"3 Road; Caspar, CA 91234","3 Road; Caspar, CA 91234",10/1/1914,,12/31/1914
+`,
+		"cycles.csv": `
+Period Start,Operations,Utilities,Insurance,Taxes,Bill Date,Method,Margin,Effective Connections,Inactive
+10/1/1914,"$300.00",$300.00,"$0.00","$0.00",5/1/1915,Normal,0.0,3,
+4/1/1915,"$300.00",$300.00,"$0.00","$0.00",11/1/1915,Normal,0.0,0,
+`,
+		"payments.csv": `
+Date,Account Name,Amount
+6/1/1915,House3,$165.77
+`,
+	})
+
+	result, err := Logic(inputs, afs)
+	require.NoError(t, err)
+
+	// The shares are in proportion to 182, 59, and 92 days.
+	stmts := result.Cycles[0].Statements
+	require.Equal(t, 3, len(stmts))
+	require.Equal(t, "$327.93", stmts[0].Vars.Pay)
+	require.Equal(t, "$106.30", stmts[1].Vars.Pay)
+	require.Equal(t, "$165.77", stmts[2].Vars.Pay)
+
+	// The leftover cents go to the accounts in the shuffled order
+	// used for connections, not to the first accounts.
+	order := leftoverOrder(result.Cycles[0].Expenses, 3)
+	a := result.Cycles[0].Audit()
+	require.Equal(t, 2, len(a.Cents))
+	for k, c := range a.Cents {
+		require.Equal(t, order[k]+1, c.Party)
+	}
+
+	require.False(t, stmts[0].Vars.Prorated)
+	require.True(t, stmts[1].Vars.Prorated)
+	require.Equal(t, "February 1, 1915", stmts[1].Vars.ServiceFrom)
+	require.Equal(t, "March 31, 1915", stmts[1].Vars.ServiceTo)
+	require.Equal(t, 59, stmts[1].Vars.ServiceDays)
+	require.Equal(t, 182, stmts[1].Vars.PeriodDays)
+
+	require.True(t, stmts[2].Vars.FinalBill)
+	require.Equal(t, "1915-Mar-Final", stmts[2].Vars.InvoiceName())
+	require.Equal(t, 92, stmts[2].Vars.ServiceDays)
+
+	asOf, err := csv.ParseDate("12/31/1915")
+	require.NoError(t, err)
+	s := result.Cycles[0].Summary(result.Accounts, asOf)
+	require.Equal(t, s.Total, s.Billed)
+	require.Equal(t, "$327.92", s.Base.Display())
+	require.Equal(t, 2, s.RoundedUp)
+
+	// The sold property is not billed after its final bill.
+	require.Equal(t, 2, len(result.Cycles[1].Statements))
+	require.Equal(t, 2, result.Cycles[1].Expenses.EffectiveConnections)
+	require.Equal(t, "$300.00", result.Cycles[1].Statements[1].Vars.Pay)
+	require.True(t, result.Accounts.Lookup("House3").Balance(asOf).IsZero())
+}
+
+func TestLogicServices(t *testing.T) {
+	afs, inputs := newFixture(t, map[string]string{
+		"users.csv": `
+Account Name,User Name,Service Address,Billing Address,First Period Start,Services
+School,School,"1 Road; Caspar, CA 91234","1 Road; Caspar, CA 91234",10/1/1914,
+House2,Miller,"2 Road; Caspar, CA 91234","2 Road; Caspar, CA 91234",10/1/1914,Septic
+House3,Sawyer,"3 Road; Caspar, CA 91234","3 Road; Caspar, CA 91234",10/1/1914,Septic
+`,
+		"business.csv": `
+Name,Address,Contact,Service
+"Water Company","1 Drive; Caspar, CA 91234",p: 555-555-5555; e: test@water.com,Water
+`,
+		// The septic service is billed in the first cycle only.
+		"cycles.csv": `
+Period Start,Operations,Utilities,Insurance,Taxes,Bill Date,Method,Margin,Effective Connections,Inactive,Service
+10/1/1914,"$300.00",$300.00,"$0.00","$0.00",5/1/1915,Normal,0.0,0,,
+10/1/1914,"$150.00",$50.00,"$0.00","$0.00",5/1/1915,Normal,0.0,0,,Septic
+4/1/1915,"$300.00",$300.00,"$0.00","$0.00",11/1/1915,Normal,0.0,0,,Water
+`,
+		"payments.csv": `
+Date,Account Name,Amount
+6/1/1915,House2,$300.00
+`,
+	})
+
+	result, err := Logic(inputs, afs)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(result.Cycles))
+
+	school := result.Cycles[0].Statements[0].Vars
+	require.Equal(t, "$200.00", school.Pay)
+	require.Equal(t, "$200.00", school.TotalDue)
+	require.Equal(t, 0, len(school.Sections))
+	require.Equal(t, "", school.Service)
+
+	house2 := result.Cycles[0].Statements[1].Vars
+	require.Equal(t, "$200.00", house2.Pay)
+	require.Equal(t, "$300.00", house2.TotalDue)
+	require.Equal(t, "Water", house2.Service)
+	require.Equal(t, 1, len(house2.Sections))
+	require.Equal(t, "Septic", house2.Sections[0].Service)
+	require.Equal(t, "$100.00", house2.Sections[0].Pay)
+	require.Equal(t, "0.5000", house2.Sections[0].Fraction)
+
+	content := house2.Content()
+	require.Equal(t, 3, len(content))
+	require.Equal(t, "Water", content[0].Header[0])
+	require.Contains(t, content[0].Rows, []string{"Water charge", "$200.00"})
+	require.Equal(t, "Septic", content[1].Header[0])
+	require.Contains(t, content[1].Rows, []string{"Septic charge", "$100.00"})
+	require.Equal(t, []string{"New balance", "$300.00"}, content[2].Rows[0])
+	require.Contains(t, content[2].Rows, []string{"Amount due", "$300.00"})
+
+	// House2 paid both services; the second cycle has no septic
+	// section.
+	house2 = result.Cycles[1].Statements[1].Vars
+	require.Equal(t, 0, len(house2.Sections))
+	require.Equal(t, "$200.00", house2.TotalDue)
+	require.Equal(t, "$500.00", result.Cycles[1].Statements[2].Vars.TotalDue)
+
+	for _, test := range []struct {
+		file, data, err string
+	}{
+		{"business.csv", `
+Name,Address,Contact
+"Water Company","1 Drive; Caspar, CA 91234",p: 555-555-5555
+`, "business service is required"},
+		{"users.csv", `
+Account Name,User Name,Service Address,Billing Address,First Period Start,Services
+House2,Miller,"2 Road; Caspar, CA 91234","2 Road; Caspar, CA 91234",10/1/1914,Trash
+`, "service Trash has no cycles"},
+		{"cycles.csv", `
+Period Start,Operations,Utilities,Insurance,Taxes,Bill Date,Method,Margin,Effective Connections,Inactive,Service
+10/1/1914,"$300.00",$300.00,"$0.00","$0.00",5/1/1915,Normal,0.0,0,,
+4/1/1915,"$150.00",$50.00,"$0.00","$0.00",11/1/1915,Normal,0.0,0,,Septic
+`, "Septic cycle 1915-Sep has no primary cycle"},
+		{"cycles.csv", `
+Period Start,Operations,Utilities,Insurance,Taxes,Bill Date,Method,Margin,Effective Connections,Inactive,Service
+10/1/1914,"$300.00",$300.00,"$0.00","$0.00",5/1/1915,Normal,0.0,0,,
+10/1/1914,"$150.00",$50.00,"$0.00","$0.00",5/1/1915,Metered,0.0,0,,Septic
+`, "an additional service cannot be metered"},
+	} {
+		orig, err := afs.ReadFile(test.file)
+		require.NoError(t, err)
+		require.NoError(t, afs.WriteFile(test.file, []byte(test.data), 0644))
+		_, err = Logic(inputs, afs)
+		require.ErrorContains(t, err, test.err)
+		require.NoError(t, afs.WriteFile(test.file, orig, 0644))
+	}
+}
+
+func TestLogicAudit(t *testing.T) {
+	afs, inputs := newFixture(t, map[string]string{
+		"users.csv": `
+Account Name,User Name,Service Address,Billing Address,First Period Start
+House1,Smith,"1 Road; Caspar, CA 91234","1 Road; Caspar, CA 91234",10/1/1914
+House2,Miller,"2 Road; Caspar, CA 91234","2 Road; Caspar, CA 91234",10/1/1914
+House3,Sawyer,"3 Road; Caspar, CA 91234","3 Road; Caspar, CA 91234",10/1/1914
+`,
+		"business.csv": `
+Name,Address,Contact,Rounding
+"Water Company","1 Drive; Caspar, CA 91234",p: 555-555-5555; e: test@water.com,HalfUp
+`,
+		// $100.05 with a 15% margin is exactly $115.0575.
+		"cycles.csv": `
+Period Start,Operations,Utilities,Insurance,Taxes,Bill Date,Margin
+10/1/1914,"$60.05",$40.00,"$0.00","$0.00",5/1/1915,0.15
+`,
+		"payments.csv": `
+Date,Account Name,Amount
+6/1/1915,House1,$38.35
+`,
+	})
+
+	result, err := Logic(inputs, afs)
+	require.NoError(t, err)
+
+	cycle, err := result.FindCycle("1915-Mar")
+	require.NoError(t, err)
+	a := cycle.Audit()
+
+	require.Equal(t, "$115.0575", a.Exact)
+	require.Equal(t, currency.RoundHalfUp, a.Rounding)
+	require.Equal(t, "$115.06", a.Total.Display())
+	require.Equal(t, a.Total, a.Billed)
+	require.Equal(t, 3, len(a.Parties))
+
+	// One leftover cent, assigned to the account that received
+	// it on its statement.
+	require.Equal(t, 1, len(a.Cents))
+	received := a.Cents[0].AccountName
+	for _, p := range a.Parties {
+		require.Equal(t, "$38.3533", p.Exact)
+		require.Equal(t, "$38.35", p.Down.Display())
+		if p.AccountName == received {
+			require.Equal(t, 1, p.Leftover)
+			require.Equal(t, "$38.36", p.Amount.Display())
+		} else {
+			require.Equal(t, 0, p.Leftover)
+			require.Equal(t, "$38.35", p.Amount.Display())
+		}
+	}
+	for _, stmt := range cycle.Statements {
+		if stmt.User.AccountName == received {
+			require.Equal(t, "$38.36", stmt.Vars.Pay)
+		}
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, a.WriteCSV(&buf))
+	require.Contains(t, buf.String(), "Exact total,$115.0575\nRounding,HalfUp\nTotal,$115.06\nBilled,$115.06\n")
+	require.Contains(t, buf.String(), "\n\nLeftover Cent,Party,Account Name\n1,")
+}
+
+func TestLogicAdjustments(t *testing.T) {
+	afs, inputs := newFixture(t, map[string]string{
+		"users.csv": `
+Account Name,User Name,Service Address,Billing Address,First Period Start
+School,School,"1 Road; Caspar, CA 91234","1 Road; Caspar, CA 91234",10/1/1914
+House2,Miller,"2 Road; Caspar, CA 91234","2 Road; Caspar, CA 91234",10/1/1914
+House3,Sawyer,"3 Road; Caspar, CA 91234","3 Road; Caspar, CA 91234",10/1/1914
+`,
+		"cycles.csv": `
+Period Start,Operations,Utilities,Insurance,Taxes,Bill Date,Margin,Effective Connections
+10/1/1914,"$300.00",$300.00,"$0.00","$0.00",5/1/1915,0.0,3
+4/1/1915,"$300.00","$300.00","$0.00","$0.00",10/15/1915,0.0,3
+`,
+		"payments.csv": `
+Date,Account Name,Amount
+6/1/1915,School,$250.00
+6/1/1915,House2,$200.00
+6/1/1915,House3,$190.00
+`,
+		"adjustments.csv": `
+Date,Account Name,Kind,Amount,Reason,Event
+3/1/1915,House3,Credit,$10.00,Outage credit,Outage 2/1915
+7/20/1915,House2,Credit,$25.00,Boil-water notice,Boil water 7/1915
+7/20/1915,House3,Credit,$25.00,Boil-water notice,Boil water 7/1915
+8/1/1915,School,Refund,$50.00,Overpayment refund,
+6/1/1915,House3,Adjustment,-$5.00,Meter read correction,
+`,
+		"stmts/1915-Sep.txt": "{{range .Adjustments}}{{.Kind}} {{.Amount}}: {{.Reason}}\n{{end}}",
+	})
+
+	inputs.AdjustmentsFile = "adjustments.csv"
+	result, err := Logic(inputs, afs)
+	require.NoError(t, err)
+
+	// The outage credit appears on the first statement.
+	house3 := result.Cycles[0].Statements[2].Vars
+	require.True(t, house3.HasAdjustment)
+	require.Equal(t, []Adjustment{{
+		Date:   "March 1, 1915",
+		Kind:   "Credit",
+		Reason: "Outage credit",
+		Amount: "-$10.00",
+		amount: currency.Units(-1000),
+	}}, house3.Adjustments)
+	require.Equal(t, "$0.00", house3.PriorBalance)
+	require.Equal(t, "$190.00", house3.TotalDue)
+	require.False(t, result.Cycles[0].Statements[0].Vars.HasAdjustment)
+
+	// The refund of School's overpayment is charged back.
+	cycle1 := result.Cycles[1]
+	school := cycle1.Statements[0].Vars
+	require.Equal(t, "-$50.00", school.PriorBalance)
+	require.Equal(t, "$200.00", school.TotalDue)
+	text, err := school.BodyText()
+	require.NoError(t, err)
+	require.Equal(t, "Refund $50.00: Overpayment refund\n", text)
+
+	house2 := cycle1.Statements[1].Vars
+	require.Equal(t, "$0.00", house2.PriorBalance)
+	require.Equal(t, "$175.00", house2.TotalDue)
+
+	// Adjustments since the prior statement, in file order.
+	house3 = cycle1.Statements[2].Vars
+	text, err = house3.BodyText()
+	require.NoError(t, err)
+	require.Equal(t, "Credit -$25.00: Boil-water notice\nAdjustment -$5.00: Meter read correction\n", text)
+	require.Equal(t, "$0.00", house3.PriorBalance)
+	require.Equal(t, "$170.00", house3.TotalDue)
+
+	// An account is credited once per event.
+	require.NoError(t, afs.WriteFile("adjustments.csv", []byte(`
+Date,Account Name,Kind,Amount,Reason,Event
+7/20/1915,House2,Credit,$25.00,Boil-water notice,Boil water 7/1915
+7/21/1915,House2,Credit,$25.00,Boil-water notice,Boil water 7/1915
+`), 0644))
+	_, err = Logic(inputs, afs)
+	require.EqualError(t, err, `adjustments.csv: account House2 already adjusted for event "Boil water 7/1915" on 7/20/1915`)
+
+	require.NoError(t, afs.WriteFile("adjustments.csv", []byte(`
+Date,Account Name,Kind,Amount,Reason,Event
+7/20/1915,House9,Credit,$25.00,Boil-water notice,
+`), 0644))
+	_, err = Logic(inputs, afs)
+	require.EqualError(t, err, "adjustments.csv: adjustment account not found: House9")
+}
+
+func TestLogicInvoices(t *testing.T) {
+	afs, inputs := newFixture(t, map[string]string{
+		"users.csv": `
+Account Name,User Name,Service Address,Billing Address,First Period Start
+School,School,"1 Road; Caspar, CA 91234","1 Road; Caspar, CA 91234",10/1/1914
+House2,Miller,"2 Road; Caspar, CA 91234","2 Road; Caspar, CA 91234",10/1/1914
+House3,Sawyer,"3 Road; Caspar, CA 91234","3 Road; Caspar, CA 91234",10/1/1914
+`,
+		"cycles.csv": `
+Period Start,Operations,Utilities,Insurance,Taxes,Bill Date,Margin,Effective Connections
+10/1/1914,"$300.00",$300.00,"$0.00","$0.00",5/1/1915,0.0,3
+4/1/1915,"$300.00","$300.00","$0.00","$0.00",10/15/1915,0.0,3
+`,
+		"payments.csv": `
+Date,Account Name,Amount
+6/1/1915,School,$200.00
+6/1/1915,House2,$200.00
+6/1/1915,House3,$200.00
+`,
+		// Written by cmd/invoice.
+		"invoices.csv": `Number,Date,Account Name,Job Name,Amount
+1,3/1/1915,House3,Emergency repair,$19.50
+2,6/15/1915,House2,Connection fee,$500.00
+3,11/1/1915,House2,Meter test,$25.00
+`,
+		"stmts/1915-Sep.txt": "{{range .Invoices}}Invoice {{.Number}} {{.JobName}} {{.Amount}}\n{{end}}",
+	})
+
+	inputs.InvoicesFile = "invoices.csv"
+	result, err := Logic(inputs, afs)
+	require.NoError(t, err)
+
+	house3 := result.Cycles[0].Statements[2].Vars
+	require.True(t, house3.HasInvoice)
+	require.Equal(t, "$0.00", house3.PriorBalance)
+	require.Equal(t, "$219.50", house3.TotalDue)
+	require.Contains(t, house3.Content()[0].Rows, []string{"Invoice 1 (Emergency repair)", "$19.50"})
+
+	cycle1 := result.Cycles[1]
+	house2 := cycle1.Statements[1].Vars
+	text, err := house2.BodyText()
+	require.NoError(t, err)
+	require.Equal(t, "Invoice 2 Connection fee $500.00\n", text)
+	require.Equal(t, "$0.00", house2.PriorBalance)
+	require.Equal(t, "$700.00", house2.TotalDue)
+
+	// The unpaid invoice is in the prior balance.
+	house3 = cycle1.Statements[2].Vars
+	require.False(t, house3.HasInvoice)
+	require.Equal(t, "$19.50", house3.PriorBalance)
+	require.Equal(t, "$219.50", house3.TotalDue)
+
+	// Invoices after the last statement are charged for the next.
+	asOf, err := csv.ParseDate("12/31/1915")
+	require.NoError(t, err)
+	require.Equal(t, "$725.00", result.Accounts.Lookup("House2").Balance(asOf).Display())
+
+	require.NoError(t, afs.WriteFile("invoices.csv", []byte(`Number,Date,Account Name,Job Name,Amount
+1,3/1/1915,House9,Emergency repair,$19.50
+`), 0644))
+	_, err = Logic(inputs, afs)
+	require.EqualError(t, err, "invoices.csv: invoice 1 account not found: House9")
+}
@@ -0,0 +1,279 @@
+package logic
+
+import (
+	"fmt"
+	"math/big"
+	"path"
+	"sort"
+
+	"github.com/jmacd/caspar.water/cmd/internal/billing/account"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/csv"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/currency"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/invoice"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/payer"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/payment"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/user"
+)
+
+// PayerStatement is one consolidated statement covering each of a
+// payer's accounts.
+type PayerStatement struct {
+	Payer   payer.Payer
+	User    user.User
+	Vars    *Vars
+	PdfPath string
+}
+
+// payerPayments allocates payments made to a payer across its
+// accounts.  The allocation depends on what each account owes on the
+// payment date, so these are posted in date order as billing
+// proceeds.
+type payerPayments struct {
+	accts   *account.Accounts
+	payers  map[string]payer.Payer
+	pending []payment.Payment
+}
+
+func newPayerPayments(accts *account.Accounts, payers []payer.Payer) (*payerPayments, error) {
+	pp := &payerPayments{
+		accts:  accts,
+		payers: map[string]payer.Payer{},
+	}
+	owner := map[string]string{}
+	for _, p := range payers {
+		if accts.Lookup(p.PayerName) != nil {
+			return nil, fmt.Errorf("payer name is also an account name: %s", p.PayerName)
+		}
+		if _, ok := pp.payers[p.PayerName]; ok {
+			return nil, fmt.Errorf("duplicate payer name: %s", p.PayerName)
+		}
+		for _, name := range p.Accounts {
+			if accts.Lookup(name) == nil {
+				return nil, fmt.Errorf("payer %s account not found: %s", p.PayerName, name)
+			}
+			if other, ok := owner[name]; ok {
+				return nil, fmt.Errorf("account %s has two payers: %s and %s", name, other, p.PayerName)
+			}
+			owner[name] = p.PayerName
+		}
+		pp.payers[p.PayerName] = p
+	}
+	return pp, nil
+}
+
+// add queues a payment if it names a payer.
+func (pp *payerPayments) add(pay payment.Payment) bool {
+	if _, ok := pp.payers[pay.AccountName]; !ok {
+		return false
+	}
+	pp.pending = append(pp.pending, pay)
+	sort.SliceStable(pp.pending, func(i, j int) bool {
+		return pp.pending[i].Date.Before(pp.pending[j].Date)
+	})
+	return true
+}
+
+// settle posts the queued payments dated on or before through, or
+// all of them when through is nil.
+func (pp *payerPayments) settle(through *csv.Date) error {
+	for len(pp.pending) != 0 {
+		pay := pp.pending[0]
+		if through != nil && pay.Date.Date().After(through.Date()) {
+			return nil
+		}
+		pp.pending = pp.pending[1:]
+
+		p := pp.payers[pay.AccountName]
+		owed := make([]currency.Amount, len(p.Accounts))
+		for i, name := range p.Accounts {
+			owed[i] = pp.accts.Lookup(name).Balance(pay.Date)
+		}
+		memo := "Payer " + p.PayerName
+		if pay.Comments != "" {
+			memo += ": " + pay.Comments
+		}
+		for i, amt := range p.Allocate(pay.Amount, owed) {
+			if amt.IsZero() {
+				continue
+			}
+			if err := pp.accts.Lookup(p.Accounts[i]).EnterPayment(payment.Payment{
+				Date:        pay.Date,
+				AccountName: p.Accounts[i],
+				Amount:      amt,
+				Comments:    memo,
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// consolidate combines the statements for a payer's accounts.  The
+// result is nil if none of the accounts were billed.
+func consolidate(p payer.Payer, compStmt *CompanyStatement, outputPath string) *PayerStatement {
+	var services []*Vars
+	for _, name := range p.Accounts {
+		for _, stmt := range compStmt.Statements {
+			if stmt.User.AccountName == name {
+				services = append(services, stmt.Vars)
+			}
+		}
+	}
+	if len(services) == 0 {
+		return nil
+	}
+
+	vars := *services[0]
+	vars.Services = services
+	vars.AccountName = p.PayerName
+	vars.ServiceAddress = ""
+	vars.UserWeight = 0
+	vars.owes = currency.Units(0)
+	vars.priorBalance = currency.Units(0)
+	vars.totalDue = currency.Units(0)
+	vars.lateFee = currency.Units(0)
+	vars.waived = currency.Units(0)
+	vars.trueUp = currency.Units(0)
+	vars.adjusted = currency.Units(0)
+	vars.invoiced = currency.Units(0)
+	vars.fraction = new(big.Rat)
+	vars.gallons = 0
+	vars.OpenReading = ""
+	vars.OpenReadingDate = ""
+	vars.CloseReading = ""
+	vars.CloseReadingDate = ""
+	vars.Tiers = nil
+	vars.TrueUps = nil
+	vars.Adjustments = nil
+	vars.Invoices = nil
+	vars.Service = ""
+	vars.Sections = nil
+	vars.Prorated = false
+	vars.ServiceFrom = ""
+	vars.ServiceTo = ""
+	vars.ServiceDays = 0
+	vars.PeriodDays = 0
+	vars.FinalBill = false
+
+	for _, svc := range services {
+		vars.UserWeight += svc.UserWeight
+		vars.owes = currency.Sum(vars.owes, svc.owes)
+		vars.priorBalance = currency.Sum(vars.priorBalance, svc.priorBalance)
+		vars.totalDue = currency.Sum(vars.totalDue, svc.totalDue)
+		vars.lateFee = currency.Sum(vars.lateFee, svc.lateFee)
+		vars.waived = currency.Sum(vars.waived, svc.waived)
+		vars.trueUp = currency.Sum(vars.trueUp, svc.trueUp)
+		vars.adjusted = currency.Sum(vars.adjusted, svc.adjusted)
+		vars.invoiced = currency.Sum(vars.invoiced, svc.invoiced)
+		vars.fraction = new(big.Rat).Add(vars.fraction, svc.fraction)
+		vars.gallons += svc.gallons
+		vars.HasLateFee = vars.HasLateFee || svc.HasLateFee
+		vars.HasTrueUp = vars.HasTrueUp || svc.HasTrueUp
+		vars.TrueUps = append(vars.TrueUps, svc.TrueUps...)
+		vars.HasAdjustment = vars.HasAdjustment || svc.HasAdjustment
+		vars.Adjustments = append(vars.Adjustments, svc.Adjustments...)
+		vars.HasInvoice = vars.HasInvoice || svc.HasInvoice
+		vars.Invoices = append(vars.Invoices, svc.Invoices...)
+	}
+	vars.Percent = percentText(vars.fraction)
+	vars.Fraction = fractionText(vars.fraction)
+	vars.Pay = vars.owes.Display()
+	vars.PriorBalance = vars.priorBalance.Display()
+	vars.TotalDue = vars.totalDue.Display()
+	vars.LateFee = vars.lateFee.Display()
+	vars.LateFeeWaived = vars.waived.Display()
+	vars.TrueUp = vars.trueUp.Display()
+	vars.Gallons = vars.gallons.Display()
+
+	return &PayerStatement{
+		Payer: p,
+		User: user.User{
+			AccountName:    p.PayerName,
+			UserName:       p.UserName,
+			BillingAddress: p.BillingAddress,
+			Email:          p.Email,
+		},
+		Vars:    &vars,
+		PdfPath: path.Join(outputPath, p.PayerName+".pdf"),
+	}
+}
+
+// consolidatedContent is the expense table and the table of the
+// payer's service addresses.  Metered statements list each address's
+// consumption in place of the expenses.
+func (vars *Vars) consolidatedContent() invoice.Content {
+	rows := vars.expenseRows()
+	rows = append(rows, []string{
+		"Margin",
+		"× " + vars.Margin,
+	})
+
+	header := []string{
+		"Service address",
+	}
+	if vars.Metered {
+		header = append(header, "Consumption")
+	}
+	header = append(header,
+		"New balance",
+		"Prior balance",
+	)
+	adjusted := vars.HasLateFee || vars.HasTrueUp || vars.HasAdjustment || vars.HasInvoice
+	if adjusted {
+		header = append(header, "Adjustments")
+	}
+	header = append(header, "Amount due")
+
+	row := func(name string, v *Vars) []string {
+		r := []string{
+			name,
+		}
+		if vars.Metered {
+			r = append(r, v.Gallons)
+		}
+		r = append(r,
+			v.owes.Display(),
+			v.PriorBalance,
+		)
+		if adjusted {
+			r = append(r, v.adjustments().Display())
+		}
+		return append(r, v.TotalDue)
+	}
+
+	var services [][]string
+	for _, svc := range vars.Services {
+		services = append(services, row(svc.ServiceAddress, svc))
+	}
+	services = append(services,
+		[]string{},
+		row("Total", vars),
+	)
+
+	tables := invoice.Content{
+		{
+			Header: header,
+			Rows:   services,
+		},
+	}
+	if vars.Metered {
+		return tables
+	}
+	return append(invoice.Content{
+		{
+			Header: []string{
+				"Expense",
+				"Cost",
+				"",
+			},
+			Rows: rows,
+		},
+	}, tables...)
+}
+
+// adjustments is the net of late fees, waivers, true-ups, refunds,
+// credits, adjustments, and one-off invoices on the statement.
+func (vars *Vars) adjustments() currency.Amount {
+	return currency.Sum(currency.Difference(vars.lateFee, vars.waived), vars.trueUp, vars.adjusted, vars.invoiced)
+}
@@ -0,0 +1,77 @@
+package logic
+
+import (
+	"math/big"
+
+	"github.com/jmacd/caspar.water/cmd/internal/billing/currency"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/expense"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/user"
+)
+
+// prorated indicates that an account billed for the cycle is served
+// for part of the period.
+func prorated(cycle expense.Cycle, users []user.User) bool {
+	for _, u := range users {
+		if getWeight(u, cycle) == 0 {
+			continue
+		}
+		if days, total := u.ServiceDays(cycle.PeriodStart); days != total {
+			return true
+		}
+	}
+	return false
+}
+
+// prorate divides the total among the accounts billed for the cycle
+// in proportion to their weight times their days of service, so the
+// days not served by a prorated account are shared by the others.
+// The leftover cents go to accounts in the order of leftoverOrder.
+// The base is the charge for one connection served the full period.
+func prorate(div *division, cycle expense.Cycle, users []user.User) {
+	var weights []int64
+	var sum int64
+	for _, u := range users {
+		if !u.Serves(cycle.PeriodStart) {
+			continue
+		}
+		days, _ := u.ServiceDays(cycle.PeriodStart)
+		div.parties = append(div.parties, u.AccountName)
+		weights = append(weights, int64(getWeight(u, cycle)*days))
+		sum += weights[len(weights)-1]
+	}
+	div.alloc = currency.Allocate(div.total, weights, leftoverOrder(cycle, len(weights)))
+
+	div.shares = map[string]share{}
+	var periodDays int
+	for i, name := range div.parties {
+		u := find(users, name)
+		sh := share{
+			owes:     div.alloc.Parts[i],
+			fraction: big.NewRat(weights[i], sum),
+			weight:   getWeight(u, cycle),
+			rounding: currency.Units(int64(div.alloc.Received(i))),
+		}
+		sh.days, sh.periodDays = u.ServiceDays(cycle.PeriodStart)
+		div.shares[name] = sh
+		periodDays = sh.periodDays
+	}
+	div.base = currency.RoundDown.Round(new(big.Rat).Mul(div.total.Rat(), big.NewRat(int64(periodDays), sum)))
+}
+
+func find(users []user.User, name string) user.User {
+	for _, u := range users {
+		if u.AccountName == name {
+			return u
+		}
+	}
+	return user.User{}
+}
+
+// prorateUnits scales an amount by the days of service, rounding to
+// the nearest cent.
+func prorateUnits(a currency.Amount, days, total int) currency.Amount {
+	if days == total {
+		return a
+	}
+	return a.Mul(big.NewRat(int64(days), int64(total)), currency.RoundHalfUp)
+}
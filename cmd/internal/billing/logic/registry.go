@@ -0,0 +1,138 @@
+package logic
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/jmacd/caspar.water/cmd/internal/billing/registry"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/user"
+)
+
+// hashed is the content of a statement that is hashed: the
+// addressee, the invoice number, the amounts due, the rows of its
+// tables, and the body text.
+type hashed struct {
+	UserName       string
+	ServiceAddress string
+	BillingAddress string
+	InvoiceNumber  string
+	AmountDue      string
+	PriorBalance   string
+	TotalDue       string
+	Tables         [][][]string
+	Body           string
+}
+
+// statementHash identifies what a statement shows.
+func statementHash(u user.User, vars *Vars) (string, error) {
+	body, err := vars.BodyText()
+	if err != nil {
+		return "", err
+	}
+	h := hashed{
+		UserName:       u.UserName,
+		ServiceAddress: u.ServiceAddress.OneLine(),
+		BillingAddress: u.BillingAddress.OneLine(),
+		InvoiceNumber:  vars.InvoiceName(),
+		AmountDue:      vars.owes.Display(),
+		PriorBalance:   vars.priorBalance.Display(),
+		TotalDue:       vars.totalDue.Display(),
+		Body:           body,
+	}
+	for _, table := range vars.Content() {
+		h.Tables = append(h.Tables, append([][]string{table.Header}, table.Rows...))
+	}
+	data, err := json.Marshal(h)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return fmt.Sprintf("sha256:%x", sum), nil
+}
+
+func newRecord(name string, u user.User, vars *Vars) (registry.Record, error) {
+	hash, err := statementHash(u, vars)
+	if err != nil {
+		return registry.Record{}, fmt.Errorf("%s: %w", name, err)
+	}
+	return registry.Record{
+		AccountName:   name,
+		Cycle:         vars.CloseMonthDate,
+		InvoiceNumber: vars.InvoiceName(),
+		IssueDate:     vars.cycle.BillDate,
+		AmountDue:     vars.owes,
+		PriorBalance:  vars.priorBalance,
+		TotalDue:      vars.totalDue,
+		Hash:          hash,
+	}, nil
+}
+
+// records returns the statements of the cycle that are mailed: the
+// payers' consolidated statements and the other accounts'.
+func (cs *CompanyStatement) records() ([]registry.Record, error) {
+	consolidated := cs.consolidated()
+
+	var recs []registry.Record
+	for _, ps := range cs.Payers {
+		rec, err := newRecord(ps.Payer.PayerName, ps.User, ps.Vars)
+		if err != nil {
+			return nil, err
+		}
+		recs = append(recs, rec)
+	}
+	for _, stmt := range cs.Statements {
+		if consolidated[stmt.User.AccountName] {
+			continue
+		}
+		rec, err := newRecord(stmt.User.AccountName, stmt.User, stmt.Vars)
+		if err != nil {
+			return nil, err
+		}
+		recs = append(recs, rec)
+	}
+	return recs, nil
+}
+
+// Changes lists the differences between the issued statements of
+// closed cycles and the statements computed for them now.
+func (r *Result) Changes() ([]registry.Change, error) {
+	var chs []registry.Change
+	for _, cs := range r.Cycles {
+		recs, err := cs.records()
+		if err != nil {
+			return nil, err
+		}
+		chs = append(chs, r.Registry.Changes(cs.Name(), recs)...)
+	}
+	return chs, nil
+}
+
+// ChangesError refuses the changes to closed cycles.
+func ChangesError(chs []registry.Change) error {
+	var lines []string
+	for _, ch := range chs {
+		lines = append(lines, "  "+ch.String())
+	}
+	return fmt.Errorf("issued statements would change, enter corrections as journal adjustments dated after the statement:\n%s",
+		strings.Join(lines, "\n"))
+}
+
+// Issue records the cycle's statements in the registry, closing the
+// cycle.
+func (r *Result) Issue(cs *CompanyStatement) error {
+	if r.Registry.Closed(cs.Name()) {
+		return fmt.Errorf("cycle %s was already issued", cs.Name())
+	}
+	recs, err := cs.records()
+	if err != nil {
+		return err
+	}
+	for _, rec := range recs {
+		if err := r.Registry.Add(rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package logic
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/jmacd/caspar.water/cmd/internal/billing/capital"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/csv"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/currency"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/reserve"
+)
+
+// Margins returns the margin collected for each cycle billed through
+// a date, the margin's share of the cycle's collections.
+func (r *Result) Margins(asOf csv.Date) []reserve.Margin {
+	var ms []reserve.Margin
+	for _, cs := range r.Cycles {
+		cycle := cs.Expenses
+		if cycle.Margin == 0 || asOf.Before(cycle.BillDate) {
+			continue
+		}
+		s := cs.Summary(r.Accounts, asOf)
+		margin := currency.Decimal(cycle.Margin)
+		share := new(big.Rat).Quo(margin, new(big.Rat).Add(big.NewRat(1, 1), margin))
+		ms = append(ms, reserve.Margin{
+			Date:   cycle.BillDate,
+			Cycle:  s.Cycle,
+			Amount: s.Collected.Mul(share, r.Business.Rounding),
+		})
+	}
+	return ms
+}
+
+// Reserve builds the reserve ledger through a date from the margin
+// collected, the reserve file's transactions, and the reserve-funded
+// capital purchases.
+func (r *Result) Reserve(txns []reserve.Transaction, asOf csv.Date) (reserve.Ledger, error) {
+	return reserve.Build(r.Margins(asOf), r.withPurchases(txns), asOf)
+}
+
+// withPurchases adds a withdrawal for each reserve-funded capital
+// purchase.
+func (r *Result) withPurchases(txns []reserve.Transaction) []reserve.Transaction {
+	all := append([]reserve.Transaction(nil), txns...)
+	for _, p := range r.Purchases {
+		if p.Funding != capital.ReserveFunding {
+			continue
+		}
+		all = append(all, reserve.Transaction{
+			Date:        p.Date,
+			Amount:      currency.Difference(currency.Units(0), p.Cost),
+			Description: "Capital purchase: " + p.Name,
+		})
+	}
+	return all
+}
+
+// SimulationBasis starts a simulation on the reserve ledger's date,
+// with the annualized expenses of the last year of cycles and the
+// last cycle's effective connections.  Transactions after the
+// ledger's date, including capital purchases, are planned.
+func (r *Result) SimulationBasis(l reserve.Ledger, txns []reserve.Transaction) (reserve.Basis, error) {
+	if len(r.Cycles) == 0 {
+		return reserve.Basis{}, fmt.Errorf("simulation requires a billing cycle")
+	}
+	perYear := r.Business.Schedule().PerYear()
+	last := r.Cycles[max(0, len(r.Cycles)-perYear):]
+
+	b := reserve.Basis{
+		Start:       l.AsOf,
+		PerYear:     perYear,
+		Connections: last[len(last)-1].Expenses.EffectiveConnections,
+		Balance:     l.Balance,
+		Rounding:    r.Business.Rounding,
+	}
+	for _, cs := range last {
+		b.Expenses = currency.Sum(b.Expenses, cs.Expenses.Total())
+	}
+	// A partial year of cycles is annualized.
+	b.Expenses = b.Expenses.Mul(big.NewRat(int64(perYear), int64(len(last))), b.Rounding)
+
+	for _, t := range r.withPurchases(txns) {
+		if l.AsOf.Before(t.Date) {
+			b.Planned = append(b.Planned, t)
+		}
+	}
+	return b, nil
+}
@@ -0,0 +1,198 @@
+package logic
+
+import (
+	"fmt"
+
+	"github.com/jmacd/caspar.water/cmd/internal/billing/constant"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/currency"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/expense"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/invoice"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/user"
+)
+
+// service is an additional service, billed to the accounts that list
+// it as a section of their statement.  Its cycles enter expenses
+// directly: they are not revised, projected, itemized, or metered, and
+// the weights file does not apply, so an account has weight 1, or 2
+// when Commercial.
+type service struct {
+	name   string
+	users  []user.User
+	cycles []expense.Cycle
+}
+
+// Section is an additional service on an account's statement.
+type Section struct {
+	cycle expense.Cycle
+	owes  currency.Amount
+
+	Service    string
+	TotalCost  string
+	Pay        string
+	Percent    string
+	Fraction   string
+	Margin     string
+	UserWeight int
+
+	Prorated    bool
+	ServiceDays int
+	PeriodDays  int
+}
+
+// separateServices returns the rows of the primary service, which
+// have no service or the business's, and the additional services in
+// order of appearance.  The accounts of each additional service must
+// be listed, and each account's services must have cycles.
+func separateServices(rows []expense.Cycle, primary string, users []user.User, perYear int) ([]expense.Cycle, []*service, error) {
+	var main []expense.Cycle
+	var services []*service
+	byName := map[string]*service{}
+
+	for _, row := range rows {
+		if row.Service == "" || row.Service == primary {
+			main = append(main, row)
+			continue
+		}
+		name := row.PeriodStart.Closing().Date().Format(constant.InvoiceDateLayout)
+		switch {
+		case bool(row.Revision):
+			return nil, nil, fmt.Errorf("%s cycle %s: an additional service cannot be revised", row.Service, name)
+		case row.Projection != expense.NoProjection:
+			return nil, nil, fmt.Errorf("%s cycle %s: an additional service cannot be projected", row.Service, name)
+		case bool(row.Itemized):
+			return nil, nil, fmt.Errorf("%s cycle %s: an additional service cannot be itemized", row.Service, name)
+		case row.Method == expense.MeteredMethod:
+			return nil, nil, fmt.Errorf("%s cycle %s: an additional service cannot be metered", row.Service, name)
+		}
+		svc := byName[row.Service]
+		if svc == nil {
+			svc = &service{name: row.Service}
+			byName[row.Service] = svc
+			services = append(services, svc)
+		}
+		svc.cycles = append(svc.cycles, row)
+	}
+
+	for _, u := range users {
+		for _, name := range u.Services {
+			svc := byName[name]
+			if svc == nil {
+				return nil, nil, fmt.Errorf("account %s: service %s has no cycles", u.AccountName, name)
+			}
+			svc.users = append(svc.users, u)
+		}
+	}
+	if len(services) != 0 && primary == "" {
+		return nil, nil, fmt.Errorf("business service is required to name the primary service")
+	}
+	for _, svc := range services {
+		if len(svc.users) == 0 {
+			return nil, nil, fmt.Errorf("service %s has no accounts", svc.name)
+		}
+		cycles, _, err := expense.Revisions(svc.cycles, perYear)
+		if err != nil {
+			return nil, nil, fmt.Errorf("service %s: %w", svc.name, err)
+		}
+		svc.cycles = cycles
+		for i := range svc.cycles {
+			if err := weigh(&svc.cycles[i], svc.users, nil); err != nil {
+				return nil, nil, fmt.Errorf("service %s: %w", svc.name, err)
+			}
+		}
+	}
+	return main, services, nil
+}
+
+// cycle returns the service's cycle for the primary cycle's period.
+func (svc *service) cycle(primary expense.Cycle) (expense.Cycle, bool) {
+	for _, c := range svc.cycles {
+		if c.PeriodStart.Starting().Date().Equal(primary.PeriodStart.Starting().Date()) {
+			return c, true
+		}
+	}
+	return expense.Cycle{}, false
+}
+
+// check requires a primary cycle for each of the service's periods.
+func (svc *service) check(cycles []expense.Cycle) error {
+	for _, c := range svc.cycles {
+		found := false
+		for _, p := range cycles {
+			if p.PeriodStart.Starting().Date().Equal(c.PeriodStart.Starting().Date()) {
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s cycle %s has no primary cycle", svc.name,
+				c.PeriodStart.Closing().Date().Format(constant.InvoiceDateLayout))
+		}
+	}
+	return nil
+}
+
+// serviceDivision is an additional service's division of a cycle.
+type serviceDivision struct {
+	name  string
+	cycle expense.Cycle
+	div   *division
+}
+
+// section returns the account's section of the service, if billed.
+func (sd serviceDivision) section(name string) (*Section, bool) {
+	sh, ok := sd.div.shares[name]
+	if !ok || sh.weight == 0 {
+		return nil, false
+	}
+	return &Section{
+		cycle:       sd.cycle,
+		owes:        sh.owes,
+		Service:     sd.name,
+		TotalCost:   sd.cycle.Total().Display(),
+		Pay:         sh.owes.Display(),
+		Percent:     percentText(sh.fraction),
+		Fraction:    fractionText(sh.fraction),
+		Margin:      fmt.Sprintf("%.0f%%", 100*sd.cycle.Margin),
+		UserWeight:  sh.weight,
+		Prorated:    sh.days != sh.periodDays,
+		ServiceDays: sh.days,
+		PeriodDays:  sh.periodDays,
+	}, true
+}
+
+// table is the section's table on the statement.
+func (s *Section) table(periodName string) invoice.Table {
+	v := Vars{cycle: s.cycle, PeriodName: periodName, TotalCost: s.TotalCost}
+	rows := v.expenseRows()
+	if s.Prorated {
+		rows = append(rows,
+			[]string{},
+			[]string{
+				"Service days",
+				fmt.Sprintf("%d of %d days", s.ServiceDays, s.PeriodDays),
+			},
+		)
+	}
+	rows = append(rows,
+		[]string{
+			"Share",
+			"× " + s.Fraction,
+		},
+		[]string{
+			"Margin",
+			"× " + s.Margin,
+		},
+		[]string{},
+		[]string{
+			s.Service + " charge",
+			s.Pay,
+		},
+	)
+	return invoice.Table{
+		Header: []string{
+			s.Service,
+			"Cost",
+			"",
+		},
+		Rows: rows,
+	}
+}
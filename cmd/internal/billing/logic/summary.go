@@ -0,0 +1,275 @@
+package logic
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/jmacd/caspar.water/cmd/internal/billing/account"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/capital"
+	billingcsv "github.com/jmacd/caspar.water/cmd/internal/billing/csv"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/currency"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/expense"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/invoice"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/ledger"
+	"github.com/jmacd/maroto/pkg/pdf"
+)
+
+// statementMemo is the journal memo of a cycle's charges.
+func statementMemo(name string) string {
+	return "Statement " + name
+}
+
+// Summary is the company report for one cycle.
+type Summary struct {
+	Cycle     string
+	AsOf      billingcsv.Date
+	Method    expense.Method
+	Estimated bool
+
+	Expenses currency.Amount
+	Margin   currency.Amount // Total less expenses
+	Total    currency.Amount // Expenses with margin
+
+	Billed      currency.Amount
+	Collected   currency.Amount // Payments and credits through AsOf
+	Outstanding currency.Amount
+
+	Inactive []string
+
+	// Rounding: the total is split into Connections charges
+	// of Base, prorated by days of service, RoundedUp of which
+	// are $0.01 more.  Metered cycles are not split.
+	Connections int
+	Base        currency.Amount
+	RoundedUp   int
+
+	Accounts []SummaryAccount
+
+	// Amortizations are the capital purchases active in the
+	// cycle; only expense-funded shares are in Expenses.
+	Amortizations []capital.Share
+}
+
+// SummaryAccount is one billed account in a Summary.
+type SummaryAccount struct {
+	AccountName string
+	Weight      int
+	Billed      currency.Amount
+	Rounding    currency.Amount
+	Collected   currency.Amount
+	Outstanding currency.Amount
+}
+
+// Summary reports the cycle as of a date.  Payments and credits are
+// applied to each account's oldest charges first, as in the aging
+// report, to determine what was collected for this cycle.
+func (cs *CompanyStatement) Summary(accts *account.Accounts, asOf billingcsv.Date) Summary {
+	cycle := cs.Expenses
+	div := cs.division
+
+	s := Summary{
+		Cycle:     cs.Name(),
+		AsOf:      asOf,
+		Method:    cycle.Method,
+		Estimated: cycle.BillDate.Before(cycle.PeriodStart.Closing()),
+		Expenses:  cycle.Total(),
+		Total:     div.total,
+
+		Amortizations: cs.Amortizations,
+	}
+	s.Margin = currency.Difference(s.Total, s.Expenses)
+
+	for _, name := range cycle.Inactive {
+		if name != "" {
+			s.Inactive = append(s.Inactive, name)
+		}
+	}
+
+	if div.rates == nil {
+		s.Connections = cycle.EffectiveConnections
+		s.Base = div.base
+		for _, sh := range div.shares {
+			s.RoundedUp += int(sh.rounding.Units())
+		}
+	}
+
+	memo := statementMemo(s.Cycle)
+	for _, stmt := range cs.Statements {
+		sh := div.shares[stmt.User.AccountName]
+		if sh.weight == 0 && sh.owes.IsZero() {
+			continue
+		}
+		acct := accts.Lookup(stmt.User.AccountName)
+		sa := SummaryAccount{
+			AccountName: stmt.User.AccountName,
+			Weight:      sh.weight,
+			Billed:      sh.owes,
+			Collected: collected(acct, asOf, func(e ledger.Entry) bool {
+				return e.Kind == ledger.Charge && e.Memo == memo
+			}),
+		}
+		if div.rates == nil {
+			sa.Rounding = sh.rounding
+		}
+		sa.Outstanding = currency.Difference(sa.Billed, sa.Collected)
+
+		s.Accounts = append(s.Accounts, sa)
+		s.Billed = currency.Sum(s.Billed, sa.Billed)
+		s.Collected = currency.Sum(s.Collected, sa.Collected)
+	}
+	s.Outstanding = currency.Difference(s.Billed, s.Collected)
+	return s
+}
+
+// collected returns the part of the target charge paid through a
+// date, applying the account's credits to its oldest debits first.
+func collected(acct *account.Account, asOf billingcsv.Date, target func(ledger.Entry) bool) currency.Amount {
+	var credit currency.Amount
+	var debits []ledger.Entry
+	for _, e := range acct.Entries() {
+		if e.Date.Date().After(asOf.Date()) {
+			continue
+		}
+		if n := acct.Net(e); n.Units() < 0 {
+			credit = currency.Difference(credit, n)
+		} else if n.Units() > 0 {
+			debits = append(debits, e)
+		}
+	}
+	for _, e := range debits {
+		applied := acct.Net(e)
+		if credit.Units() < applied.Units() {
+			applied = credit
+		}
+		credit = currency.Difference(credit, applied)
+		if target(e) {
+			return applied
+		}
+	}
+	return currency.Units(0)
+}
+
+func (s Summary) items() [][]string {
+	method := string(s.Method)
+	if s.Estimated {
+		method += ", estimated"
+	}
+	rows := [][]string{
+		{"Method", method},
+		{"Expenses", s.Expenses.Display()},
+	}
+	if capex := capital.Expense(s.Amortizations); !capex.IsZero() {
+		rows = append(rows, []string{"Capital in expenses", capex.Display()})
+	}
+	rows = append(rows, [][]string{
+		{"Margin", s.Margin.Display()},
+		{"Total", s.Total.Display()},
+		{"Billed", s.Billed.Display()},
+	}...)
+	rows = append(rows,
+		[]string{"Collected", s.Collected.Display()},
+		[]string{"Outstanding", s.Outstanding.Display()},
+		[]string{"Inactive accounts", strings.Join(s.Inactive, ", ")},
+	)
+	if s.Connections != 0 {
+		rows = append(rows,
+			[]string{"Effective connections", fmt.Sprint(s.Connections)},
+			[]string{"Base charge", s.Base.Display()},
+			[]string{"Charges rounded up $0.01", fmt.Sprint(s.RoundedUp)},
+		)
+	}
+	return rows
+}
+
+func summaryHeader() []string {
+	return []string{"Account Name", "Weight", "Billed", "Rounding", "Collected", "Outstanding"}
+}
+
+func (s Summary) accountRows() [][]string {
+	var rows [][]string
+	for _, a := range s.Accounts {
+		rows = append(rows, []string{
+			a.AccountName,
+			fmt.Sprint(a.Weight),
+			a.Billed.Display(),
+			a.Rounding.Display(),
+			a.Collected.Display(),
+			a.Outstanding.Display(),
+		})
+	}
+	return rows
+}
+
+func (s Summary) totalRow() []string {
+	var weight int
+	var rounding currency.Amount
+	for _, a := range s.Accounts {
+		weight += a.Weight
+		rounding = currency.Sum(rounding, a.Rounding)
+	}
+	return []string{
+		"Total",
+		fmt.Sprint(weight),
+		s.Billed.Display(),
+		rounding.Display(),
+		s.Collected.Display(),
+		s.Outstanding.Display(),
+	}
+}
+
+func amortizationHeader() []string {
+	return []string{"Capital Purchase", "Funding", "Cycle", "Cost", "Share", "Remaining"}
+}
+
+func (s Summary) amortizationRows() [][]string {
+	var rows [][]string
+	for _, a := range s.Amortizations {
+		rows = append(rows, []string{
+			a.Name,
+			string(a.Funding),
+			fmt.Sprintf("%d of %d", a.Cycle, a.Cycles),
+			a.Cost.Display(),
+			a.Amount.Display(),
+			a.Remaining.Display(),
+		})
+	}
+	return rows
+}
+
+// WriteCSV writes the summary items, a blank line, and the
+// per-account table with a total, followed by the active capital
+// purchases, if any.
+func (s Summary) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"Item", "Value"})
+	_ = cw.WriteAll(s.items())
+	_ = cw.Write(nil)
+	_ = cw.Write(summaryHeader())
+	_ = cw.WriteAll(s.accountRows())
+	_ = cw.Write(s.totalRow())
+	if len(s.Amortizations) != 0 {
+		_ = cw.Write(nil)
+		_ = cw.Write(amortizationHeader())
+		_ = cw.WriteAll(s.amortizationRows())
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// MainContent renders the summary, per-account, and capital purchase
+// tables.
+func (s Summary) MainContent(m pdf.Maroto) {
+	m.Row(2, func() {
+		m.TableList([]string{"Cycle " + s.Cycle, ""}, s.items(), invoice.TableStyle)
+	})
+	m.Row(2, func() {
+		m.TableList(summaryHeader(), append(s.accountRows(), []string{}, s.totalRow()), invoice.TableStyle)
+	})
+	if len(s.Amortizations) != 0 {
+		m.Row(2, func() {
+			m.TableList(amortizationHeader(), s.amortizationRows(), invoice.TableStyle)
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package logic
+
+import (
+	"fmt"
+
+	"github.com/jmacd/caspar.water/cmd/internal/billing/account"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/constant"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/csv"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/currency"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/expense"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/ledger"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/meter"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/rate"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/user"
+)
+
+// TrueUp is the difference between an account's estimated charge for
+// a cycle and the charge for its actual expenses.
+type TrueUp struct {
+	Period    string // Closing month of the estimated cycle
+	Estimated string // Charge on the estimated statement
+	Actual    string // Charge for the actual expenses
+	Amount    string // Actual less estimated
+
+	amount currency.Amount
+}
+
+// trueUps tracks the revisions of estimated cycles.  A true-up is
+// posted on the first statement issued on or after the revision's
+// bill date.
+type trueUps struct {
+	revisions []expense.Revision
+	billed    []map[string]share
+	applied   []bool
+	rounding  currency.Rounding
+}
+
+// due returns the true-ups by account name for the statements of
+// cycle number cycleNo issued on the bill date.
+func (t *trueUps) due(cycleNo int, billDate csv.Date, users []user.User, readings *meter.Readings, blocks []rate.Block) (map[string][]TrueUp, error) {
+	if t.applied == nil {
+		t.applied = make([]bool, len(t.revisions))
+	}
+	result := map[string][]TrueUp{}
+
+	for i, rev := range t.revisions {
+		if t.applied[i] || rev.Original >= cycleNo || billDate.Before(rev.Actual.BillDate) {
+			continue
+		}
+		t.applied[i] = true
+
+		actual, err := computeShares(rev.Actual, users, readings, blocks, t.rounding)
+		if err != nil {
+			return nil, fmt.Errorf("revision: %w", err)
+		}
+		name := rev.Actual.PeriodStart.Closing().Date().Format(constant.InvoiceDateLayout)
+
+		for _, user := range users {
+			est, ok := t.billed[rev.Original][user.AccountName]
+			if !ok {
+				continue
+			}
+			act := actual.shares[user.AccountName].owes
+			diff := currency.Difference(act, est.owes)
+			if diff.IsZero() {
+				continue
+			}
+			result[user.AccountName] = append(result[user.AccountName], TrueUp{
+				Period:    name,
+				Estimated: est.owes.Display(),
+				Actual:    act.Display(),
+				Amount:    diff.Display(),
+				amount:    diff,
+			})
+		}
+	}
+	return result, nil
+}
+
+// pending lists the cycles of revisions still waiting for a
+// statement.
+func (t *trueUps) pending() []string {
+	var names []string
+	for i, rev := range t.revisions {
+		if i < len(t.applied) && t.applied[i] {
+			continue
+		}
+		names = append(names, rev.Actual.PeriodStart.Closing().Date().Format(constant.InvoiceDateLayout))
+	}
+	return names
+}
+
+// postTrueUps enters the account's true-ups and returns their sum.
+func postTrueUps(acct *account.Account, billDate csv.Date, tus []TrueUp) (currency.Amount, error) {
+	total := currency.Units(0)
+	for _, tu := range tus {
+		memo := "True-up of the " + tu.Period + " estimate"
+		if err := acct.Enter(billDate, ledger.TrueUp, tu.amount, memo); err != nil {
+			return total, err
+		}
+		total = currency.Sum(total, tu.amount)
+	}
+	return total, nil
+}
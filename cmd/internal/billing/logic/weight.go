@@ -0,0 +1,47 @@
+package logic
+
+import (
+	"fmt"
+
+	"github.com/jmacd/caspar.water/cmd/internal/billing/constant"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/expense"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/user"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/weight"
+)
+
+// weigh sets the weights of the accounts billed for the cycle and
+// derives its effective connections.  An account without a weight in
+// the schedule has weight 1, or 2 when Commercial except in an
+// Introductory cycle.  Inactive accounts have weight 0.
+func weigh(cycle *expense.Cycle, users []user.User, sched *weight.Schedule) error {
+	cycle.Weights = map[string]int{}
+
+	count := 0
+	for _, u := range users {
+		if !u.Serves(cycle.PeriodStart) {
+			continue
+		}
+		w, ok := sched.Weight(u.AccountName, cycle.PeriodStart)
+		switch {
+		case cycle.Inactive.Contains(u):
+			w = 0
+		case ok:
+		case bool(u.Commercial) && cycle.Method != expense.IntroductoryMethod:
+			w = 2
+		default:
+			w = 1
+		}
+		cycle.Weights[u.AccountName] = w
+		count += w
+	}
+	if count == 0 {
+		return fmt.Errorf("cycle %s has no connections",
+			cycle.PeriodStart.Closing().Date().Format(constant.InvoiceDateLayout))
+	}
+	if cycle.EffectiveConnections != 0 && cycle.EffectiveConnections != count {
+		return fmt.Errorf("cycle %s: effective connections %d do not match the account weights %d, enter zero to derive",
+			cycle.PeriodStart.Closing().Date().Format(constant.InvoiceDateLayout), cycle.EffectiveConnections, count)
+	}
+	cycle.EffectiveConnections = count
+	return nil
+}
@@ -0,0 +1,120 @@
+package meter
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/jmacd/caspar.water/cmd/internal/billing/constant"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/csv"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/period"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// Reading records the cumulative register of one account's meter on
+// a given date.
+type Reading struct {
+	Date        csv.Date
+	AccountName string
+
+	// Gallons is the cumulative meter register.
+	Gallons Gallons
+}
+
+// Gallons is a volume of water.
+type Gallons uint64
+
+var printer = message.NewPrinter(language.English)
+
+func (g Gallons) Display() string {
+	return printer.Sprintf("%d gal", uint64(g))
+}
+
+func (r Reading) Validate() error {
+	if err := r.Date.Validate(); err != nil {
+		return err
+	}
+	if r.AccountName == "" {
+		return fmt.Errorf("empty meter reading account name")
+	}
+	return nil
+}
+
+// Readings indexes meter readings by account, in date order.
+type Readings struct {
+	byAccount map[string][]Reading
+}
+
+func NewReadings(readings []Reading) (*Readings, error) {
+	r := &Readings{
+		byAccount: map[string][]Reading{},
+	}
+	for _, read := range readings {
+		r.byAccount[read.AccountName] = append(r.byAccount[read.AccountName], read)
+	}
+	for name, list := range r.byAccount {
+		sort.SliceStable(list, func(i, j int) bool {
+			return list[i].Date.Before(list[j].Date)
+		})
+		for i := 1; i < len(list); i++ {
+			if list[i].Gallons < list[i-1].Gallons {
+				return nil, fmt.Errorf("meter reading decreased: %s on %s",
+					name, list[i].Date.Date().Format(constant.CsvLayout))
+			}
+		}
+	}
+	return r, nil
+}
+
+// Usage is the opening and closing readings of an account's meter
+// for a period.
+type Usage struct {
+	Open  Reading
+	Close Reading
+}
+
+// Gallons is the volume used between the readings.
+func (u Usage) Gallons() Gallons {
+	return u.Close.Gallons - u.Open.Gallons
+}
+
+// Consumption returns the gallons used by an account during a period.
+func (r *Readings) Consumption(account string, p period.Period) (Gallons, error) {
+	u, err := r.Usage(account, p)
+	if err != nil {
+		return 0, err
+	}
+	return u.Gallons(), nil
+}
+
+// Usage returns the readings of an account's meter for a period.
+// The opening reading is the latest on or before the period start
+// (or the first one inside the period, for a newly installed meter);
+// the closing reading is the latest on or before the period close.
+func (r *Readings) Usage(account string, p period.Period) (Usage, error) {
+	list := r.byAccount[account]
+
+	start := p.Starting().Date()
+	close := p.Closing().Date()
+
+	open := -1
+	last := -1
+	for i, read := range list {
+		date := read.Date.Date()
+		if date.After(close) {
+			break
+		}
+		if !date.After(start) || open < 0 {
+			open = i
+		}
+		last = i
+	}
+	if open < 0 || last == open {
+		return Usage{}, fmt.Errorf("not enough meter readings for %s: %s..%s",
+			account, start.Format(constant.CsvLayout), close.Format(constant.CsvLayout))
+	}
+	return Usage{
+		Open:  list[open],
+		Close: list[last],
+	}, nil
+}
@@ -0,0 +1,140 @@
+package notice
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/jmacd/caspar.water/cmd/internal/billing/constant"
+	billingcsv "github.com/jmacd/caspar.water/cmd/internal/billing/csv"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/currency"
+	"github.com/spf13/afero"
+)
+
+// Record is an issued notice.
+type Record struct {
+	AccountName string
+	Stage       string
+	IssueDate   billingcsv.Date
+
+	// Overdue and Balance are the amounts on the notice.
+	Overdue currency.Amount
+	Balance currency.Amount
+}
+
+func (r Record) Validate() error {
+	if r.AccountName == "" || r.Stage == "" {
+		return fmt.Errorf("issued notice requires an account and stage")
+	}
+	return r.IssueDate.Validate()
+}
+
+func (r Record) key() string {
+	return r.AccountName + " " + r.Stage + " " + r.IssueDate.Date().Format(constant.CsvLayout)
+}
+
+// Log is the notice history, the notices issued to each account.
+type Log struct {
+	records []Record
+	keys    map[string]bool
+}
+
+func NewLog() *Log {
+	return &Log{
+		keys: map[string]bool{},
+	}
+}
+
+// ReadLog reads a notice log; a missing file is an empty log.
+func ReadLog(name string, fs afero.Fs) (*Log, error) {
+	l := NewLog()
+	if _, err := fs.Stat(name); errors.Is(err, os.ErrNotExist) {
+		return l, nil
+	}
+	recs, err := billingcsv.ReadFile[Record](name, fs)
+	if err != nil {
+		return nil, err
+	}
+	for _, rec := range recs {
+		if err := l.Add(rec); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return l, nil
+}
+
+// Add records an issued notice.  A stage is issued to an account
+// once per day.
+func (l *Log) Add(rec Record) error {
+	if l.keys[rec.key()] {
+		return fmt.Errorf("notice %s was already issued", rec.key())
+	}
+	l.keys[rec.key()] = true
+	l.records = append(l.records, rec)
+	byDate(l.records)
+	return nil
+}
+
+// Records returns the issued notices by date.
+func (l *Log) Records() []Record {
+	return l.records
+}
+
+// Account returns the notices issued to an account by date.
+func (l *Log) Account(accountName string) []Record {
+	var recs []Record
+	for _, rec := range l.records {
+		if rec.AccountName == accountName {
+			recs = append(recs, rec)
+		}
+	}
+	return recs
+}
+
+// byDate orders records by issue date, then account and stage.
+func byDate(recs []Record) {
+	sort.SliceStable(recs, func(i, j int) bool {
+		if !recs[i].IssueDate.Date().Equal(recs[j].IssueDate.Date()) {
+			return recs[i].IssueDate.Before(recs[j].IssueDate)
+		}
+		if recs[i].AccountName != recs[j].AccountName {
+			return recs[i].AccountName < recs[j].AccountName
+		}
+		return recs[i].Stage < recs[j].Stage
+	})
+}
+
+var header = []string{
+	"Account Name",
+	"Stage",
+	"Issue Date",
+	"Overdue",
+	"Balance",
+}
+
+// Write replaces the notice log.
+func (l *Log) Write(name string) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	cw := csv.NewWriter(f)
+	_ = cw.Write(header)
+	for _, rec := range l.records {
+		_ = cw.Write([]string{
+			rec.AccountName,
+			rec.Stage,
+			rec.IssueDate.Date().Format(constant.CsvLayout),
+			rec.Overdue.Display(),
+			rec.Balance.Display(),
+		})
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
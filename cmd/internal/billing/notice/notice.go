@@ -0,0 +1,259 @@
+package notice
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/jmacd/caspar.water/cmd/internal/billing/account"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/aging"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/constant"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/csv"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/currency"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/invoice"
+	"github.com/jmacd/maroto/pkg/pdf"
+	"github.com/spf13/afero"
+)
+
+// Stage is one step of the delinquency notices, e.g., a reminder, a
+// final notice, and a shutoff warning.  The stages file lists them
+// in order.
+type Stage struct {
+	// Stage names the notice, e.g., "Reminder".
+	Stage string
+
+	// Days is the age, in days since the charge was posted, of
+	// unpaid charges that are delinquent at this stage.
+	Days int
+
+	// MinimumBalance is the smallest delinquent balance that
+	// receives the notice.
+	MinimumBalance currency.Amount `csv:",optional"`
+
+	// PayWithin is the number of days after the notice to pay,
+	// printed as its pay-by date, zero for none.
+	PayWithin int `csv:",optional"`
+
+	// Template is the notice's body text, a text/template of
+	// the Notice.
+	Template string
+
+	tmpl *template.Template
+}
+
+func (s Stage) Validate() error {
+	if s.Stage == "" {
+		return fmt.Errorf("notice stage has no name")
+	}
+	if s.Days < 0 || s.PayWithin < 0 {
+		return fmt.Errorf("notice stage %s days cannot be negative", s.Stage)
+	}
+	if s.MinimumBalance.Units() < 0 {
+		return fmt.Errorf("notice stage %s minimum balance cannot be negative", s.Stage)
+	}
+	if s.Template == "" {
+		return fmt.Errorf("notice stage %s has no template", s.Stage)
+	}
+	return nil
+}
+
+// ReadStages reads the stages file and their templates, which are
+// named relative to dir unless absolute.  The stages are listed in
+// order of increasing Days.
+func ReadStages(name, dir string, fs afero.Fs) ([]Stage, error) {
+	stages, err := csv.ReadFile[Stage](name, fs)
+	if err != nil {
+		return nil, err
+	}
+	for i := range stages {
+		s := &stages[i]
+		if i != 0 && s.Days <= stages[i-1].Days {
+			return nil, fmt.Errorf("%s: stage %s should follow %s by more days", name, s.Stage, stages[i-1].Stage)
+		}
+		for _, prev := range stages[:i] {
+			if strings.EqualFold(prev.Stage, s.Stage) {
+				return nil, fmt.Errorf("%s: duplicate stage %s", name, s.Stage)
+			}
+		}
+		tmplPath := s.Template
+		if !filepath.IsAbs(tmplPath) {
+			tmplPath = filepath.Join(dir, tmplPath)
+		}
+		data, err := afero.ReadFile(fs, tmplPath)
+		if err != nil {
+			return nil, fmt.Errorf("%s: stage %s: %w", name, s.Stage, err)
+		}
+		if s.tmpl, err = template.New(filepath.Base(s.Template)).Parse(string(data)); err != nil {
+			return nil, fmt.Errorf("%s: stage %s: %w", name, s.Stage, err)
+		}
+	}
+	return stages, nil
+}
+
+// Notice is a delinquency notice issued to an account.  Its fields
+// are the variables of the stage's template.
+type Notice struct {
+	Stage          string
+	AccountName    string
+	UserName       string
+	ServiceAddress string
+	IssueDate      string
+	PayBy          string // Empty without a PayWithin
+
+	// Days is the stage's age of delinquency, Overdue the
+	// balance of charges older than Days, and Balance the total
+	// owed.
+	Days    int
+	Overdue string
+	Balance string
+
+	stage   *Stage
+	issued  csv.Date
+	overdue currency.Amount
+	balance currency.Amount
+	aging   aging.Row
+}
+
+var _ invoice.Document = &Notice{}
+
+func (n *Notice) FullDate() string {
+	return n.IssueDate
+}
+
+func (n *Notice) InvoiceName() string {
+	return "Notice-" + strings.ReplaceAll(n.Stage, " ", "") + "-" + n.issued.Date().Format(time.DateOnly)
+}
+
+func (n *Notice) BodyText() (string, error) {
+	var buf bytes.Buffer
+	if err := n.stage.tmpl.Execute(&buf, n); err != nil {
+		return "", fmt.Errorf("notice %s: %w", n.Stage, err)
+	}
+	return buf.String(), nil
+}
+
+// Record is the notice's entry in the notice log.
+func (n *Notice) Record() Record {
+	return Record{
+		AccountName: n.AccountName,
+		Stage:       n.Stage,
+		IssueDate:   n.issued,
+		Overdue:     n.overdue,
+		Balance:     n.balance,
+	}
+}
+
+// MainContent renders the account's unpaid balance by age.
+func (n *Notice) MainContent(m pdf.Maroto) {
+	var rows [][]string
+	for i, label := range aging.Labels {
+		rows = append(rows, []string{
+			"Unpaid " + label + " days",
+			n.aging.Buckets[i].Display(),
+		})
+	}
+	rows = append(rows,
+		[]string{},
+		[]string{
+			fmt.Sprintf("Unpaid over %d days", n.Days),
+			n.Overdue,
+		},
+		[]string{
+			"Balance",
+			n.Balance,
+		},
+	)
+	if n.PayBy != "" {
+		rows = append(rows, []string{
+			"Pay by",
+			n.PayBy,
+		})
+	}
+	m.Row(2, func() {
+		m.TableList([]string{
+			n.Stage,
+			"Amount",
+			"",
+		}, rows, invoice.TableStyle)
+	})
+}
+
+// Due returns the notices to issue on a date, at most one per
+// account.  An account's delinquency begins with its oldest unpaid
+// charge, and it receives each stage once per delinquency, in order:
+// a stage is due when the previous stage was issued on an earlier
+// date and the account's charges older than the stage's days sum to
+// at least its minimum balance.
+func Due(accts *account.Accounts, stages []Stage, log *Log, asOf csv.Date) []*Notice {
+	var due []*Notice
+	for _, name := range accts.Names() {
+		acct := accts.Lookup(name)
+		charges, _ := aging.Unpaid(acct, asOf)
+		if len(charges) == 0 {
+			continue
+		}
+		begins := charges[0].Date
+
+		// issued is the date each stage was issued during
+		// this delinquency.
+		issued := map[string]csv.Date{}
+		for _, rec := range log.Account(name) {
+			if !rec.IssueDate.Before(begins) && !asOf.Before(rec.IssueDate) {
+				issued[strings.ToLower(rec.Stage)] = rec.IssueDate
+			}
+		}
+
+		for i := range stages {
+			s := &stages[i]
+			if _, ok := issued[strings.ToLower(s.Stage)]; ok {
+				continue
+			}
+			if i != 0 {
+				prev, ok := issued[strings.ToLower(stages[i-1].Stage)]
+				if !ok || !prev.Before(asOf) {
+					break
+				}
+			}
+			var overdue currency.Amount
+			for _, c := range charges {
+				if c.Days(asOf) > s.Days {
+					overdue = currency.Sum(overdue, c.Amount)
+				}
+			}
+			if overdue.Units() <= 0 || overdue.Units() < s.MinimumBalance.Units() {
+				break
+			}
+			due = append(due, newNotice(acct, s, asOf, overdue))
+			break
+		}
+	}
+	return due
+}
+
+func newNotice(acct *account.Account, s *Stage, asOf csv.Date, overdue currency.Amount) *Notice {
+	u := acct.User()
+	balance := acct.Balance(asOf)
+	n := &Notice{
+		Stage:          s.Stage,
+		AccountName:    u.AccountName,
+		UserName:       u.UserName,
+		ServiceAddress: u.ServiceAddress.OneLine(),
+		IssueDate:      asOf.Date().Format(constant.FullDateLayout),
+		Days:           s.Days,
+		Overdue:        overdue.Display(),
+		Balance:        balance.Display(),
+
+		stage:   s,
+		issued:  asOf,
+		overdue: overdue,
+		balance: balance,
+		aging:   aging.Age(acct, asOf),
+	}
+	if s.PayWithin != 0 {
+		n.PayBy = asOf.Date().AddDate(0, 0, s.PayWithin).Format(constant.FullDateLayout)
+	}
+	return n
+}
@@ -0,0 +1,142 @@
+package notice
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jmacd/caspar.water/cmd/internal/billing"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/account"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/csv"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/currency"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/ledger"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/user"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func stagesFs(t *testing.T, stages string) afero.Fs {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "stages.csv", []byte(stages), 0644))
+	for _, name := range []string{"reminder.txt", "final.txt", "shutoff.txt"} {
+		require.NoError(t, afero.WriteFile(fs, name, []byte(
+			name+": {{.UserName}} owes {{.Overdue}} over {{.Days}} days{{if .PayBy}}, pay by {{.PayBy}}{{end}}\n"), 0644))
+	}
+	return fs
+}
+
+const stagesCSV = `Stage,Days,Minimum Balance,Pay Within,Template
+Reminder,30,$10.00,14,reminder.txt
+Final Notice,60,,10,final.txt
+Shutoff Warning,90,,,shutoff.txt
+`
+
+func TestNotices(t *testing.T) {
+	stages, err := ReadStages("stages.csv", "", stagesFs(t, stagesCSV))
+	require.NoError(t, err)
+	require.Equal(t, 3, len(stages))
+
+	accts := account.NewAccounts()
+	for _, name := range []string{"A", "B", "C"} {
+		accts.Register(user.User{AccountName: name, UserName: "User " + name})
+	}
+	date := func(s string) csv.Date {
+		return internal.Must(csv.ParseDate(s))
+	}
+	enter := func(name, on string, kind ledger.Kind, units int64) {
+		require.NoError(t, accts.Lookup(name).Enter(date(on), kind, currency.Units(units), ""))
+	}
+	enter("A", "3/31/2023", ledger.Charge, 30000)
+	enter("A", "4/15/2023", ledger.Payment, 10000)
+	enter("B", "3/31/2023", ledger.Charge, 500)
+	enter("C", "3/31/2023", ledger.Charge, 30000)
+	enter("C", "4/15/2023", ledger.Payment, 30000)
+
+	log := NewLog()
+	issue := func(on string) []*Notice {
+		due := Due(accts, stages, log, date(on))
+		for _, n := range due {
+			require.NoError(t, log.Add(n.Record()))
+		}
+		return due
+	}
+
+	// B owes less than the minimum, C is paid.
+	due := issue("5/5/2023")
+	require.Equal(t, 1, len(due))
+	require.Equal(t, "A", due[0].AccountName)
+	require.Equal(t, "Reminder", due[0].Stage)
+	require.Equal(t, "$200.00", due[0].Overdue)
+	require.Equal(t, "May 19, 2023", due[0].PayBy)
+	require.Equal(t, "Notice-Reminder-2023-05-05", due[0].InvoiceName())
+	text, err := due[0].BodyText()
+	require.NoError(t, err)
+	require.Equal(t, "reminder.txt: User A owes $200.00 over 30 days, pay by May 19, 2023\n", text)
+
+	// One stage per day, then the next stages in order.
+	require.Empty(t, issue("5/5/2023"))
+	require.Empty(t, issue("5/20/2023"))
+	due = issue("6/5/2023")
+	require.Equal(t, 1, len(due))
+	require.Equal(t, "Final Notice", due[0].Stage)
+	due = issue("7/5/2023")
+	require.Equal(t, 1, len(due))
+	require.Equal(t, "Shutoff Warning", due[0].Stage)
+	require.Equal(t, "", due[0].PayBy)
+	require.Empty(t, issue("8/5/2023"))
+
+	// A new delinquency starts again with a reminder.
+	enter("A", "8/10/2023", ledger.Payment, 20000)
+	enter("A", "9/30/2023", ledger.Charge, 30000)
+	require.Empty(t, issue("10/15/2023"))
+	due = issue("11/5/2023")
+	require.Equal(t, 1, len(due))
+	require.Equal(t, "Reminder", due[0].Stage)
+
+	require.Equal(t, 4, len(log.Account("A")))
+	require.Error(t, log.Add(due[0].Record()))
+
+	name := filepath.Join(t.TempDir(), "notice-log.csv")
+	require.NoError(t, log.Write(name))
+	data, err := os.ReadFile(name)
+	require.NoError(t, err)
+	require.Contains(t, string(data), "Account Name,Stage,Issue Date,Overdue,Balance\nA,Reminder,5/5/2023,$200.00,$200.00\n")
+
+	read, err := ReadLog(name, afero.NewOsFs())
+	require.NoError(t, err)
+	require.Equal(t, log.Records(), read.Records())
+}
+
+func TestStagesInvalid(t *testing.T) {
+	_, err := ReadStages("stages.csv", "", stagesFs(t, `Stage,Days,Template
+Reminder,30,reminder.txt
+Final Notice,30,final.txt
+`))
+	require.EqualError(t, err, "stages.csv: stage Final Notice should follow Reminder by more days")
+
+	_, err = ReadStages("stages.csv", "", stagesFs(t, `Stage,Days,Template
+Reminder,30,missing.txt
+`))
+	require.ErrorContains(t, err, "stages.csv: stage Reminder: ")
+
+	_, err = ReadStages("stages.csv", "", stagesFs(t, `Stage,Days,Template
+Reminder,-1,reminder.txt
+`))
+	require.Error(t, err)
+}
+
+func TestStagesDir(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "co/stages.csv", []byte(`Stage,Days,Template
+Reminder,30,reminder.txt
+`), 0644))
+	require.NoError(t, afero.WriteFile(fs, "co/reminder.txt", []byte("{{.UserName}}\n"), 0644))
+
+	// Templates are named relative to the directory.
+	stages, err := ReadStages("co/stages.csv", "co", fs)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(stages))
+
+	_, err = ReadStages("co/stages.csv", "", fs)
+	require.ErrorContains(t, err, "co/stages.csv: stage Reminder: ")
+}
@@ -0,0 +1,167 @@
+package payer
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/mail"
+	"strings"
+
+	"github.com/jmacd/caspar.water/cmd/internal/billing/address"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/bool"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/currency"
+)
+
+// Payer is a responsible party for several user accounts.  Payments
+// naming the payer are allocated across its accounts.
+type Payer struct {
+	// PayerName identifies the payer in the payments file, it
+	// must be distinct from every account name.
+	PayerName string
+
+	// UserName is the payer's name on a consolidated statement.
+	UserName string
+
+	// BillingAddress is where the payer receives mail.
+	BillingAddress address.Address
+
+	// Accounts is a comma/whitespace separated list of the
+	// payer's account names.
+	Accounts Accounts
+
+	// Allocation is the rule for dividing a payment, values
+	// include:
+	// - Proportional: in proportion to each account's balance
+	//   owed on the payment date (the default).
+	// - Sequential: pay each account's balance in the order
+	//   listed, the excess goes to the first account.
+	// - Even: split equally among the accounts.
+	Allocation Allocation `csv:",default=Proportional"`
+
+	// Consolidate indicates one statement listing every
+	// account, instead of one statement per account.
+	Consolidate bool.Bool `csv:",optional"`
+
+	// Email is an optional address for the consolidated
+	// statement.
+	Email string `csv:",optional"`
+}
+
+type Allocation string
+
+const (
+	ProportionalAllocation Allocation = "Proportional"
+	SequentialAllocation   Allocation = "Sequential"
+	EvenAllocation         Allocation = "Even"
+)
+
+func (a *Allocation) UnmarshalJSON(data []byte) error {
+	var s string
+	err := json.Unmarshal(data, &s)
+	if err != nil {
+		return err
+	}
+	str := strings.ToLower(s)
+	switch str {
+	case "", "proportional":
+		*a = ProportionalAllocation
+	case "sequential":
+		*a = SequentialAllocation
+	case "even":
+		*a = EvenAllocation
+	default:
+		return fmt.Errorf("invalid allocation: %q", str)
+	}
+	return nil
+}
+
+type Accounts []string
+
+func (in *Accounts) UnmarshalJSON(data []byte) error {
+	var s string
+	err := json.Unmarshal(data, &s)
+	if err != nil {
+		return err
+	}
+	*in = nil
+	for _, name := range strings.Split(strings.ReplaceAll(s, " ", ""), ",") {
+		if name != "" {
+			*in = append(*in, name)
+		}
+	}
+	return nil
+}
+
+func (p Payer) Validate() error {
+	if p.PayerName == "" {
+		return fmt.Errorf("empty payer name")
+	}
+	if p.UserName == "" {
+		return fmt.Errorf("empty payer user name")
+	}
+	if p.BillingAddress == "" {
+		return fmt.Errorf("empty payer billing address")
+	}
+	if len(p.Accounts) < 2 {
+		return fmt.Errorf("payer %s should have several accounts", p.PayerName)
+	}
+	seen := map[string]struct{}{}
+	for _, name := range p.Accounts {
+		if _, ok := seen[name]; ok {
+			return fmt.Errorf("payer %s lists account %s twice", p.PayerName, name)
+		}
+		seen[name] = struct{}{}
+	}
+	if p.Email != "" {
+		if _, err := mail.ParseAddress(p.Email); err != nil {
+			return fmt.Errorf("payer %s email: %w", p.PayerName, err)
+		}
+	}
+	return nil
+}
+
+// Allocate divides a payment among the payer's accounts, given the
+// balance owed by each account on the payment date.  Even and
+// proportional payments are divided exactly, see currency.Allocate,
+// the leftover cents going to the largest remainders.
+func (p Payer) Allocate(amount currency.Amount, owed []currency.Amount) []currency.Amount {
+	even := make([]int64, len(p.Accounts))
+	for i := range even {
+		even[i] = 1
+	}
+
+	switch p.Allocation {
+	case SequentialAllocation:
+		out := make([]currency.Amount, len(owed))
+		remain := amount
+		for i, o := range owed {
+			if o.Units() <= 0 || remain.Units() <= 0 {
+				continue
+			}
+			pay := o
+			if remain.Units() < o.Units() {
+				pay = remain
+			}
+			out[i] = pay
+			remain = currency.Difference(remain, pay)
+		}
+		out[0] = currency.Sum(out[0], remain)
+		return out
+
+	case EvenAllocation:
+		return currency.Allocate(amount, even, nil).Parts
+
+	default:
+		weights := make([]int64, len(owed))
+		var total int64
+		for i, o := range owed {
+			if o.Units() > 0 {
+				weights[i] = o.Units()
+				total += weights[i]
+			}
+		}
+		if total == 0 {
+			weights = even
+		}
+		return currency.Allocate(amount, weights, nil).Parts
+	}
+}
@@ -0,0 +1,73 @@
+package payer
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/jmacd/caspar.water/cmd/internal/billing/csv"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/currency"
+	"github.com/stretchr/testify/require"
+)
+
+const header = "Payer Name,User Name,Billing Address,Accounts,Allocation,Consolidate"
+
+func TestPayerAllocate(t *testing.T) {
+	data := header + `
+Owner1,Owner One,1 P.O. Box,"A1, A2, A3",,TRUE
+Owner2,Owner Two,2 P.O. Box,"B1,B2,B3",Sequential,FALSE
+Owner3,Owner Three,3 P.O. Box,"C1,C2,C3",even,FALSE
+`
+	payers, err := csv.Read[Payer]("<input>", bytes.NewBufferString(data))
+	require.NoError(t, err)
+	require.Equal(t, 3, len(payers))
+	require.Equal(t, Accounts{"A1", "A2", "A3"}, payers[0].Accounts)
+	require.Equal(t, ProportionalAllocation, payers[0].Allocation)
+	require.True(t, bool(payers[0].Consolidate))
+
+	units := func(us ...int64) []currency.Amount {
+		var r []currency.Amount
+		for _, u := range us {
+			r = append(r, currency.Units(u))
+		}
+		return r
+	}
+
+	for _, test := range []struct {
+		payer  Payer
+		amount int64
+		owed   []currency.Amount
+		expect []currency.Amount
+	}{
+		// Proportional to the balance owed.
+		{payers[0], 600, units(100, 200, 300), units(100, 200, 300)},
+		{payers[0], 300, units(100, 0, 200), units(100, 0, 200)},
+		// Exact shares, the leftover cent to the largest
+		// remainder.
+		{payers[0], 100, units(1, 2, 4), units(14, 29, 57)},
+		// Credit balances are ignored.
+		{payers[0], 300, units(-100, 100, 200), units(0, 100, 200)},
+		// Nothing owed, split evenly.
+		{payers[0], 100, units(0, 0, 0), units(34, 33, 33)},
+		// Sequential, excess to the first.
+		{payers[1], 250, units(100, 100, 100), units(100, 100, 50)},
+		{payers[1], 400, units(100, 100, 100), units(200, 100, 100)},
+		{payers[1], 150, units(0, 100, 100), units(0, 100, 50)},
+		// Even.
+		{payers[2], 300, units(100, 0, 500), units(100, 100, 100)},
+	} {
+		require.Equal(t, test.expect, test.payer.Allocate(currency.Units(test.amount), test.owed))
+	}
+}
+
+func TestPayerInvalid(t *testing.T) {
+	for _, test := range []string{
+		`,Owner,1 P.O. Box,"A1,A2",,FALSE`,
+		`Owner,Owner,1 P.O. Box,"A1",,FALSE`,
+		`Owner,Owner,1 P.O. Box,"A1,A1",,FALSE`,
+		`Owner,Owner,1 P.O. Box,"A1,A2",Random,FALSE`,
+	} {
+		data := header + "\n" + test
+		_, err := csv.Read[Payer]("<input>", bytes.NewBufferString(data))
+		require.Error(t, err, "for %s", test)
+	}
+}
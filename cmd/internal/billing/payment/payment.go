@@ -13,9 +13,14 @@ type Payment struct {
 	AccountName string
 	Amount      currency.Amount
 
-	// This adjustment is a hack; need a new data model to handle account
-	// changeover and would help for multi-account payers.
-	Comments string
+	// Comments are recorded as the payment's journal memo.
+	// Account changeovers and other corrections are posted as
+	// entries in the journal file, see ledger.Record.
+	Comments string `csv:",optional"`
+
+	// Reference identifies an imported bank transaction, used to
+	// detect duplicate imports, e.g., "ofx:20250601001".
+	Reference string `csv:",optional"`
 }
 
 func (p Payment) Validate() error {
@@ -26,7 +31,7 @@ func (p Payment) Validate() error {
 		return fmt.Errorf("empty payment account name")
 	}
 	if p.Amount.Units() < 0 {
-		return fmt.Errorf("negative or zero payment is invalid")
+		return fmt.Errorf("negative payment is invalid, enter a refund as an adjustment")
 	}
 	return nil
 }
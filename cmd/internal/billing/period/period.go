@@ -1,16 +1,146 @@
 package period
 
 import (
+	"encoding/json"
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/jmacd/caspar.water/cmd/internal/billing/csv"
 )
 
 // Periods are described by their start in CSV files, which must be
-// April or October 1st (any year). They are six months.
+// the first of a month aligned with the business's Schedule.  A
+// period read from a file has the Default schedule, six months
+// starting April or October 1st, until it is bound to another, see
+// Schedule.Bind.
 type Period struct {
-	start csv.Date
-	close csv.Date
+	start    csv.Date
+	schedule Schedule
+}
+
+// Length is the number of months in a billing period.
+type Length int
+
+const (
+	Monthly    Length = 1
+	Quarterly  Length = 3
+	SemiAnnual Length = 6
+	Annual     Length = 12
+)
+
+func (l *Length) UnmarshalJSON(data []byte) error {
+	var months int
+	if json.Unmarshal(data, &months) == nil {
+		*l = Length(months)
+		return nil
+	}
+	var s string
+	err := json.Unmarshal(data, &s)
+	if err != nil {
+		return err
+	}
+	if months, err := strconv.Atoi(strings.TrimSpace(s)); err == nil {
+		*l = Length(months)
+		return nil
+	}
+	str := strings.ReplaceAll(strings.ToLower(s), "-", "")
+	switch str {
+	case "monthly":
+		*l = Monthly
+	case "quarterly":
+		*l = Quarterly
+	case "", "semiannual":
+		*l = SemiAnnual
+	case "annual":
+		*l = Annual
+	default:
+		return fmt.Errorf("invalid billing period: %q", s)
+	}
+	return nil
+}
+
+// Name describes the length on statements.
+func (l Length) Name() string {
+	switch l {
+	case Monthly:
+		return "Monthly"
+	case Quarterly:
+		return "Quarterly"
+	case Annual:
+		return "Annual"
+	default:
+		return "Semi-annual"
+	}
+}
+
+// Schedule describes the length and alignment of billing periods.
+type Schedule struct {
+	// Length is the number of months per period.
+	Length Length
+
+	// Anchor is a month in which periods start.
+	Anchor time.Month
+}
+
+// Default is the original schedule, six-month periods starting
+// April and October.
+var Default = Schedule{
+	Length: SemiAnnual,
+	Anchor: time.April,
+}
+
+func (s Schedule) Validate() error {
+	switch s.Length {
+	case Monthly, Quarterly, SemiAnnual, Annual:
+	default:
+		return fmt.Errorf("billing period should be 1, 3, 6, or 12 months: %d", s.Length)
+	}
+	if s.Anchor < time.January || s.Anchor > time.December {
+		return fmt.Errorf("invalid anchor month: %d", s.Anchor)
+	}
+	return nil
+}
+
+// PerYear is the number of periods in a year.
+func (s Schedule) PerYear() int {
+	return 12 / int(s.Length)
+}
+
+// starts lists the months in which periods start.
+func (s Schedule) starts() []time.Month {
+	var months []time.Month
+	for i := 0; i < s.PerYear(); i++ {
+		months = append(months, time.Month((int(s.Anchor)-1+i*int(s.Length))%12+1))
+	}
+	return months
+}
+
+// Bind returns the period in this schedule, an error when its start
+// is not aligned with the schedule.
+func (s Schedule) Bind(p Period) (Period, error) {
+	p.schedule = s
+	return p, p.Validate()
+}
+
+// ParseStart parses and validates the start of a period in this
+// schedule.
+func (s Schedule) ParseStart(str string) (Period, error) {
+	var p Period
+	if err := p.UnmarshalJSON([]byte(fmt.Sprintf("%q", str))); err != nil {
+		return p, err
+	}
+	return s.Bind(p)
+}
+
+// Containing returns the period of this schedule that contains the
+// date.
+func (s Schedule) Containing(d csv.Date) Period {
+	t := d.Date()
+	months := (int(t.Month()) - int(s.Anchor) + 12) % int(s.Length)
+	start := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location()).AddDate(0, -months, 0)
+	return Period{start: csv.DateFromTime(start), schedule: s}
 }
 
 func (p *Period) UnmarshalJSON(data []byte) error {
@@ -19,7 +149,6 @@ func (p *Period) UnmarshalJSON(data []byte) error {
 		return err
 	}
 	p.start = d
-	p.close = csv.DateFromTime(d.Date().AddDate(0, 6, -1))
 	return nil
 }
 
@@ -27,10 +156,29 @@ func (p *Period) Starting() csv.Date {
 	return p.start
 }
 
+// Schedule is the schedule of the period.
+func (p Period) Schedule() Schedule {
+	if p.schedule.Length == 0 {
+		return Default
+	}
+	return p.schedule
+}
+
+// Closing is the last day of the period in its schedule.
 func (p *Period) Closing() csv.Date {
-	return p.close
+	return csv.DateFromTime(p.start.Date().AddDate(0, int(p.Schedule().Length), -1))
+}
+
+// Since returns the number of periods from an earlier period to p,
+// negative when p is earlier.
+func (p Period) Since(earlier Period) int {
+	a, b := earlier.start.Date(), p.start.Date()
+	months := (b.Year()-a.Year())*12 + int(b.Month()) - int(a.Month())
+	return months / int(p.Schedule().Length)
 }
 
+// ParseStart parses and validates the start of a period in the
+// Default schedule, as read from a file.
 func ParseStart(s string) (Period, error) {
 	var p Period
 	if err := p.UnmarshalJSON([]byte(fmt.Sprintf("%q", s))); err != nil {
@@ -43,10 +191,16 @@ func (p Period) Validate() error {
 	if p.start.Date().Day() != 1 {
 		return fmt.Errorf("periods start on the first of the months")
 	}
-	switch p.start.Date().Month() {
-	case 4, 10:
-	default:
-		return fmt.Errorf("periods start in April (4) and October (10)")
+	sched := p.Schedule()
+	months := sched.starts()
+	for _, m := range months {
+		if p.start.Date().Month() == m {
+			return nil
+		}
 	}
-	return nil
+	var names []string
+	for _, m := range months {
+		names = append(names, fmt.Sprintf("%s (%d)", m, m))
+	}
+	return fmt.Errorf("%s periods start in %s", strings.ToLower(sched.Length.Name()), strings.Join(names, ", "))
 }
@@ -4,8 +4,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/jmacd/caspar.water/cmd/internal/billing/constant"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/csv"
 	"github.com/stretchr/testify/require"
 )
 
@@ -43,3 +45,107 @@ func TestPeriodJSONBad(t *testing.T) {
 		require.Error(t, p.Validate())
 	}
 }
+
+func TestPeriodSchedule(t *testing.T) {
+	for _, test := range []struct {
+		schedule Schedule
+		good     [][]string
+		bad      []string
+	}{
+		{
+			Schedule{Length: Quarterly, Anchor: time.February},
+			[][]string{
+				{"2/1/2024", "4/30/2024"},
+				{"5/1/2024", "7/31/2024"},
+				{"11/1/2024", "1/31/2025"},
+			},
+			[]string{"1/1/2024", "4/1/2024", "5/2/2024"},
+		},
+		{
+			Schedule{Length: Monthly, Anchor: time.April},
+			[][]string{
+				{"1/1/2024", "1/31/2024"},
+				{"2/1/2024", "2/29/2024"},
+			},
+			[]string{"2/15/2024"},
+		},
+		{
+			Schedule{Length: Annual, Anchor: time.July},
+			[][]string{
+				{"7/1/2024", "6/30/2025"},
+			},
+			[]string{"1/1/2024", "4/1/2024", "10/1/2024"},
+		},
+	} {
+		require.NoError(t, test.schedule.Validate())
+
+		for _, good := range test.good {
+			p, err := test.schedule.ParseStart(good[0])
+			require.NoError(t, err)
+			require.Equal(t, test.schedule, p.Schedule())
+			require.Equal(t, good[1], p.Closing().Date().Format(constant.CsvLayout))
+
+			// A period read from a file is bound to the
+			// schedule.
+			var read Period
+			require.NoError(t, json.Unmarshal(quoteBytes(good[0]), &read))
+			bound, err := test.schedule.Bind(read)
+			require.NoError(t, err)
+			require.Equal(t, p, bound)
+		}
+		for _, bad := range test.bad {
+			_, err := test.schedule.ParseStart(bad)
+			require.Error(t, err, "for %s", bad)
+		}
+	}
+
+	// The Default schedule is unchanged.
+	p, err := ParseStart("4/1/2024")
+	require.NoError(t, err)
+	require.Equal(t, "9/30/2024", p.Closing().Date().Format(constant.CsvLayout))
+
+	require.Error(t, Schedule{Length: 4, Anchor: time.April}.Validate())
+	require.Error(t, Schedule{Length: Monthly}.Validate())
+}
+
+func TestLengthJSON(t *testing.T) {
+	for in, length := range map[string]Length{
+		`"Monthly"`:     Monthly,
+		`"quarterly"`:   Quarterly,
+		`"Semi-annual"`: SemiAnnual,
+		`""`:            SemiAnnual,
+		`"ANNUAL"`:      Annual,
+		`3`:             Quarterly,
+	} {
+		var l Length
+		require.NoError(t, json.Unmarshal([]byte(in), &l))
+		require.Equal(t, length, l)
+	}
+	var l Length
+	require.Error(t, json.Unmarshal([]byte(`"weekly"`), &l))
+}
+
+func TestPeriodContaining(t *testing.T) {
+	for _, test := range []struct {
+		schedule Schedule
+		date     string
+		start    string
+		since    int
+	}{
+		{Default, "10/1/2024", "10/1/2024", 0},
+		{Default, "3/31/2025", "10/1/2024", 0},
+		{Default, "4/1/2025", "4/1/2025", 1},
+		{Default, "9/15/2026", "4/1/2026", 3},
+		{Schedule{Length: Quarterly, Anchor: time.February}, "1/31/2025", "11/1/2024", 0},
+		{Schedule{Length: Quarterly, Anchor: time.February}, "2/1/2025", "2/1/2025", 1},
+	} {
+		d, err := csv.ParseDate(test.date)
+		require.NoError(t, err)
+		p := test.schedule.Containing(d)
+		require.Equal(t, test.start, p.Starting().Date().Format(constant.CsvLayout))
+
+		first := test.schedule.Containing(csv.DateFromTime(time.Date(2024, time.November, 1, 0, 0, 0, 0, time.UTC)))
+		require.Equal(t, test.since, p.Since(first), "for %s", test.date)
+		require.Equal(t, -test.since, first.Since(p))
+	}
+}
@@ -0,0 +1,227 @@
+package projection
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/jmacd/caspar.water/cmd/internal/billing/constant"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/currency"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/expense"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/invoice"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/period"
+	"github.com/jmacd/maroto/pkg/pdf"
+)
+
+// Estimate is one projected expense and the basis for it.
+type Estimate struct {
+	Expense string
+	Amount  currency.Amount
+	Basis   string
+}
+
+// Cycle is the projection of one cycle's expenses.
+type Cycle struct {
+	PeriodStart period.Period
+	Name        string
+	Method      expense.Projection
+	Estimates   []Estimate
+}
+
+// Report lists the projected cycles.
+type Report []Cycle
+
+// point is a prior amount, by cycle or year number.
+type point struct {
+	x      int
+	name   string
+	amount currency.Amount
+}
+
+// Project computes the expenses of each row with a Projection from
+// the prior cycles, preferring a revision's actual amounts to the
+// original estimate.  Prior projections that were not revised are
+// not used.  Operations and utilities are projected from every prior
+// cycle; taxes and insurance are yearly amounts projected for the
+// first cycle of each year, see expense.SplitAnnual.  The rows are
+// modified in place.
+func Project(rows []expense.Cycle, perYear int) (Report, error) {
+	var originals []int
+	revised := map[int]int{}
+
+	for i, row := range rows {
+		if !row.Revision {
+			originals = append(originals, i)
+			continue
+		}
+		for j, o := range originals {
+			if rows[o].PeriodStart.Starting().Date().Equal(row.PeriodStart.Starting().Date()) {
+				revised[j] = i
+			}
+		}
+	}
+
+	var report Report
+	for k, o := range originals {
+		target := &rows[o]
+		if target.Projection == expense.NoProjection {
+			continue
+		}
+		var ops, utils, taxes, ins []point
+		for j := 0; j < k; j++ {
+			row := rows[originals[j]]
+			if r, ok := revised[j]; ok {
+				row = rows[r]
+			} else if row.Projection != expense.NoProjection {
+				continue
+			}
+			name := row.PeriodStart.Closing().Date().Format(constant.InvoiceDateLayout)
+
+			ops = append(ops, point{j, name, row.Operations})
+			utils = append(utils, point{j, name, row.Utilities})
+			if j%perYear == 0 {
+				taxes = append(taxes, point{j / perYear, name, row.Taxes})
+				ins = append(ins, point{j / perYear, name, row.Insurance})
+			}
+		}
+
+		pc := Cycle{
+			PeriodStart: target.PeriodStart,
+			Name:        target.PeriodStart.Closing().Date().Format(constant.InvoiceDateLayout),
+			Method:      target.Projection,
+		}
+		estimate := func(expense string, series []point, x, lag int, unit string) (currency.Amount, error) {
+			amount, basis, err := project(target.Projection, series, x, lag, unit)
+			if err != nil {
+				return amount, fmt.Errorf("projecting %s for cycle %s: %w", strings.ToLower(expense), pc.Name, err)
+			}
+			pc.Estimates = append(pc.Estimates, Estimate{
+				Expense: expense,
+				Amount:  amount,
+				Basis:   basis,
+			})
+			return amount, nil
+		}
+
+		var err error
+		if target.Operations, err = estimate("Operations", ops, k, perYear, "cycle"); err != nil {
+			return nil, err
+		}
+		if target.Utilities, err = estimate("Utilities", utils, k, perYear, "cycle"); err != nil {
+			return nil, err
+		}
+		if k%perYear == 0 {
+			if target.Taxes, err = estimate("Taxes", taxes, k/perYear, 1, "year"); err != nil {
+				return nil, err
+			}
+			if target.Insurance, err = estimate("Insurance", ins, k/perYear, 1, "year"); err != nil {
+				return nil, err
+			}
+		}
+		report = append(report, pc)
+	}
+	return report, nil
+}
+
+// project applies the method to the prior amounts.  The lag is the
+// distance to the same period of the previous year, which is also
+// the window of the trailing average.
+func project(method expense.Projection, series []point, x, lag int, unit string) (currency.Amount, string, error) {
+	switch method {
+	case expense.TrailingAverage:
+		var names []string
+		var sum int64
+		for _, p := range series {
+			if p.x < x-lag {
+				continue
+			}
+			names = append(names, p.name)
+			sum += p.amount.Units()
+		}
+		if len(names) == 0 {
+			return currency.Amount{}, "", fmt.Errorf("no %s in the past year", unit)
+		}
+		avg := currency.Units(int64(math.Round(float64(sum) / float64(len(names)))))
+		return avg, "average of " + strings.Join(names, ", "), nil
+
+	case expense.SamePeriodLastYear:
+		for _, p := range series {
+			if p.x == x-lag {
+				return p.amount, "same as " + p.name, nil
+			}
+		}
+		return currency.Amount{}, "", fmt.Errorf("no %s a year earlier", unit)
+
+	case expense.LinearTrend:
+		if len(series) < 2 {
+			return currency.Amount{}, "", fmt.Errorf("a trend requires two prior amounts")
+		}
+		var mx, my float64
+		for _, p := range series {
+			mx += float64(p.x)
+			my += float64(p.amount.Units())
+		}
+		n := float64(len(series))
+		mx /= n
+		my /= n
+
+		var sxy, sxx float64
+		for _, p := range series {
+			dx := float64(p.x) - mx
+			sxy += dx * (float64(p.amount.Units()) - my)
+			sxx += dx * dx
+		}
+		slope := sxy / sxx
+		value := math.Max(0, math.Round(my+slope*(float64(x)-mx)))
+
+		change := currency.Units(int64(math.Round(slope))).Display()
+		if slope >= 0 {
+			change = "+" + change
+		}
+		basis := fmt.Sprintf("trend of %s to %s, %s per %s", series[0].name, series[len(series)-1].name, change, unit)
+		return currency.Units(int64(value)), basis, nil
+	}
+	return currency.Amount{}, "", fmt.Errorf("invalid projection: %q", method)
+}
+
+func header() []string {
+	return []string{"Cycle", "Method", "Expense", "Amount", "Basis"}
+}
+
+func (r Report) rows() [][]string {
+	var rows [][]string
+	for _, c := range r {
+		for _, e := range c.Estimates {
+			rows = append(rows, []string{
+				c.Name,
+				c.Method.Display(),
+				e.Expense,
+				e.Amount.Display(),
+				e.Basis,
+			})
+		}
+	}
+	return rows
+}
+
+// WriteCSV writes one row per projected expense.
+func (r Report) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(header()); err != nil {
+		return err
+	}
+	if err := cw.WriteAll(r.rows()); err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// MainContent renders the report as a table.
+func (r Report) MainContent(m pdf.Maroto) {
+	m.Row(2, func() {
+		m.TableList(header(), r.rows(), invoice.TableStyle)
+	})
+}
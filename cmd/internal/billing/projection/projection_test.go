@@ -0,0 +1,120 @@
+package projection
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/jmacd/caspar.water/cmd/internal/billing/csv"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/expense"
+	"github.com/stretchr/testify/require"
+)
+
+const cyclesHeader = "Period Start,Operations,Utilities,Insurance,Taxes,Bill Date,Method,Margin,Effective Connections,Revision,Projection\n"
+
+const history = `10/1/1914,$300.00,$100.00,$600.00,$400.00,5/1/1915,Normal,0.0,3,,
+4/1/1915,$400.00,$200.00,$0.00,$0.00,10/15/1915,Normal,0.0,3,,
+10/1/1915,$500.00,$300.00,$800.00,$600.00,4/15/1916,Normal,0.0,3,,
+`
+
+func readCycles(t *testing.T, data string) []expense.Cycle {
+	cycles, err := csv.Read[expense.Cycle]("<input>", bytes.NewBufferString(cyclesHeader+data))
+	require.NoError(t, err)
+	return cycles
+}
+
+func amounts(c expense.Cycle) []string {
+	return []string{
+		c.Operations.Display(),
+		c.Utilities.Display(),
+		c.Insurance.Display(),
+		c.Taxes.Display(),
+	}
+}
+
+func TestProject(t *testing.T) {
+	for _, test := range []struct {
+		next   string
+		expect []string
+		basis  string
+	}{
+		// The second cycle of a year has no taxes or insurance.
+		{
+			"4/1/1916,$0.00,$0.00,$0.00,$0.00,7/1/1916,Normal,0.0,3,,Trailing Average",
+			[]string{"$450.00", "$250.00", "$0.00", "$0.00"},
+			"average of 1915-Sep, 1916-Mar",
+		},
+		{
+			"4/1/1916,$0.00,$0.00,$0.00,$0.00,7/1/1916,Normal,0.0,3,,same-period-last-year",
+			[]string{"$400.00", "$200.00", "$0.00", "$0.00"},
+			"same as 1915-Sep",
+		},
+		{
+			"4/1/1916,$0.00,$0.00,$0.00,$0.00,7/1/1916,Normal,0.0,3,,LinearTrend",
+			[]string{"$600.00", "$400.00", "$0.00", "$0.00"},
+			"trend of 1915-Mar to 1916-Mar, +$100.00 per cycle",
+		},
+		// The first cycle of a year projects the yearly amounts.
+		{
+			"4/1/1916,$600.00,$400.00,$0.00,$0.00,10/15/1916,Normal,0.0,3,,\n" +
+				"10/1/1916,$0.00,$0.00,$0.00,$0.00,1/1/1917,Normal,0.0,3,,TrailingAverage",
+			[]string{"$550.00", "$350.00", "$800.00", "$600.00"},
+			"average of 1916-Mar, 1916-Sep",
+		},
+		{
+			"4/1/1916,$600.00,$400.00,$0.00,$0.00,10/15/1916,Normal,0.0,3,,\n" +
+				"10/1/1916,$0.00,$0.00,$0.00,$0.00,1/1/1917,Normal,0.0,3,,LinearTrend",
+			[]string{"$700.00", "$500.00", "$1,000.00", "$800.00"},
+			"trend of 1915-Mar to 1916-Sep, +$100.00 per cycle",
+		},
+	} {
+		rows := readCycles(t, history+test.next+"\n")
+		report, err := Project(rows, 2)
+		require.NoError(t, err, "for %s", test.next)
+		require.Equal(t, 1, len(report))
+		require.Equal(t, test.expect, amounts(rows[len(rows)-1]))
+		require.Equal(t, "Operations", report[0].Estimates[0].Expense)
+		require.Equal(t, test.basis, report[0].Estimates[0].Basis)
+	}
+}
+
+func TestProjectRevised(t *testing.T) {
+	// The projected 1915-Sep cycle is revised, and the revision
+	// is used to project 1916-Mar.
+	rows := readCycles(t, `10/1/1914,$300.00,$100.00,$600.00,$400.00,5/1/1915,Normal,0.0,3,,
+4/1/1915,$0.00,$0.00,$0.00,$0.00,7/1/1915,Normal,0.0,3,,TrailingAverage
+10/1/1915,$0.00,$0.00,$0.00,$0.00,1/1/1916,Normal,0.0,3,,TrailingAverage
+4/1/1915,$500.00,$300.00,$0.00,$0.00,10/15/1915,Normal,0.0,3,TRUE,
+`)
+	report, err := Project(rows, 2)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(report))
+	require.Equal(t, []string{"$300.00", "$100.00", "$0.00", "$0.00"}, amounts(rows[1]))
+	require.Equal(t, []string{"$400.00", "$200.00", "$600.00", "$400.00"}, amounts(rows[2]))
+	require.Equal(t, "average of 1915-Mar, 1915-Sep", report[1].Estimates[0].Basis)
+}
+
+func TestProjectInsufficient(t *testing.T) {
+	for _, test := range []string{
+		"10/1/1914,$0.00,$0.00,$0.00,$0.00,1/1/1915,Normal,0.0,3,,TrailingAverage",
+		"10/1/1914,$300.00,$100.00,$600.00,$400.00,5/1/1915,Normal,0.0,3,,\n" +
+			"4/1/1915,$0.00,$0.00,$0.00,$0.00,7/1/1915,Normal,0.0,3,,LinearTrend",
+		"10/1/1914,$300.00,$100.00,$600.00,$400.00,5/1/1915,Normal,0.0,3,,\n" +
+			"4/1/1915,$0.00,$0.00,$0.00,$0.00,7/1/1915,Normal,0.0,3,,SamePeriodLastYear",
+	} {
+		_, err := Project(readCycles(t, test+"\n"), 2)
+		require.Error(t, err, "for %s", test)
+	}
+}
+
+func TestReportCSV(t *testing.T) {
+	rows := readCycles(t, history+"4/1/1916,$0.00,$0.00,$0.00,$0.00,7/1/1916,Normal,0.0,3,,SamePeriodLastYear\n")
+	report, err := Project(rows, 2)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, report.WriteCSV(&buf))
+	require.Equal(t, `Cycle,Method,Expense,Amount,Basis
+1916-Sep,same period last year,Operations,$400.00,same as 1915-Sep
+1916-Sep,same period last year,Utilities,$200.00,same as 1915-Sep
+`, buf.String())
+}
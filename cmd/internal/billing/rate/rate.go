@@ -0,0 +1,153 @@
+package rate
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/jmacd/caspar.water/cmd/internal/billing/currency"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/meter"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/period"
+)
+
+// Block is one step of a tiered volumetric rate.  The blocks for one
+// period are applied in order of their upper limits.
+type Block struct {
+	// PeriodStart is the billing cycle this block applies to.
+	PeriodStart period.Period
+
+	// UpToGallons is the cumulative upper limit of the block,
+	// zero indicates no limit (the final block).
+	UpToGallons meter.Gallons
+
+	// PerThousandGallons is the volumetric price.
+	PerThousandGallons currency.Amount
+}
+
+// Bind binds the block's period to the business's schedule.
+func (b *Block) Bind(s period.Schedule) error {
+	p, err := s.Bind(b.PeriodStart)
+	if err != nil {
+		return err
+	}
+	b.PeriodStart = p
+	return nil
+}
+
+func (b Block) Validate() error {
+	if b.PerThousandGallons.Units() < 0 {
+		return fmt.Errorf("negative volumetric rate")
+	}
+	return nil
+}
+
+// Schedule is the tiered volumetric rate for one period.
+type Schedule []Block
+
+// ScheduleFor returns the blocks that apply to a period, in order.
+// The result is nil when the period has no volumetric rate.
+func ScheduleFor(blocks []Block, p period.Period) (Schedule, error) {
+	var sched Schedule
+	for _, b := range blocks {
+		if b.PeriodStart.Starting().Date().Equal(p.Starting().Date()) {
+			sched = append(sched, b)
+		}
+	}
+	sort.SliceStable(sched, func(i, j int) bool {
+		if sched[i].UpToGallons == 0 {
+			return false
+		}
+		return sched[j].UpToGallons == 0 || sched[i].UpToGallons < sched[j].UpToGallons
+	})
+	for i := range sched {
+		if i > 0 && sched[i].UpToGallons != 0 && sched[i].UpToGallons == sched[i-1].UpToGallons {
+			return nil, fmt.Errorf("duplicate rate block: %v", sched[i].UpToGallons.Display())
+		}
+		if i < len(sched)-1 && sched[i].UpToGallons == 0 {
+			return nil, fmt.Errorf("only the final rate block may be unlimited")
+		}
+	}
+	if len(sched) != 0 && sched[len(sched)-1].UpToGallons != 0 {
+		return nil, fmt.Errorf("the final rate block must be unlimited")
+	}
+	return sched, nil
+}
+
+// Tier is the charge computed for one block.
+type Tier struct {
+	From   meter.Gallons
+	To     meter.Gallons // zero means no limit
+	Used   meter.Gallons
+	Rate   currency.Amount
+	Amount currency.Amount
+}
+
+// Description names the tier for the statement.
+func (t Tier) Description() string {
+	if t.To == 0 {
+		if t.From == 0 {
+			return "All usage"
+		}
+		return "Over " + t.From.Display()
+	}
+	if t.From == 0 {
+		return "First " + t.To.Display()
+	}
+	return "Next " + (t.To - t.From).Display()
+}
+
+func (t Tier) Gallons() string {
+	return t.Used.Display()
+}
+
+func (t Tier) PerThousand() string {
+	return t.Rate.Display() + "/1,000 gal"
+}
+
+func (t Tier) Charge() string {
+	return t.Amount.Display()
+}
+
+// Charge is a metered bill: a fixed base charge plus a volumetric
+// charge for each block.
+type Charge struct {
+	Gallons meter.Gallons
+	Base    currency.Amount
+	Tiers   []Tier
+	Usage   currency.Amount
+}
+
+func (c Charge) Total() currency.Amount {
+	return currency.Sum(c.Base, c.Usage)
+}
+
+// Compute bills the gallons used according to the schedule.  The base
+// charge is multiplied by the connection weight.
+func (s Schedule) Compute(base currency.Amount, weight int, used meter.Gallons) Charge {
+	c := Charge{
+		Gallons: used,
+		Base:    currency.Units(base.Units() * int64(weight)),
+	}
+	var from meter.Gallons
+	for _, b := range s {
+		if from != 0 && used <= from {
+			break
+		}
+		inBlock := used - from
+		if b.UpToGallons != 0 && used > b.UpToGallons {
+			inBlock = b.UpToGallons - from
+		}
+		// Round to the nearest cent.
+		units := (int64(inBlock)*b.PerThousandGallons.Units() + 500) / 1000
+		t := Tier{
+			From:   from,
+			To:     b.UpToGallons,
+			Used:   inBlock,
+			Rate:   b.PerThousandGallons,
+			Amount: currency.Units(units),
+		}
+		c.Tiers = append(c.Tiers, t)
+		c.Usage = currency.Sum(c.Usage, t.Amount)
+		from = b.UpToGallons
+	}
+	return c
+}
@@ -0,0 +1,66 @@
+package rate
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/jmacd/caspar.water/cmd/internal/billing"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/csv"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/currency"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/meter"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/period"
+	"github.com/stretchr/testify/require"
+)
+
+const blocks = `Period Start,Up To Gallons,Per Thousand Gallons
+4/1/2026,0,"$9.00"
+4/1/2026,3000,"$4.00"
+4/1/2026,6000,"$6.00"
+10/1/2026,0,"$5.00"
+`
+
+func TestScheduleCompute(t *testing.T) {
+	list, err := csv.Read[Block]("<input>", bytes.NewBufferString(blocks))
+	require.NoError(t, err)
+
+	sched, err := ScheduleFor(list, internal.Must(period.ParseStart("4/1/2026")))
+	require.NoError(t, err)
+	require.Equal(t, 3, len(sched))
+
+	for _, test := range []struct {
+		used  uint64
+		usage int64
+		tiers int
+	}{
+		{0, 0, 1},
+		{2000, 800, 1},
+		{3000, 1200, 1},
+		{4500, 1200 + 900, 2},
+		{10000, 1200 + 1800 + 3600, 3},
+		{3333, 1200 + 200, 2},
+	} {
+		c := sched.Compute(currency.Units(5000), 2, meter.Gallons(test.used))
+		require.Equal(t, int64(10000), c.Base.Units())
+		require.Equal(t, test.usage, c.Usage.Units(), "for %d", test.used)
+		require.Equal(t, test.tiers, len(c.Tiers), "for %d", test.used)
+	}
+
+	none, err := ScheduleFor(list, internal.Must(period.ParseStart("4/1/2027")))
+	require.NoError(t, err)
+	require.Nil(t, none)
+}
+
+func TestScheduleInvalid(t *testing.T) {
+	for _, test := range []string{
+		"4/1/2026,3000,\"$4.00\"\n",
+		"4/1/2026,0,\"$4.00\"\n4/1/2026,0,\"$5.00\"\n",
+		"4/1/2026,3000,\"$4.00\"\n4/1/2026,3000,\"$5.00\"\n4/1/2026,0,\"$5.00\"\n",
+	} {
+		data := "Period Start,Up To Gallons,Per Thousand Gallons\n" + test
+		list, err := csv.Read[Block]("<input>", bytes.NewBufferString(data))
+		require.NoError(t, err)
+
+		_, err = ScheduleFor(list, internal.Must(period.ParseStart("4/1/2026")))
+		require.Error(t, err, "for %s", test)
+	}
+}
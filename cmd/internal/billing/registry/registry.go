@@ -0,0 +1,222 @@
+package registry
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/jmacd/caspar.water/cmd/internal/billing/constant"
+	billingcsv "github.com/jmacd/caspar.water/cmd/internal/billing/csv"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/currency"
+	"github.com/spf13/afero"
+)
+
+// Record is an issued statement.  Once a cycle is issued, its
+// statements are closed: a later run that computes a different
+// statement for a closed cycle is refused, and corrections are
+// entered as journal adjustments on a later statement.
+type Record struct {
+	// AccountName is the account, or payer of a consolidated
+	// statement.
+	AccountName string
+
+	// Cycle is the closing month, e.g., "2025-Sep", and
+	// InvoiceNumber is printed on the statement.
+	Cycle         string
+	InvoiceNumber string
+
+	IssueDate    billingcsv.Date
+	AmountDue    currency.Amount
+	PriorBalance currency.Amount
+	TotalDue     currency.Amount
+
+	// Hash identifies the statement's content, see package
+	// logic.
+	Hash string
+}
+
+func (r Record) Validate() error {
+	if r.AccountName == "" || r.Cycle == "" || r.InvoiceNumber == "" {
+		return fmt.Errorf("issued statement requires an account, cycle, and invoice number")
+	}
+	if r.Hash == "" {
+		return fmt.Errorf("issued statement %s has no hash", r.key())
+	}
+	return nil
+}
+
+func (r Record) key() string {
+	return r.AccountName + " " + r.InvoiceNumber
+}
+
+// Registry is the set of issued statements.
+type Registry struct {
+	records map[string]Record
+	cycles  map[string]bool
+}
+
+func New() *Registry {
+	return &Registry{
+		records: map[string]Record{},
+		cycles:  map[string]bool{},
+	}
+}
+
+// Read reads a registry file; a missing file is an empty registry.
+func Read(name string, fs afero.Fs) (*Registry, error) {
+	r := New()
+	if _, err := fs.Stat(name); errors.Is(err, os.ErrNotExist) {
+		return r, nil
+	}
+	recs, err := billingcsv.ReadFile[Record](name, fs)
+	if err != nil {
+		return nil, err
+	}
+	for _, rec := range recs {
+		if err := r.Add(rec); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return r, nil
+}
+
+// Add records an issued statement.  A statement is issued once.
+func (r *Registry) Add(rec Record) error {
+	if _, ok := r.records[rec.key()]; ok {
+		return fmt.Errorf("statement %s was already issued", rec.key())
+	}
+	r.records[rec.key()] = rec
+	r.cycles[rec.Cycle] = true
+	return nil
+}
+
+// Closed indicates that statements of the cycle were issued.
+func (r *Registry) Closed(cycle string) bool {
+	return r.cycles[cycle]
+}
+
+// Lookup returns the issued statement of an account.
+func (r *Registry) Lookup(accountName, invoiceNumber string) (Record, bool) {
+	rec, ok := r.records[accountName+" "+invoiceNumber]
+	return rec, ok
+}
+
+// Records returns the issued statements by cycle and account.
+func (r *Registry) Records() []Record {
+	var recs []Record
+	for _, rec := range r.records {
+		recs = append(recs, rec)
+	}
+	sort.Slice(recs, func(i, j int) bool {
+		if !recs[i].IssueDate.Date().Equal(recs[j].IssueDate.Date()) {
+			return recs[i].IssueDate.Before(recs[j].IssueDate)
+		}
+		return recs[i].key() < recs[j].key()
+	})
+	return recs
+}
+
+var header = []string{
+	"Account Name",
+	"Cycle",
+	"Invoice Number",
+	"Issue Date",
+	"Amount Due",
+	"Prior Balance",
+	"Total Due",
+	"Hash",
+}
+
+// Write replaces the registry file.
+func (r *Registry) Write(name string) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	cw := csv.NewWriter(f)
+	_ = cw.Write(header)
+	for _, rec := range r.Records() {
+		_ = cw.Write([]string{
+			rec.AccountName,
+			rec.Cycle,
+			rec.InvoiceNumber,
+			rec.IssueDate.Date().Format(constant.CsvLayout),
+			rec.AmountDue.Display(),
+			rec.PriorBalance.Display(),
+			rec.TotalDue.Display(),
+			rec.Hash,
+		})
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Change is the difference between an issued statement and the
+// statement computed for it now.  Missing indicates the statement is
+// no longer computed, Added that a closed cycle has a new statement.
+type Change struct {
+	Issued   Record
+	Computed Record
+	Missing  bool
+	Added    bool
+}
+
+// Changes compares the statements computed for a cycle with those
+// issued, when the cycle is closed.
+func (r *Registry) Changes(cycle string, computed []Record) []Change {
+	if !r.Closed(cycle) {
+		return nil
+	}
+	var chs []Change
+	found := map[string]bool{}
+	for _, comp := range computed {
+		found[comp.key()] = true
+
+		issued, ok := r.records[comp.key()]
+		switch {
+		case !ok:
+			chs = append(chs, Change{Computed: comp, Added: true})
+		case issued.AmountDue != comp.AmountDue ||
+			issued.PriorBalance != comp.PriorBalance ||
+			issued.TotalDue != comp.TotalDue ||
+			issued.Hash != comp.Hash:
+			chs = append(chs, Change{Issued: issued, Computed: comp})
+		}
+	}
+	for _, rec := range r.Records() {
+		if rec.Cycle == cycle && !found[rec.key()] {
+			chs = append(chs, Change{Issued: rec, Missing: true})
+		}
+	}
+	return chs
+}
+
+// String describes the change, listing the amounts that differ.
+func (c Change) String() string {
+	switch {
+	case c.Missing:
+		return fmt.Sprintf("%s: issued statement is missing", c.Issued.key())
+	case c.Added:
+		return fmt.Sprintf("%s: new statement in a closed cycle, total due %s", c.Computed.key(), c.Computed.TotalDue.Display())
+	}
+	var diffs []string
+	diff := func(what string, a, b currency.Amount) {
+		if a != b {
+			diffs = append(diffs, fmt.Sprintf("%s %s => %s", what, a.Display(), b.Display()))
+		}
+	}
+	diff("amount due", c.Issued.AmountDue, c.Computed.AmountDue)
+	diff("prior balance", c.Issued.PriorBalance, c.Computed.PriorBalance)
+	diff("total due", c.Issued.TotalDue, c.Computed.TotalDue)
+	if len(diffs) == 0 {
+		diffs = append(diffs, "content changed")
+	}
+	return c.Issued.key() + ": " + strings.Join(diffs, "; ")
+}
@@ -0,0 +1,72 @@
+package registry
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jmacd/caspar.water/cmd/internal/billing/csv"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/currency"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func record(t *testing.T, name, cycle string, due int64) Record {
+	date, err := csv.ParseDate("5/1/1915")
+	require.NoError(t, err)
+	return Record{
+		AccountName:   name,
+		Cycle:         cycle,
+		InvoiceNumber: cycle,
+		IssueDate:     date,
+		AmountDue:     currency.Units(due),
+		TotalDue:      currency.Units(due),
+		Hash:          "sha256:" + name,
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "issued.csv")
+
+	reg, err := Read(name, afero.NewOsFs())
+	require.NoError(t, err)
+	require.False(t, reg.Closed("1915-Mar"))
+
+	require.NoError(t, reg.Add(record(t, "House2", "1915-Mar", 15000)))
+	require.NoError(t, reg.Add(record(t, "House3", "1915-Mar", 15000)))
+	require.Error(t, reg.Add(record(t, "House3", "1915-Mar", 15000)))
+	require.NoError(t, reg.Write(name))
+
+	reg, err = Read(name, afero.NewOsFs())
+	require.NoError(t, err)
+	require.True(t, reg.Closed("1915-Mar"))
+	require.Equal(t, []Record{
+		record(t, "House2", "1915-Mar", 15000),
+		record(t, "House3", "1915-Mar", 15000),
+	}, reg.Records())
+
+	data, err := os.ReadFile(name)
+	require.NoError(t, err)
+	require.Contains(t, string(data), "House2,1915-Mar,1915-Mar,5/1/1915,$150.00,$0.00,$150.00,sha256:House2\n")
+
+	// Open cycles have no changes.
+	require.Nil(t, reg.Changes("1915-Sep", []Record{record(t, "House2", "1915-Sep", 1)}))
+
+	changed := record(t, "House2", "1915-Mar", 16000)
+	content := record(t, "House3", "1915-Mar", 15000)
+	content.Hash = "sha256:other"
+	var descs []string
+	for _, ch := range reg.Changes("1915-Mar", []Record{changed, record(t, "House4", "1915-Mar", 100)}) {
+		descs = append(descs, ch.String())
+	}
+	require.Equal(t, []string{
+		"House2 1915-Mar: amount due $150.00 => $160.00; total due $150.00 => $160.00",
+		"House4 1915-Mar: new statement in a closed cycle, total due $1.00",
+		"House3 1915-Mar: issued statement is missing",
+	}, descs)
+
+	chs := reg.Changes("1915-Mar", []Record{record(t, "House2", "1915-Mar", 15000), content})
+	require.Equal(t, 1, len(chs))
+	require.Equal(t, "House3 1915-Mar: content changed", chs[0].String())
+}
@@ -0,0 +1,166 @@
+package reserve
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/jmacd/caspar.water/cmd/internal/billing/constant"
+	billingcsv "github.com/jmacd/caspar.water/cmd/internal/billing/csv"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/currency"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/invoice"
+	"github.com/jmacd/maroto/pkg/pdf"
+)
+
+// Transaction is a row of the reserve file, a capital withdrawal or a
+// deposit other than margin, e.g., the opening balance.
+type Transaction struct {
+	Date billingcsv.Date
+
+	// Amount is positive for deposits and negative for
+	// withdrawals.
+	Amount currency.Amount
+
+	Description string
+}
+
+func (t Transaction) Validate() error {
+	if t.Amount.IsZero() {
+		return fmt.Errorf("reserve transaction has no amount")
+	}
+	if t.Description == "" {
+		return fmt.Errorf("reserve transaction requires a description")
+	}
+	return nil
+}
+
+// Margin is the margin collected for one cycle, deposited to the
+// reserve on the cycle's bill date.
+type Margin struct {
+	Date   billingcsv.Date
+	Cycle  string
+	Amount currency.Amount
+}
+
+// Entry is one line of the reserve ledger.
+type Entry struct {
+	Date        billingcsv.Date
+	Description string
+	Deposit     currency.Amount
+	Withdrawal  currency.Amount
+	Balance     currency.Amount
+}
+
+// Ledger is the reserve fund through a date.
+type Ledger struct {
+	AsOf    billingcsv.Date
+	Entries []Entry
+
+	Margin      currency.Amount // Margin collected
+	Deposits    currency.Amount // Other deposits
+	Withdrawals currency.Amount
+	Balance     currency.Amount
+}
+
+// Build orders the margins and transactions dated through asOf by
+// date, margins first on the same date, and computes the running
+// balance.  A withdrawal larger than the balance is an error.
+func Build(margins []Margin, txns []Transaction, asOf billingcsv.Date) (Ledger, error) {
+	l := Ledger{AsOf: asOf}
+
+	for _, m := range margins {
+		if m.Date.Date().After(asOf.Date()) {
+			continue
+		}
+		l.Entries = append(l.Entries, Entry{
+			Date:        m.Date,
+			Description: "Margin collected, cycle " + m.Cycle,
+			Deposit:     m.Amount,
+		})
+		l.Margin = currency.Sum(l.Margin, m.Amount)
+	}
+	for _, t := range txns {
+		if t.Date.Date().After(asOf.Date()) {
+			continue
+		}
+		e := Entry{
+			Date:        t.Date,
+			Description: t.Description,
+		}
+		if t.Amount.Units() < 0 {
+			e.Withdrawal = currency.Difference(currency.Units(0), t.Amount)
+			l.Withdrawals = currency.Sum(l.Withdrawals, e.Withdrawal)
+		} else {
+			e.Deposit = t.Amount
+			l.Deposits = currency.Sum(l.Deposits, e.Deposit)
+		}
+		l.Entries = append(l.Entries, e)
+	}
+	sort.SliceStable(l.Entries, func(i, j int) bool {
+		return l.Entries[i].Date.Before(l.Entries[j].Date)
+	})
+
+	for i := range l.Entries {
+		e := &l.Entries[i]
+		l.Balance = currency.Difference(currency.Sum(l.Balance, e.Deposit), e.Withdrawal)
+		if l.Balance.Units() < 0 {
+			return l, fmt.Errorf("reserve withdrawal on %s exceeds the balance: %s",
+				e.Date.Date().Format(constant.CsvLayout), e.Description)
+		}
+		e.Balance = l.Balance
+	}
+	return l, nil
+}
+
+func header() []string {
+	return []string{"Date", "Description", "Deposit", "Withdrawal", "Balance"}
+}
+
+func display(a currency.Amount) string {
+	if a.IsZero() {
+		return ""
+	}
+	return a.Display()
+}
+
+func (l Ledger) rows() [][]string {
+	var rows [][]string
+	for _, e := range l.Entries {
+		rows = append(rows, []string{
+			e.Date.Date().Format(constant.CsvLayout),
+			e.Description,
+			display(e.Deposit),
+			display(e.Withdrawal),
+			e.Balance.Display(),
+		})
+	}
+	return rows
+}
+
+func (l Ledger) totalRow() []string {
+	return []string{
+		"",
+		"Total",
+		currency.Sum(l.Margin, l.Deposits).Display(),
+		l.Withdrawals.Display(),
+		l.Balance.Display(),
+	}
+}
+
+// WriteCSV writes the ledger with a total row.
+func (l Ledger) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write(header())
+	_ = cw.WriteAll(l.rows())
+	_ = cw.Write(l.totalRow())
+	cw.Flush()
+	return cw.Error()
+}
+
+// MainContent renders the ledger table.
+func (l Ledger) MainContent(m pdf.Maroto) {
+	m.Row(2, func() {
+		m.TableList(header(), append(l.rows(), []string{}, l.totalRow()), invoice.TableStyle)
+	})
+}
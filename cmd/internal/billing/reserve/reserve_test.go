@@ -0,0 +1,105 @@
+package reserve
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/jmacd/caspar.water/cmd/internal/billing/csv"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/currency"
+	"github.com/stretchr/testify/require"
+)
+
+func date(t *testing.T, s string) csv.Date {
+	d, err := csv.ParseDate(s)
+	require.NoError(t, err)
+	return d
+}
+
+func TestBuild(t *testing.T) {
+	margins := []Margin{
+		{Date: date(t, "5/1/2025"), Cycle: "2025-Mar", Amount: currency.Units(30000)},
+		{Date: date(t, "11/1/2025"), Cycle: "2025-Sep", Amount: currency.Units(32000)},
+		{Date: date(t, "5/1/2026"), Cycle: "2026-Mar", Amount: currency.Units(33000)},
+	}
+	txns := []Transaction{
+		{Date: date(t, "11/1/2025"), Amount: currency.Units(-50000), Description: "Pump"},
+		{Date: date(t, "1/1/2025"), Amount: currency.Units(100000), Description: "Opening balance"},
+	}
+	l, err := Build(margins, txns, date(t, "12/31/2025"))
+	require.NoError(t, err)
+
+	require.Equal(t, 4, len(l.Entries))
+	require.Equal(t, "Opening balance", l.Entries[0].Description)
+	require.Equal(t, "Margin collected, cycle 2025-Sep", l.Entries[2].Description)
+	require.Equal(t, "Pump", l.Entries[3].Description)
+	require.Equal(t, "$1,120.00", l.Balance.Display())
+	require.Equal(t, "$620.00", l.Margin.Display())
+	require.Equal(t, "$500.00", l.Withdrawals.Display())
+
+	var buf bytes.Buffer
+	require.NoError(t, l.WriteCSV(&buf))
+	require.Contains(t, buf.String(), "11/1/2025,Pump,,$500.00,\"$1,120.00\"\n")
+
+	// Withdrawing more than the balance.
+	txns[0].Amount = currency.Units(-200000)
+	_, err = Build(margins, txns, date(t, "12/31/2025"))
+	require.ErrorContains(t, err, "exceeds the balance: Pump")
+}
+
+func TestSimulate(t *testing.T) {
+	margins, err := ParseMargins("0, 0.1, 0.2")
+	require.NoError(t, err)
+	_, err = ParseMargins("0,x")
+	require.Error(t, err)
+
+	b := Basis{
+		Start:       date(t, "1/1/2026"),
+		Expenses:    currency.Units(1000000),
+		PerYear:     2,
+		Connections: 10,
+		Balance:     currency.Units(100000),
+		Planned: []Transaction{
+			{Date: date(t, "6/1/2027"), Amount: currency.Units(-150000), Description: "Tank"},
+		},
+	}
+	sim, err := Simulate(b, 4, []Scenario{
+		{Margins: margins},
+		{Margins: []float64{0.2}, Growth: 0.1},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 8, len(sim))
+
+	require.Equal(t, "margin 0%, 10%, 20%; growth 0%", sim[0].Scenario)
+	require.Equal(t, 0.0, sim[0].Margin)
+	require.Equal(t, "$500.00", sim[0].Charge.Display())
+	require.Equal(t, "$1,000.00", sim[0].Balance.Display())
+
+	require.Equal(t, 0.1, sim[1].Margin)
+	require.Equal(t, "$550.00", sim[1].Charge.Display())
+	require.Equal(t, "$1,500.00", sim[1].Withdrawals.Display())
+	require.Equal(t, "$500.00", sim[1].Balance.Display())
+
+	require.Equal(t, 0.2, sim[3].Margin)
+	require.Equal(t, "$4,500.00", sim[3].Balance.Display())
+
+	require.Equal(t, "$11,000.00", sim[4].Expenses.Display())
+	require.Equal(t, "$660.00", sim[4].Charge.Display())
+	require.Equal(t, "$2,200.00", sim[4].Collected.Display())
+
+	// Growth is exact, rounded to the cent by the basis.
+	b.Expenses = currency.Units(100050)
+	for mode, want := range map[currency.Rounding]string{
+		currency.RoundDown:   "$1,030.51",
+		currency.RoundHalfUp: "$1,030.52",
+	} {
+		b.Rounding = mode
+		sim, err := Simulate(b, 1, []Scenario{{Margins: []float64{0}, Growth: 0.03}})
+		require.NoError(t, err)
+		require.Equal(t, want, sim[0].Expenses.Display(), "for %s", mode)
+	}
+
+	_, err = Simulate(b, 4, []Scenario{{Margins: []float64{1.5}}})
+	require.Error(t, err)
+	_, err = Simulate(b, 0, []Scenario{{Margins: margins}})
+	require.Error(t, err)
+}
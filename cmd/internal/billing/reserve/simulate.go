@@ -0,0 +1,221 @@
+package reserve
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math/big"
+	"strconv"
+	"strings"
+
+	billingcsv "github.com/jmacd/caspar.water/cmd/internal/billing/csv"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/currency"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/invoice"
+	"github.com/jmacd/maroto/pkg/pdf"
+)
+
+// Scenario is a margin policy and an expense growth rate.
+type Scenario struct {
+	// Margins are the margins of successive years, the last of
+	// which continues, e.g., 0, 0.1, 0.2 to reach a 20% target
+	// margin in the third year.
+	Margins []float64
+
+	// Growth is the yearly increase in expenses, e.g., 0.03.
+	Growth float64
+}
+
+// ParseMargins reads a comma-separated margin schedule.
+func ParseMargins(s string) ([]float64, error) {
+	var r []float64
+	for _, f := range strings.Split(s, ",") {
+		m, err := strconv.ParseFloat(strings.TrimSpace(f), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid margin schedule %q: %w", s, err)
+		}
+		r = append(r, m)
+	}
+	return r, nil
+}
+
+func (s Scenario) Validate() error {
+	if len(s.Margins) == 0 {
+		return fmt.Errorf("scenario has no margins")
+	}
+	for _, m := range s.Margins {
+		if m < 0 || m >= 1 {
+			return fmt.Errorf("margin should be a fraction: %v", m)
+		}
+	}
+	if s.Growth <= -1 {
+		return fmt.Errorf("invalid expense growth: %v", s.Growth)
+	}
+	return nil
+}
+
+// Margin returns the margin of a year, starting at 1.
+func (s Scenario) Margin(year int) float64 {
+	return s.Margins[min(year, len(s.Margins))-1]
+}
+
+// Name describes the scenario, e.g., "margin 0%, 10%, 20%; growth 3%".
+func (s Scenario) Name() string {
+	var ms []string
+	for _, m := range s.Margins {
+		ms = append(ms, percent(m))
+	}
+	return "margin " + strings.Join(ms, ", ") + "; growth " + percent(s.Growth)
+}
+
+func percent(f float64) string {
+	return strconv.FormatFloat(100*f, 'f', -1, 64) + "%"
+}
+
+// Basis is the starting point of a simulation.
+type Basis struct {
+	// Start begins the first simulated year.
+	Start billingcsv.Date
+
+	// Expenses are the expenses of the last year of cycles,
+	// billed in PerYear cycles to Connections.
+	Expenses    currency.Amount
+	PerYear     int
+	Connections int
+
+	// Balance is the reserve on the start date.
+	Balance currency.Amount
+
+	// Rounding rounds the simulated expenses and margin to the
+	// cent.
+	Rounding currency.Rounding
+
+	// Planned transactions dated after the start date are
+	// entered in the year they fall in.
+	Planned []Transaction
+}
+
+// Year is one simulated year of a scenario.
+type Year struct {
+	Scenario string
+	Year     int
+	Margin   float64
+	Growth   float64
+
+	// Expenses for the year, and the charge per connection for
+	// each cycle.
+	Expenses currency.Amount
+	Charge   currency.Amount
+
+	// Collected is the margin, assuming every charge is paid.
+	// Withdrawals are net of planned deposits.
+	Collected   currency.Amount
+	Withdrawals currency.Amount
+	Balance     currency.Amount
+}
+
+// Simulation is the years of each scenario.
+type Simulation []Year
+
+// Simulate projects the reserve balance and the charge per connection
+// of each scenario for the given number of years.  The balance may
+// become negative, showing a shortfall.
+func Simulate(b Basis, years int, scenarios []Scenario) (Simulation, error) {
+	if years <= 0 {
+		return nil, fmt.Errorf("simulation requires a number of years: %d", years)
+	}
+	if b.PerYear <= 0 || b.Connections <= 0 {
+		return nil, fmt.Errorf("simulation requires cycles and connections")
+	}
+	var sim Simulation
+	for _, s := range scenarios {
+		if err := s.Validate(); err != nil {
+			return nil, err
+		}
+		balance := b.Balance
+		for year := 1; year <= years; year++ {
+			from := b.Start.Date().AddDate(year-1, 0, 0)
+			to := b.Start.Date().AddDate(year, 0, 0)
+
+			var withdrawn currency.Amount
+			for _, t := range b.Planned {
+				if t.Date.Date().After(from) && !t.Date.Date().After(to) {
+					withdrawn = currency.Difference(withdrawn, t.Amount)
+				}
+			}
+
+			margin := s.Margin(year)
+			growth := new(big.Rat).Add(big.NewRat(1, 1), currency.Decimal(s.Growth))
+			factor := big.NewRat(1, 1)
+			for range year {
+				factor.Mul(factor, growth)
+			}
+			expenses := b.Expenses.Mul(factor, b.Rounding)
+			total := expenses.Mul(new(big.Rat).Add(big.NewRat(1, 1), currency.Decimal(margin)), b.Rounding)
+			collected := currency.Difference(total, expenses)
+			balance = currency.Difference(currency.Sum(balance, collected), withdrawn)
+
+			sim = append(sim, Year{
+				Scenario:    s.Name(),
+				Year:        year,
+				Margin:      margin,
+				Growth:      s.Growth,
+				Expenses:    expenses,
+				Charge:      total.Split(b.PerYear)[0].Split(b.Connections)[0],
+				Collected:   collected,
+				Withdrawals: withdrawn,
+				Balance:     balance,
+			})
+		}
+	}
+	return sim, nil
+}
+
+func simulationHeader() []string {
+	return []string{"Scenario", "Year", "Margin", "Expenses", "Charge", "Collected", "Withdrawals", "Balance"}
+}
+
+func (sim Simulation) rows() [][]string {
+	var rows [][]string
+	for _, y := range sim {
+		rows = append(rows, []string{
+			y.Scenario,
+			fmt.Sprint(y.Year),
+			percent(y.Margin),
+			y.Expenses.Display(),
+			y.Charge.Display(),
+			y.Collected.Display(),
+			y.Withdrawals.Display(),
+			y.Balance.Display(),
+		})
+	}
+	return rows
+}
+
+// WriteCSV writes one row per scenario and year.
+func (sim Simulation) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write(simulationHeader())
+	_ = cw.WriteAll(sim.rows())
+	cw.Flush()
+	return cw.Error()
+}
+
+// MainContent renders one table per scenario.
+func (sim Simulation) MainContent(m pdf.Maroto) {
+	for start := 0; start < len(sim); {
+		end := start + 1
+		for end < len(sim) && sim[end].Scenario == sim[start].Scenario {
+			end++
+		}
+		// The scenario names the table, and its year column.
+		header := append([]string{sim[start].Scenario}, simulationHeader()[2:]...)
+		var body [][]string
+		for _, r := range sim[start:end].rows() {
+			body = append(body, append([]string{"Year " + r[1]}, r[2:]...))
+		}
+		m.Row(2, func() {
+			m.TableList(header, body, invoice.TableStyle)
+		})
+		start = end
+	}
+}
@@ -1,10 +1,16 @@
 package user
 
 import (
+	"encoding/json"
 	"fmt"
+	"net/mail"
+	"strings"
+	"time"
 
 	"github.com/jmacd/caspar.water/cmd/internal/billing/address"
-	"github.com/jmacd/caspar.water/cmd/internal/billing/bool"
+	billingbool "github.com/jmacd/caspar.water/cmd/internal/billing/bool"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/constant"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/csv"
 	"github.com/jmacd/caspar.water/cmd/internal/billing/period"
 )
 
@@ -27,8 +33,52 @@ type User struct {
 	// FirstPeriodStart is the initial billing cycle.
 	FirstPeriodStart period.Period
 
-	// Commercial indicates double weight.
-	Commercial bool.Bool
+	// ServiceStart is optional, the first day of service, in
+	// the first period.  ServiceEnd is optional, the last day
+	// of service, e.g., when the property is disconnected or
+	// sold; its period is the final bill.  The share of a
+	// partial period is prorated by day.
+	ServiceStart csv.OptionalDate `csv:",optional"`
+	ServiceEnd   csv.OptionalDate `csv:",optional"`
+
+	// Commercial indicates double weight, for an account
+	// without a weight in the weights file.
+	Commercial billingbool.Bool `csv:",optional"`
+
+	// Email is an optional address for emailed statements.
+	Email string `csv:",optional"`
+
+	// Services is an optional comma-separated list of the
+	// additional services billed to the account, e.g., Septic,
+	// each a section of its statement.
+	Services Services `csv:",optional"`
+}
+
+// Services lists the additional services of an account.
+type Services []string
+
+func (s *Services) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return err
+	}
+	*s = nil
+	for _, name := range strings.Split(str, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			*s = append(*s, name)
+		}
+	}
+	return nil
+}
+
+// Has indicates the account is billed for the service.
+func (u User) Has(service string) bool {
+	for _, name := range u.Services {
+		if name == service {
+			return true
+		}
+	}
+	return false
 }
 
 func (u User) Validate() error {
@@ -44,8 +94,85 @@ func (u User) Validate() error {
 	if u.BillingAddress == "" {
 		return fmt.Errorf("empty service address")
 	}
-	if err := u.FirstPeriodStart.Validate(); err != nil {
+	if end, ok := u.ServiceEnd.Get(); ok {
+		if end.Before(u.serviceStart()) {
+			return fmt.Errorf("service end %s is before the service start", end.Date().Format(constant.CsvLayout))
+		}
+	}
+	if u.Email != "" {
+		if _, err := mail.ParseAddress(u.Email); err != nil {
+			return fmt.Errorf("user email: %w", err)
+		}
+	}
+	return nil
+}
+
+// Bind binds the first period to the business's schedule, an error
+// when it is not aligned with the schedule or service does not start
+// in it.
+func (u *User) Bind(s period.Schedule) error {
+	first, err := s.Bind(u.FirstPeriodStart)
+	if err != nil {
 		return err
 	}
+	u.FirstPeriodStart = first
+	if start, ok := u.ServiceStart.Get(); ok {
+		if start.Before(first.Starting()) || start.Date().After(first.Closing().Date()) {
+			return fmt.Errorf("service start %s is not in the first period", start.Date().Format(constant.CsvLayout))
+		}
+	}
 	return nil
 }
+
+// serviceStart is the first day of service.
+func (u User) serviceStart() csv.Date {
+	if start, ok := u.ServiceStart.Get(); ok {
+		return start
+	}
+	return u.FirstPeriodStart.Starting()
+}
+
+// Serves indicates the account is billed for the period, from its
+// first period through the period of its service end.
+func (u User) Serves(p period.Period) bool {
+	if u.FirstPeriodStart.Starting().Date().After(p.Starting().Date()) {
+		return false
+	}
+	end, ok := u.ServiceEnd.Get()
+	return !ok || !end.Before(p.Starting())
+}
+
+// Final indicates the period is the account's final bill.
+func (u User) Final(p period.Period) bool {
+	end, ok := u.ServiceEnd.Get()
+	return ok && u.Serves(p) && !end.Date().After(p.Closing().Date())
+}
+
+// ServiceDays returns the days of service in the period and the
+// days of the period.
+func (u User) ServiceDays(p period.Period) (days, total int) {
+	total = daysBetween(p.Starting().Date(), p.Closing().Date())
+	if !u.Serves(p) {
+		return 0, total
+	}
+	first, last := u.ServicePeriod(p)
+	return daysBetween(first.Date(), last.Date()), total
+}
+
+// ServicePeriod returns the first and last days of service in the
+// period, for an account that Serves it.
+func (u User) ServicePeriod(p period.Period) (first, last csv.Date) {
+	first, last = p.Starting(), p.Closing()
+	if start := u.serviceStart(); first.Before(start) {
+		first = start
+	}
+	if end, ok := u.ServiceEnd.Get(); ok && end.Before(last) {
+		last = end
+	}
+	return first, last
+}
+
+// daysBetween counts the days from first through last.
+func daysBetween(first, last time.Time) int {
+	return int(last.Sub(first).Hours()/24+0.5) + 1
+}
@@ -3,6 +3,7 @@ package user
 import (
 	"bytes"
 	"testing"
+	"time"
 
 	"github.com/jmacd/caspar.water/cmd/internal/billing"
 	"github.com/jmacd/caspar.water/cmd/internal/billing/csv"
@@ -11,9 +12,9 @@ import (
 )
 
 func TestUserRead(t *testing.T) {
-	data := `Account Name,User Name,Service Address,Billing Address,Active,First Period Start
-TestAcct1,Mister and Misses,1 Driveway,1 P.O. Box,TRUE,4/1/2022
-TestAcct2,Misses and Mister,2 Driveway,2 P.O. Box,FALSE,10/1/2022
+	data := `Account Name,User Name,Service Address,Billing Address,First Period Start
+TestAcct1,Mister and Misses,1 Driveway,1 P.O. Box,4/1/2022
+TestAcct2,Misses and Mister,2 Driveway,2 P.O. Box,10/1/2022
 `
 	users, err := csv.Read[User]("<input>", bytes.NewBufferString(data))
 	require.NoError(t, err)
@@ -33,4 +34,92 @@ TestAcct2,Misses and Mister,2 Driveway,2 P.O. Box,FALSE,10/1/2022
 			FirstPeriodStart: internal.Must(period.ParseStart("10/1/2022")),
 		},
 	}, users)
+
+	// Columns without a field are an error.
+	_, err = csv.Read[User]("<input>", bytes.NewBufferString(`Account Name,User Name,Service Address,Billing Address,Active,First Period Start
+TestAcct1,Mister and Misses,1 Driveway,1 P.O. Box,TRUE,4/1/2022
+`))
+	require.EqualError(t, err, `<input>:1:56: unknown column "Active"`)
+}
+
+func TestUserEmail(t *testing.T) {
+	data := `Account Name,User Name,Service Address,Billing Address,First Period Start,Commercial,Email
+TestAcct1,Mister and Misses,1 Driveway,1 P.O. Box,4/1/2022,FALSE,"Misses <misses@example.com>"
+TestAcct2,Misses and Mister,2 Driveway,2 P.O. Box,10/1/2022,FALSE,
+`
+	users, err := csv.Read[User]("<input>", bytes.NewBufferString(data))
+	require.NoError(t, err)
+	require.Equal(t, "Misses <misses@example.com>", users[0].Email)
+	require.Equal(t, "", users[1].Email)
+
+	_, err = csv.Read[User]("<input>", bytes.NewBufferString(`Account Name,User Name,Service Address,Billing Address,First Period Start,Email
+TestAcct1,Mister,1 Driveway,1 P.O. Box,4/1/2022,not an address
+`))
+	require.Error(t, err)
+}
+
+func TestUserService(t *testing.T) {
+	data := `Account Name,User Name,Service Address,Billing Address,First Period Start,Service Start,Service End
+TestAcct1,Mister and Misses,1 Driveway,1 P.O. Box,4/1/2022,6/15/2022,11/30/2022
+TestAcct2,Misses and Mister,2 Driveway,2 P.O. Box,4/1/2022,,
+`
+	users, err := csv.Read[User]("<input>", bytes.NewBufferString(data))
+	require.NoError(t, err)
+
+	first := internal.Must(period.ParseStart("4/1/2022"))
+	second := internal.Must(period.ParseStart("10/1/2022"))
+	third := internal.Must(period.ParseStart("4/1/2023"))
+
+	for _, test := range []struct {
+		user   User
+		p      period.Period
+		serves bool
+		final  bool
+		days   int
+		total  int
+	}{
+		{users[0], first, true, false, 108, 183},
+		{users[0], second, true, true, 61, 182},
+		{users[0], third, false, false, 0, 183},
+		{users[1], first, true, false, 183, 183},
+		{users[1], third, true, false, 183, 183},
+	} {
+		require.Equal(t, test.serves, test.user.Serves(test.p))
+		require.Equal(t, test.final, test.user.Final(test.p))
+		days, total := test.user.ServiceDays(test.p)
+		require.Equal(t, test.days, days)
+		require.Equal(t, test.total, total)
+	}
+
+	for i := range users {
+		require.NoError(t, users[i].Bind(period.Default))
+	}
+
+	// Service starts in the first period of the schedule.
+	late, err := csv.Read[User]("<input>", bytes.NewBufferString(`Account Name,User Name,Service Address,Billing Address,First Period Start,Service Start,Service End
+TestAcct1,Mister,1 Driveway,1 P.O. Box,4/1/2022,10/15/2022,
+`))
+	require.NoError(t, err)
+	require.Error(t, late[0].Bind(period.Default))
+	require.NoError(t, late[0].Bind(period.Schedule{Length: period.Annual, Anchor: time.April}))
+	require.Error(t, late[0].Bind(period.Schedule{Length: period.Quarterly, Anchor: time.February}))
+
+	_, err = csv.Read[User]("<input>", bytes.NewBufferString(`Account Name,User Name,Service Address,Billing Address,First Period Start,Service Start,Service End
+TestAcct1,Mister,1 Driveway,1 P.O. Box,4/1/2022,6/15/2022,6/1/2022
+`))
+	require.Error(t, err)
+}
+
+func TestUserServices(t *testing.T) {
+	data := `Account Name,User Name,Service Address,Billing Address,First Period Start,Services
+TestAcct1,Mister and Misses,1 Driveway,1 P.O. Box,4/1/2022,"Septic, Trash"
+TestAcct2,Misses and Mister,2 Driveway,2 P.O. Box,4/1/2022,
+`
+	users, err := csv.Read[User]("<input>", bytes.NewBufferString(data))
+	require.NoError(t, err)
+	require.Equal(t, Services{"Septic", "Trash"}, users[0].Services)
+	require.True(t, users[0].Has("Septic"))
+	require.False(t, users[0].Has("Water"))
+	require.Nil(t, users[1].Services)
+	require.False(t, users[1].Has("Septic"))
 }
@@ -0,0 +1,131 @@
+package weight
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jmacd/caspar.water/cmd/internal/billing/constant"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/csv"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/period"
+)
+
+// Size is a meter size, e.g., "5/8" or "1.5", kept as written.
+type Size string
+
+func (s *Size) UnmarshalText(text []byte) error {
+	*s = Size(strings.TrimSpace(string(text)))
+	return nil
+}
+
+// Equivalent is a row of the meter sizes file, the number of
+// equivalent dwelling units of a meter size.
+type Equivalent struct {
+	MeterSize   Size
+	Equivalents int
+}
+
+func (e Equivalent) Validate() error {
+	if e.MeterSize == "" {
+		return fmt.Errorf("meter size equivalent requires a size")
+	}
+	if e.Equivalents <= 0 {
+		return fmt.Errorf("meter size %s requires a positive number of equivalents", e.MeterSize)
+	}
+	return nil
+}
+
+// Assignment is a row of the weights file.  An account is assigned
+// either an explicit weight or a meter size, effective for the
+// periods starting on or after the effective date.
+type Assignment struct {
+	AccountName   string
+	EffectiveDate csv.Date
+
+	Weight    int  `csv:",optional"`
+	MeterSize Size `csv:",optional"`
+}
+
+func (a Assignment) Validate() error {
+	if a.AccountName == "" {
+		return fmt.Errorf("weight requires an account name")
+	}
+	if err := a.EffectiveDate.Validate(); err != nil {
+		return err
+	}
+	if a.Weight < 0 {
+		return fmt.Errorf("weight of %s cannot be negative", a.AccountName)
+	}
+	if (a.Weight == 0) == (a.MeterSize == "") {
+		return fmt.Errorf("weight of %s requires either a weight or a meter size", a.AccountName)
+	}
+	return nil
+}
+
+// Schedule is the weight of each account over time.
+type Schedule struct {
+	byAccount map[string][]entry
+}
+
+type entry struct {
+	effective csv.Date
+	weight    int
+}
+
+// NewSchedule resolves the meter sizes of the assignments.  An
+// account may have one assignment per effective date.
+func NewSchedule(equivs []Equivalent, assigns []Assignment) (*Schedule, error) {
+	sizes := map[Size]int{}
+	for _, e := range equivs {
+		if _, ok := sizes[e.MeterSize]; ok {
+			return nil, fmt.Errorf("duplicate meter size: %s", e.MeterSize)
+		}
+		sizes[e.MeterSize] = e.Equivalents
+	}
+	s := &Schedule{
+		byAccount: map[string][]entry{},
+	}
+	for _, a := range assigns {
+		w := a.Weight
+		if a.MeterSize != "" {
+			var ok bool
+			if w, ok = sizes[a.MeterSize]; !ok {
+				return nil, fmt.Errorf("weight of %s: unknown meter size: %s", a.AccountName, a.MeterSize)
+			}
+		}
+		s.byAccount[a.AccountName] = append(s.byAccount[a.AccountName], entry{
+			effective: a.EffectiveDate,
+			weight:    w,
+		})
+	}
+	for name, list := range s.byAccount {
+		sort.SliceStable(list, func(i, j int) bool {
+			return list[i].effective.Before(list[j].effective)
+		})
+		for i := 1; i < len(list); i++ {
+			if list[i].effective.Date().Equal(list[i-1].effective.Date()) {
+				return nil, fmt.Errorf("duplicate weight: %s on %s",
+					name, list[i].effective.Date().Format(constant.CsvLayout))
+			}
+		}
+	}
+	return s, nil
+}
+
+// Weight returns the account's weight for a period, the latest
+// assignment effective on or before the period start.  The result is
+// false when the account has no weight in effect.
+func (s *Schedule) Weight(account string, p period.Period) (int, bool) {
+	if s == nil {
+		return 0, false
+	}
+	start := p.Starting().Date()
+	w, ok := 0, false
+	for _, e := range s.byAccount[account] {
+		if e.effective.Date().After(start) {
+			break
+		}
+		w, ok = e.weight, true
+	}
+	return w, ok
+}
@@ -0,0 +1,109 @@
+package weight
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/jmacd/caspar.water/cmd/internal/billing"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/csv"
+	"github.com/jmacd/caspar.water/cmd/internal/billing/period"
+	"github.com/stretchr/testify/require"
+)
+
+const sizes = `Meter Size,Equivalents
+5/8,1
+1,3
+1.5,5
+`
+
+const weights = `Account Name,Effective Date,Weight,Meter Size
+School,10/1/2025,2,
+School,4/1/2026,,1.5
+House2,10/1/2025,,5/8
+House3,5/15/2026,3,
+`
+
+func TestSchedule(t *testing.T) {
+	equivs, err := csv.Read[Equivalent]("<sizes>", bytes.NewBufferString(sizes))
+	require.NoError(t, err)
+	assigns, err := csv.Read[Assignment]("<weights>", bytes.NewBufferString(weights))
+	require.NoError(t, err)
+	require.Equal(t, Size("1.5"), assigns[1].MeterSize)
+
+	sched, err := NewSchedule(equivs, assigns)
+	require.NoError(t, err)
+
+	first := internal.Must(period.ParseStart("10/1/2025"))
+	second := internal.Must(period.ParseStart("4/1/2026"))
+	third := internal.Must(period.ParseStart("10/1/2026"))
+
+	for _, test := range []struct {
+		account string
+		p       period.Period
+		weight  int
+		ok      bool
+	}{
+		{"School", first, 2, true},
+		{"School", second, 5, true},
+		{"School", third, 5, true},
+		{"House2", first, 1, true},
+		{"House3", second, 0, false},
+		{"House3", third, 3, true},
+		{"House4", first, 0, false},
+	} {
+		w, ok := sched.Weight(test.account, test.p)
+		require.Equal(t, test.ok, ok, "%s %v", test.account, test.p)
+		require.Equal(t, test.weight, w, "%s %v", test.account, test.p)
+	}
+
+	var none *Schedule
+	_, ok := none.Weight("School", first)
+	require.False(t, ok)
+}
+
+func TestScheduleErrors(t *testing.T) {
+	equivs, err := csv.Read[Equivalent]("<sizes>", bytes.NewBufferString(sizes))
+	require.NoError(t, err)
+
+	_, err = NewSchedule(equivs, []Assignment{{AccountName: "School", MeterSize: "2"}})
+	require.ErrorContains(t, err, "unknown meter size")
+
+	date := internal.Must(csv.ParseDate("10/1/2025"))
+	_, err = NewSchedule(equivs, []Assignment{
+		{AccountName: "School", EffectiveDate: date, Weight: 2},
+		{AccountName: "School", EffectiveDate: date, MeterSize: "1"},
+	})
+	require.ErrorContains(t, err, "duplicate weight")
+
+	_, err = csv.Read[Assignment]("<weights>", bytes.NewBufferString(`Account Name,Effective Date,Weight,Meter Size
+School,10/1/2025,2,1
+`))
+	require.ErrorContains(t, err, "either a weight or a meter size")
+
+	// The meter sizes file requires a size.
+	_, err = csv.Read[Equivalent]("<sizes>", bytes.NewBufferString(`Equivalents
+1
+`))
+	require.Error(t, err)
+}
+
+func TestWeightsOnly(t *testing.T) {
+	// A weights file without meter sizes.
+	assigns, err := csv.Read[Assignment]("<weights>", bytes.NewBufferString(`Account Name,Effective Date,Weight
+School,10/1/2025,2
+House2,10/1/2025,1
+`))
+	require.NoError(t, err)
+	require.Equal(t, Size(""), assigns[0].MeterSize)
+
+	sched, err := NewSchedule(nil, assigns)
+	require.NoError(t, err)
+	w, ok := sched.Weight("School", internal.Must(period.ParseStart("4/1/2026")))
+	require.True(t, ok)
+	require.Equal(t, 2, w)
+
+	_, err = csv.Read[Assignment]("<weights>", bytes.NewBufferString(`Account Name,Effective Date,Weight
+School,10/1/2025,
+`))
+	require.ErrorContains(t, err, "either a weight or a meter size")
+}
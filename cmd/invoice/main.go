@@ -2,11 +2,15 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 	"slices"
 	"strings"
+	"time"
 
+	"github.com/jmacd/caspar.water/cmd/internal/billing/adhoc"
 	"github.com/jmacd/caspar.water/cmd/internal/billing/business"
 	"github.com/jmacd/caspar.water/cmd/internal/billing/csv"
 	"github.com/jmacd/caspar.water/cmd/internal/billing/currency"
@@ -20,17 +24,31 @@ import (
 )
 
 var (
-	inputFile    = flag.String("input", "invoice.yaml", "input yaml file")
-	outputFile   = flag.String("output", "output.pdf", "output pdf file")
+	inputFile    = flag.String("input", "invoice.yaml", "input yaml file, one invoice or a batch")
+	outputFile   = flag.String("output", "output.pdf", "output pdf file (one invoice)")
+	outputDir    = flag.String("dir", ".", "output directory (batch)")
 	usersFile    = flag.String("users", "users.csv", "csv")
 	businessFile = flag.String("business", "business.csv", "csv")
+	invoicesFile = flag.String("invoices", "invoices.csv", "csv of recorded invoices, shared with the billing program")
 )
 
 type Invoice struct {
+	// Number is optional, to choose the number of a new
+	// invoice.  Invoices are otherwise numbered in sequence.
+	Number int `yaml:"number"`
+
 	JobName string `yaml:"job_name"`
 	Account string `yaml:"account"`
 	Date    string `yaml:"date"`
 	Items   []Item `yaml:"items"`
+
+	// recorded is the invoice in the invoices file.
+	recorded adhoc.Invoice
+}
+
+// Batch is an input of several invoices.
+type Batch struct {
+	Invoices []Invoice `yaml:"invoices"`
 }
 
 type Item struct {
@@ -45,42 +63,61 @@ func (inv *Invoice) FullDate() string {
 }
 
 func (inv *Invoice) InvoiceName() string {
-	return strings.ReplaceAll(
+	name := strings.ReplaceAll(
 		cases.Title(language.English, cases.NoLower).String(inv.JobName),
 		" ",
 		"")
+	if inv.recorded.Number == 0 {
+		return name
+	}
+	return fmt.Sprintf("%d-%s", inv.recorded.Number, name)
 }
 
 func (*Invoice) BodyText() (string, error) {
 	return "", nil
 }
 
-func main() {
-	fs := afero.NewOsFs()
-	flag.Parse()
-
-	data, err := os.ReadFile(*inputFile)
-	if err != nil {
-		log.Fatalf("cannot read file: %v: %v", *inputFile, err)
+// date parses the invoice date, as 2021-01-15 or 1/15/2021.
+func (inv *Invoice) date() (csv.Date, error) {
+	if t, err := time.Parse(time.DateOnly, inv.Date); err == nil {
+		return csv.DateFromTime(t), nil
 	}
+	return csv.ParseDate(inv.Date)
+}
 
-	var inv Invoice
-
-	if err = yaml.Unmarshal([]byte(data), &inv); err != nil {
-		log.Fatalf("cannot unmarshal data: %v", err)
+func (inv *Invoice) total() currency.Amount {
+	var total currency.Amount
+	for _, item := range inv.Items {
+		total = currency.Sum(total, item.Amount)
 	}
+	return total
+}
 
-	// Users
-	users, err := csv.ReadFile[user.User](*usersFile, fs)
+// readInput reads one invoice, or a batch listing several under
+// "invoices".
+func readInput(name string) (_ []Invoice, isBatch bool, _ error) {
+	data, err := os.ReadFile(name)
 	if err != nil {
-		log.Fatalf("read users file: %v: %v", *usersFile, err)
+		return nil, false, fmt.Errorf("cannot read file: %v: %w", name, err)
+	}
+	var batch Batch
+	if err := yaml.Unmarshal(data, &batch); err == nil && len(batch.Invoices) != 0 {
+		return batch.Invoices, true, nil
+	}
+	var inv Invoice
+	if err := yaml.Unmarshal(data, &inv); err != nil {
+		return nil, false, fmt.Errorf("cannot unmarshal data: %w", err)
 	}
+	return []Invoice{inv}, false, nil
+}
 
-	uidx := slices.IndexFunc(users, func(u user.User) bool {
-		return u.AccountName == inv.Account
-	})
-	if uidx < 0 {
-		log.Fatalf("invalid user account: %v: %v", inv.Account, err)
+func main() {
+	fs := afero.NewOsFs()
+	flag.Parse()
+
+	invs, isBatch, err := readInput(*inputFile)
+	if err != nil {
+		log.Fatal(err)
 	}
 
 	// Business
@@ -92,26 +129,78 @@ func main() {
 		log.Fatalf("business file should have one row")
 	}
 
-	print, err := invoice.MakeInvoice(business[0], users[uidx], &inv, inv.mainContent)
+	// Users
+	users, err := csv.ReadFile[user.User](*usersFile, fs)
+	if err != nil {
+		log.Fatalf("read users file: %v: %v", *usersFile, err)
+	}
+
+	// Invoices are recorded before printing, so that a failed
+	// run printed again keeps the same numbers.
+	recorded, err := adhoc.Read(*invoicesFile, fs)
+	if err != nil {
+		log.Fatalf("read invoices file: %v", err)
+	}
+	accounts := make([]user.User, len(invs))
+	for i := range invs {
+		inv := &invs[i]
+		uidx := slices.IndexFunc(users, func(u user.User) bool {
+			return u.AccountName == inv.Account
+		})
+		if uidx < 0 {
+			log.Fatalf("invalid user account: %v", inv.Account)
+		}
+		accounts[i] = users[uidx]
+
+		date, err := inv.date()
+		if err != nil {
+			log.Fatalf("invoice %s: date: %v", inv.JobName, err)
+		}
+		inv.recorded, err = recorded.Record(adhoc.Invoice{
+			Number:      inv.Number,
+			Date:        date,
+			AccountName: inv.Account,
+			JobName:     inv.JobName,
+			Amount:      inv.total(),
+		})
+		if err != nil {
+			log.Fatalf("invoice %s: %v", inv.JobName, err)
+		}
+	}
+	if err := recorded.Write(*invoicesFile); err != nil {
+		log.Fatalf("write invoices file: %v", err)
+	}
 
-	if err := print.OutputFileAndClose(*outputFile); err != nil {
-		log.Fatalf("command failed: %v", err)
+	for i := range invs {
+		inv := &invs[i]
+		output := *outputFile
+		if isBatch {
+			output = filepath.Join(*outputDir, inv.InvoiceName()+".pdf")
+		}
+
+		print, err := invoice.DefaultLayout().PDF(business[0], accounts[i], inv, inv.mainContent)
+		if err != nil {
+			log.Fatalf("invoice %d: %v", inv.recorded.Number, err)
+		}
+		if err := print.OutputFileAndClose(output); err != nil {
+			log.Fatalf("command failed: %v", err)
+		}
+		fmt.Printf("Invoice %d %s %s %s (%s)\n",
+			inv.recorded.Number, inv.Account, inv.JobName, inv.recorded.Amount.Display(), output)
 	}
 }
 
 func (inv *Invoice) mainContent(m pdf.Maroto) {
 	var lines [][]string
-	var total currency.Amount
 
 	for _, item := range inv.Items {
 		lines = append(lines, []string{
 			item.Description,
 			item.Amount.Display(),
 		})
-		total = currency.Sum(total, item.Amount)
 	}
 	lines = append(lines, []string{"", ""})
-	lines = append(lines, []string{"Total", total.Display()})
+	lines = append(lines, []string{"Total", inv.total().Display()})
 
 	m.Row(2, func() {
 		m.TableList([]string{